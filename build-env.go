@@ -1,8 +1,12 @@
 package vugu
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/vugu/xxhash"
 )
@@ -46,6 +50,43 @@ type BuildEnv struct {
 
 	// used to determine "seen in this pass"
 	passNum uint8
+
+	// modification tracking for memoized components, see unmodified
+	modTracker *ModTracker
+
+	// the component whose Build method is being called, UseComponent calls are recorded for it
+	// so they can be repeated when its BuildOut is reused
+	building    Builder
+	compUses    map[buildCacheKey][]compUse // from this pass
+	compUsesOld map[buildCacheKey][]compUse // from prior pass
+
+	// Provide calls by component, so they can also be repeated when its BuildOut is reused
+	provides    map[buildCacheKey][]providedVal // from this pass
+	providesOld map[buildCacheKey][]providedVal // from prior pass
+
+	// components whose BuildOut was reused from the prior pass
+	reused map[buildCacheKey]bool
+
+	stats  BuildStats
+	tracer BuildTracer
+
+	// components entered by buildOne this pass, used to find the subtree to destroy after a panic
+	entered []Builder
+
+	errorHook func(err *BuildError)
+
+	// indexes of the fields tagged `vugu:"cparam"` or `vugu:"modcheck"` by component type
+	memoFieldsByType map[reflect.Type][]int
+
+	// components to build even if unmodified, see Rebuild and RebuildAll
+	rebuild       map[Builder]bool
+	rebuildAll    bool // for the next pass
+	rebuildingAll bool // for this pass
+}
+
+type compUse struct {
+	key  CompKey
+	comp Builder
 }
 
 // BuildResults contains the BuildOut values for full tree of components built.
@@ -53,6 +94,22 @@ type BuildResults struct {
 	Out *BuildOut
 
 	allOut map[buildCacheKey]*BuildOut
+	reused map[buildCacheKey]bool
+}
+
+// Reused returns true if the BuildOut for the component is the same one as in the prior build
+// pass, i.e. its Build method returned the BuildOut it returned last time, which a component can do
+// when nothing its output depends on has changed (the BuildOut must not be modified in between),
+// or the component is memoized (see ModChecker) and Build was not called.
+// Renderers can use this to skip syncing output which has not changed.
+func (r *BuildResults) Reused(component any) bool {
+	return r.reused[makeBuildCacheKey(component)]
+}
+
+// Counts returns the number of components built in this pass and how many of those reused their
+// BuildOut from the prior pass.
+func (r *BuildResults) Counts() (built, reused int) {
+	return len(r.allOut), len(r.reused)
 }
 
 // ResultFor is alias for indexing into AllOut.
@@ -96,12 +153,57 @@ func (e *BuildEnv) RunBuild(builder Builder) *BuildResults {
 	// swap cache and results, so the prior results is the new cache
 	e.buildCache, e.buildResults = e.buildResults, e.buildCache
 
+	start := time.Now()
+
 	e.passNum++
 
+	if e.modTracker == nil {
+		e.modTracker = NewModTracker()
+	} else {
+		e.modTracker.TrackNext()
+	}
+
+	// swap the recorded UseComponent calls, the prior ones are needed for any reused BuildOut
+	if e.compUses == nil {
+		e.compUses = make(map[buildCacheKey][]compUse)
+	}
+	e.compUsesOld, e.compUses = e.compUses, e.compUsesOld
+	if e.compUses == nil {
+		e.compUses = make(map[buildCacheKey][]compUse)
+	}
+	for k := range e.compUses {
+		delete(e.compUses, k)
+	}
+
+	e.providesOld, e.provides = e.provides, e.providesOld
+	if e.provides == nil {
+		e.provides = make(map[buildCacheKey][]providedVal)
+	}
+	for k := range e.provides {
+		delete(e.provides, k)
+	}
+
+	// a new map each pass since it is returned in BuildResults
+	e.reused = make(map[buildCacheKey]bool)
+
 	if e.compStateMap == nil {
 		e.compStateMap = make(map[Builder]compState)
 	}
 
+	e.entered = e.entered[:0]
+
+	e.rebuildingAll, e.rebuildAll = e.rebuildAll, false
+
+	// report panics that no ErrorBoundary handles
+	if e.errorHook != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				e.errorHook(e.newBuildError(r, 0))
+				panic(r)
+			}
+		}()
+	}
+
 	var buildIn BuildIn
 	buildIn.BuildEnv = e
 	// buildIn.PositionHashList starts empty
@@ -113,23 +215,45 @@ func (e *BuildEnv) RunBuild(builder Builder) *BuildResults {
 	if len(buildIn.PositionHashList) != 0 {
 		panic(fmt.Errorf("unexpected PositionHashList len = %d", len(buildIn.PositionHashList)))
 	}
+	if len(buildIn.provided) != 0 {
+		panic(fmt.Errorf("unexpected provided len = %d", len(buildIn.provided)))
+	}
 
 	// remove and invoke destroy on anything where passNum doesn't match
 	for k, st := range e.compStateMap {
 		if st.passNum != e.passNum {
-			invokeDestroy(k, e.eventEnv)
+			invokeDestroy(k, e.eventEnv, st.cancel)
 			delete(e.compStateMap, k)
+			delete(e.rebuild, k)
 		}
 	}
 
-	return &BuildResults{allOut: e.buildResults, Out: e.buildResults[makeBuildCacheKey(builder)]}
+	e.stats = BuildStats{
+		Start:       start,
+		Duration:    time.Since(start),
+		Components:  len(e.buildResults),
+		CacheHits:   len(e.reused),
+		CacheMisses: len(e.buildResults) - len(e.reused),
+	}
+	if e.tracer != nil {
+		e.tracer.BuildDone(e.stats)
+	}
+
+	return &BuildResults{allOut: e.buildResults, reused: e.reused, Out: e.buildResults[makeBuildCacheKey(builder)]}
 }
 
 func (e *BuildEnv) buildOne(buildIn *BuildIn, thisb Builder) {
 
+	var start time.Time
+	if e.tracer != nil {
+		start = time.Now()
+	}
+
+	e.entered = append(e.entered, thisb)
+
 	st, ok := e.compStateMap[thisb]
 	if !ok {
-		invokeInit(thisb, e.eventEnv)
+		st.cancel = invokeInit(thisb, e.eventEnv)
 	}
 	st.passNum = e.passNum
 	e.compStateMap[thisb] = st
@@ -141,12 +265,158 @@ func (e *BuildEnv) buildOne(buildIn *BuildIn, thisb Builder) {
 		invokeCompute(thisb, e.eventEnv)
 	}
 
-	buildOut := thisb.Build(buildIn)
+	cacheKey := makeBuildCacheKey(thisb)
+
+	// values provided by this component are only visible to its subtree
+	providedLen := len(buildIn.provided)
+	defer func() {
+		buildIn.provided = buildIn.provided[:providedLen]
+	}()
+
+	// components which opt into memoization and are not modified reuse the BuildOut from the prior
+	// pass instead of building again
+	var buildOut *BuildOut
+	if e.unmodified(thisb) {
+		buildOut = e.buildCache[cacheKey]
+	}
+
+	if buildOut != nil {
+		e.reused[cacheKey] = true
+		// the child components were looked up during the prior Build, repeat that so they stay cached
+		for _, u := range e.compUsesOld[cacheKey] {
+			e.UseComponent(u.key, u.comp)
+		}
+		e.compUses[cacheKey] = e.compUsesOld[cacheKey]
+		// and provide the same values to the subtree
+		buildIn.provided = append(buildIn.provided, e.providesOld[cacheKey]...)
+		e.provides[cacheKey] = e.providesOld[cacheKey]
+	} else {
+		e.building = thisb
+		buildOut = thisb.Build(buildIn)
+		e.building = nil
+		// a component which returns the same BuildOut as in the prior pass has the same output
+		if prior := e.buildCache[cacheKey]; prior != nil && prior == buildOut {
+			e.reused[cacheKey] = true
+		}
+	}
 
 	// store in buildResults
-	e.buildResults[makeBuildCacheKey(thisb)] = buildOut
+	e.buildResults[cacheKey] = buildOut
+
+	if e.tracer != nil {
+		e.tracer.ComponentBuilt(ComponentBuildStats{
+			Component: thisb,
+			Start:     start,
+			Duration:  time.Since(start),
+			CacheHit:  e.reused[cacheKey],
+		})
+	}
+
+	eb, ok := thisb.(ErrorBoundary)
+	if !ok {
+		e.buildComponents(buildIn, buildOut.Components)
+		return
+	}
+
+	berr := e.tryBuildComponents(buildIn, buildOut.Components)
+	if berr == nil {
+		return
+	}
+
+	if e.errorHook != nil {
+		e.errorHook(berr)
+	}
+	eb.BuildError(berr)
+
+	// build again so the boundary can output its fallback, starting over with what it provides and uses
+	buildIn.provided = buildIn.provided[:providedLen]
+	delete(e.compUses, cacheKey)
+	delete(e.provides, cacheKey)
+	delete(e.reused, cacheKey)
+	e.building = thisb
+	buildOut = thisb.Build(buildIn)
+	e.building = nil
+	e.buildResults[cacheKey] = buildOut
+
+	e.buildComponents(buildIn, buildOut.Components)
+}
+
+// unmodified returns true if c opted into memoization with fields tagged `vugu:"cparam"` or `vugu:"modcheck"`,
+// reports no modification and no rebuild was requested for it.  Implementing ModChecker is not enough to opt in,
+// components did that (e.g. by embedding ChangeCounter) before memoization existed and expect to be built every pass.
+// The check is done every pass so the ModTracker stays current.
+func (e *BuildEnv) unmodified(c Builder) bool {
+	fields := e.memoFields(c)
+	if len(fields) == 0 {
+		return false
+	}
+	mod := e.modTracker.ModCheckAll(fields...)
+	if e.rebuild[c] {
+		delete(e.rebuild, c)
+		return false
+	}
+	return !mod && !e.rebuildingAll
+}
+
+// memoFields returns pointers to the exported fields of c tagged `vugu:"cparam"` or `vugu:"modcheck"`, if c is a struct pointer.
+// Unexported fields cannot be addressed through reflection from this package, so they are skipped even if tagged.
+func (e *BuildEnv) memoFields(c Builder) []any {
+	rv := reflect.ValueOf(c)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	rv = rv.Elem()
+	t := rv.Type()
+
+	idxs, ok := e.memoFieldsByType[t]
+	if !ok {
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			tag := t.Field(i).Tag.Get("vugu")
+			for _, part := range strings.Split(tag, ",") {
+				if part == "cparam" || part == "modcheck" {
+					idxs = append(idxs, i)
+					break
+				}
+			}
+		}
+		if e.memoFieldsByType == nil {
+			e.memoFieldsByType = make(map[reflect.Type][]int)
+		}
+		e.memoFieldsByType[t] = idxs
+	}
+
+	if len(idxs) == 0 {
+		return nil
+	}
+	ret := make([]any, len(idxs))
+	for i, idx := range idxs {
+		ret[i] = rv.Field(idx).Addr().Interface()
+	}
+	return ret
+}
+
+// Rebuild makes the next build pass call Build on component even if it is unmodified, for memoized components
+// whose output depends on something other than what is checked for modification.  Memoized components can
+// also embed a ChangeCounter in a field tagged `vugu:"modcheck"` and call Changed on it instead.
+func (e *BuildEnv) Rebuild(component Builder) {
+	if e.rebuild == nil {
+		e.rebuild = make(map[Builder]bool)
+	}
+	e.rebuild[component] = true
+}
+
+// RebuildAll makes the next build pass call Build on every component, see Rebuild.
+func (e *BuildEnv) RebuildAll() {
+	e.rebuildAll = true
+}
 
-	if len(buildOut.Components) == 0 {
+// buildComponents calls buildOne on each of the components with the position hash for each
+func (e *BuildEnv) buildComponents(buildIn *BuildIn, comps []Builder) {
+
+	if len(comps) == 0 {
 		return
 	}
 
@@ -157,7 +427,7 @@ func (e *BuildEnv) buildOne(buildIn *BuildIn, thisb Builder) {
 		buildIn.PositionHashList = buildIn.PositionHashList[:len(buildIn.PositionHashList)-1]
 	}()
 
-	for _, c := range buildOut.Components {
+	for _, c := range comps {
 
 		e.buildOne(buildIn, c)
 
@@ -190,6 +460,10 @@ func (e *BuildEnv) CachedComponent(compKey CompKey) Builder {
 func (e *BuildEnv) UseComponent(compKey CompKey, component Builder) {
 	delete(e.compCache, compKey)    // make sure it's not in the cache
 	e.compUsed[compKey] = component // make sure it is in the used
+	if e.building != nil {
+		k := makeBuildCacheKey(e.building)
+		e.compUses[k] = append(e.compUses[k], compUse{key: compKey, comp: component})
+	}
 }
 
 // SetWireFunc assigns the function to be called by WireComponent.
@@ -224,6 +498,7 @@ func hashVals(vs ...uint64) uint64 {
 
 type compState struct {
 	passNum uint8
+	cancel  context.CancelFunc // cancels the context given to Init, nil if it has none
 	// TODO: flags?
 }
 
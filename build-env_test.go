@@ -1,6 +1,8 @@
 package vugu
 
 import (
+	"context"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -67,3 +69,275 @@ func (b *testb1) Build(in *BuildIn) (out *BuildOut) {
 		Out: []*VGNode{},
 	}
 }
+
+func TestBuildEnvReuseUnmodified(t *testing.T) {
+
+	assert := assert.New(t)
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+
+	root := &reuseRoot{text: "a"}
+
+	res := be.RunBuild(root)
+	assert.False(res.Reused(root))
+	child := root.child
+	assert.NotNil(child)
+
+	// the same BuildOut is returned, so it is reused
+	res2 := be.RunBuild(root)
+	assert.True(res2.Reused(root))
+	assert.Same(res.Out, res2.Out)
+	built, reused := res2.Counts()
+	assert.Equal(2, built)
+	assert.Equal(1, reused)
+	stats := be.LastBuildStats()
+	assert.Equal(2, stats.Components)
+	assert.Equal(1, stats.CacheHits)
+	assert.Equal(1, stats.CacheMisses)
+
+	// a new BuildOut is not, and the child is still the same one
+	root.text = "b"
+	res3 := be.RunBuild(root)
+	assert.False(res3.Reused(root))
+	assert.NotSame(res.Out, res3.Out)
+	assert.Same(child, root.child)
+}
+
+// reuseRoot returns the BuildOut from its last Build while text is the same
+type reuseRoot struct {
+	text     string
+	lastText string
+	out      *BuildOut
+	child    *testb1
+}
+
+func (b *reuseRoot) Build(in *BuildIn) (out *BuildOut) {
+	key := MakeCompKey(42, nil)
+	b.child, _ = in.BuildEnv.CachedComponent(key).(*testb1)
+	if b.child == nil {
+		b.child = &testb1{}
+	}
+	in.BuildEnv.UseComponent(key, b.child)
+	if b.out != nil && b.lastText == b.text {
+		return b.out
+	}
+	n := &VGNode{Type: ElementNode, Data: "div"}
+	n.AppendChild(&VGNode{Type: TextNode, Data: b.text})
+	n.AppendChild(&VGNode{Component: b.child})
+	b.out, b.lastText = &BuildOut{Out: []*VGNode{n}, Components: []Builder{b.child}}, b.text
+	return b.out
+}
+
+// memoComp is memoized with tagged fields
+type memoComp struct {
+	Name      string        `vugu:"cparam"`
+	Count     ChangeCounter `vugu:"modcheck"`
+	Unrelated string
+	builds    int
+}
+
+func (c *memoComp) Build(in *BuildIn) (out *BuildOut) {
+	c.builds++
+	return &BuildOut{Out: []*VGNode{{Type: ElementNode, Data: "p"}}}
+}
+
+func TestBuildEnvMemoFields(t *testing.T) {
+
+	assert := assert.New(t)
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+
+	c := &memoComp{Name: "a"}
+	root := &panicComp{children: []Builder{c}}
+
+	be.RunBuild(root)
+	assert.Equal(1, c.builds)
+
+	res := be.RunBuild(root)
+	assert.Equal(1, c.builds)
+	assert.True(res.Reused(c))
+
+	// untagged fields are ignored
+	c.Unrelated = "x"
+	be.RunBuild(root)
+	assert.Equal(1, c.builds)
+
+	c.Name = "b"
+	be.RunBuild(root)
+	assert.Equal(2, c.builds)
+
+	c.Count.Changed()
+	be.RunBuild(root)
+	assert.Equal(3, c.builds)
+
+	be.Rebuild(c)
+	be.RunBuild(root)
+	assert.Equal(4, c.builds)
+	be.RunBuild(root)
+	assert.Equal(4, c.builds)
+
+	be.RebuildAll()
+	be.RunBuild(root)
+	assert.Equal(5, c.builds)
+	be.RunBuild(root)
+	assert.Equal(5, c.builds)
+}
+
+// memoUnexported has a tagged unexported field, which is skipped
+type memoUnexported struct {
+	Name   string `vugu:"cparam"`
+	state  string `vugu:"modcheck"`
+	builds int
+}
+
+func (c *memoUnexported) Build(in *BuildIn) (out *BuildOut) {
+	c.builds++
+	return &BuildOut{Out: []*VGNode{{Type: ElementNode, Data: "p", Attr: []VGAttribute{{Key: "title", Val: c.state}}}}}
+}
+
+func TestBuildEnvMemoUnexported(t *testing.T) {
+
+	assert := assert.New(t)
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+
+	c := &memoUnexported{Name: "a"}
+	root := &panicComp{children: []Builder{c}}
+
+	be.RunBuild(root)
+	be.RunBuild(root)
+	assert.Equal(1, c.builds)
+
+	// only the exported field is checked
+	c.state = "x"
+	be.RunBuild(root)
+	assert.Equal(1, c.builds)
+	c.Name = "b"
+	be.RunBuild(root)
+	assert.Equal(2, c.builds)
+}
+
+func TestBuildEnvMemoChildren(t *testing.T) {
+
+	assert := assert.New(t)
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+
+	root := &modRoot{}
+
+	res := be.RunBuild(root)
+	assert.Equal(1, root.builds)
+	child := root.child
+
+	// not modified, so Build is skipped and the child component stays cached
+	res2 := be.RunBuild(root)
+	assert.Equal(1, root.builds)
+	assert.True(res2.Reused(root))
+	assert.Same(res.Out, res2.Out)
+
+	// after being reused it must still be possible to get the same child
+	root.Changed()
+	be.RunBuild(root)
+	assert.Equal(2, root.builds)
+	assert.Same(child, root.child)
+}
+
+type modRoot struct {
+	ChangeCounter `vugu:"modcheck"`
+	builds        int
+	child         *testb1
+}
+
+func (b *modRoot) Build(in *BuildIn) (out *BuildOut) {
+	b.builds++
+	key := MakeCompKey(42, nil)
+	b.child, _ = in.BuildEnv.CachedComponent(key).(*testb1)
+	if b.child == nil {
+		b.child = &testb1{}
+	}
+	in.BuildEnv.UseComponent(key, b.child)
+	n := &VGNode{Type: ElementNode, Data: "div"}
+	n.AppendChild(&VGNode{Component: b.child})
+	return &BuildOut{Out: []*VGNode{n}, Components: []Builder{b.child}}
+}
+
+func TestBuildEnvModCheckerNotMemoized(t *testing.T) {
+
+	assert := assert.New(t)
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+
+	// implementing ModChecker without tagged fields does not opt into memoization
+	root := &plainModRoot{}
+	be.RunBuild(root)
+	res := be.RunBuild(root)
+	assert.Equal(2, root.builds)
+	assert.False(res.Reused(root))
+}
+
+type plainModRoot struct {
+	ChangeCounter
+	builds int
+}
+
+func (b *plainModRoot) Build(in *BuildIn) (out *BuildOut) {
+	b.builds++
+	return &BuildOut{Out: []*VGNode{{Type: ElementNode, Data: "div"}}}
+}
+
+// ctxComp keeps the InitCtx so the test can use it after Init
+type ctxComp struct {
+	initCtx   InitCtx
+	destroyed bool
+	val       int
+}
+
+func (c *ctxComp) Init(ctx InitCtx) { c.initCtx = ctx }
+
+func (c *ctxComp) Destroy(ctx DestroyCtx) {
+	// the context is already cancelled when Destroy is called
+	c.destroyed = c.initCtx.Context().Err() != nil
+}
+
+func (c *ctxComp) Build(in *BuildIn) (out *BuildOut) {
+	return &BuildOut{Out: []*VGNode{{Type: ElementNode, Data: "p"}}}
+}
+
+func TestBuildEnvInitContext(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var rwmu sync.RWMutex
+	renderCH := make(chan bool, 8)
+	be, err := NewBuildEnv(NewEventEnvImpl(&rwmu, renderCH))
+	assert.NoError(err)
+
+	c := &ctxComp{}
+	root := &panicComp{children: []Builder{c}}
+	be.RunBuild(root)
+	assert.NotNil(c.initCtx)
+	assert.NoError(c.initCtx.Context().Err())
+
+	// Update from a goroutine while the component is alive
+	done := make(chan bool)
+	c.initCtx.Go(func(ctx context.Context) {
+		done <- c.initCtx.Update(func() { c.val = 1 })
+	})
+	assert.True(<-done)
+	assert.Equal(1, c.val)
+	assert.Len(renderCH, 1)
+
+	// build without it, it is destroyed and further updates are dropped
+	root.children = nil
+	be.RunBuild(root)
+	assert.True(c.destroyed)
+	assert.Error(c.initCtx.Context().Err())
+	assert.False(c.initCtx.Update(func() { c.val = 2 }))
+	assert.Equal(1, c.val)
+	assert.Len(renderCH, 1)
+}
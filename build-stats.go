@@ -0,0 +1,38 @@
+package vugu
+
+import (
+	"time"
+)
+
+// BuildStats describes a single build pass, see BuildEnv.LastBuildStats.
+type BuildStats struct {
+	Start       time.Time
+	Duration    time.Duration
+	Components  int // components in the build
+	CacheHits   int // components whose BuildOut was the same as in the prior pass, see BuildResults.Reused
+	CacheMisses int // components with a new BuildOut
+}
+
+// ComponentBuildStats describes the build of one component during a pass.
+type ComponentBuildStats struct {
+	Component Builder
+	Start     time.Time
+	Duration  time.Duration // time spent on this component (lifecycle callbacks and Build), not including its child components
+	CacheHit  bool          // the BuildOut from the prior pass was reused
+}
+
+// BuildTracer receives timings from a BuildEnv, see BuildEnv.SetTracer.
+type BuildTracer interface {
+	ComponentBuilt(stats ComponentBuildStats) // called for each component as it is built
+	BuildDone(stats BuildStats)               // called at the end of each pass
+}
+
+// SetTracer sets a BuildTracer which is called during each build pass.  Pass nil to remove it.
+func (e *BuildEnv) SetTracer(t BuildTracer) {
+	e.tracer = t
+}
+
+// LastBuildStats returns the stats for the most recent build pass.
+func (e *BuildEnv) LastBuildStats() BuildStats {
+	return e.stats
+}
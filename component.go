@@ -12,6 +12,9 @@ type BuildIn struct {
 
 	// a stack of position hashes, the last one can be used by a component to get a unique hash for overall position
 	PositionHashList []uint64
+
+	// values provided by components above this one, see Provide and Inject
+	provided []providedVal
 }
 
 // CurrentPositionHash returns the hash value that can be used by a component to
@@ -125,3 +125,16 @@ func (cm *callbackManager) callback(this js.Value, args []js.Value) any {
 
 	return nil
 }
+
+// refHandler assigns the element for vg-ref (see vugu.VGNode.Ref) and then calls the vg-js-create handler, if any
+type refHandler struct {
+	ref  *js.Element
+	next vugu.JSValueHandler
+}
+
+func (h refHandler) JSValueHandle(v js.Value) {
+	*h.ref = js.ElementOf(v)
+	if h.next != nil {
+		h.next.JSValueHandle(v)
+	}
+}
@@ -0,0 +1,375 @@
+// Package ildecode decodes the binary instructions that domrender sends to its JS side, so they can be
+// inspected in tests and when debugging.  Decode returns a typed value for each instruction, e.g.
+// SetElement{NodeName: "div"}, and Disassemble formats them one per line:
+//
+//	SelectMountPoint Selector="#app" NodeName="div"
+//	MoveToFirstChild
+//	SetElement NodeName="span"
+//	SetAttrStr Name="class" Value="title"
+//
+// To capture what is sent for each render use a Recorder with domrender.JSRenderer.SetInstructionRecorder.
+// This package is not used by domrender itself, so it does not add to the size of a program that doesn't use it.
+package ildecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Instruction is one decoded instruction, it is one of the types in this package named after its opcode.
+type Instruction interface {
+	Opcode() Opcode
+}
+
+// ClearEl unsets the current element.
+type ClearEl struct{}
+
+// RemoveOtherAttrs removes the attributes of the current element that were not just set.
+type RemoveOtherAttrs struct{}
+
+// SetAttrStr sets an attribute on the current element.
+type SetAttrStr struct{ Name, Value string }
+
+// SelectMountPoint selects the mount point element.
+type SelectMountPoint struct{ Selector, NodeName string }
+
+// MoveToFirstChild moves the node selection to the first child.
+type MoveToFirstChild struct{}
+
+// SetElement makes the current node an element with NodeName.
+type SetElement struct{ NodeName string }
+
+// SetText makes the current node a text node.
+type SetText struct{ Text string }
+
+// SetComment makes the current node a comment.
+type SetComment struct{ Comment string }
+
+// MoveToParent moves the node selection to the parent, removing the siblings after the current node.
+type MoveToParent struct{}
+
+// MoveToNextSibling moves the node selection to the next sibling.
+type MoveToNextSibling struct{}
+
+// RemoveOtherEventListeners removes the event listeners of the current element that were not just set.
+type RemoveOtherEventListeners struct{ PositionID string }
+
+// SetEventListener sets an event listener on the current element.
+type SetEventListener struct {
+	PositionID string
+	EventType  string
+	Capture    bool
+	Passive    bool
+	Flags      uint8 // the modifiers preventDefault, stopPropagation, once and self
+	Keys       string
+}
+
+// SetInnerHTML sets the inner HTML of the current element, after any BufferInnerHTML before it.
+type SetInnerHTML struct{ HTML string }
+
+// SetCSSTag writes a style or link tag.
+type SetCSSTag struct {
+	ElementName string
+	TextContent string
+	AttrPairs   []string // key, value, key, value...
+}
+
+// RemoveOtherCSSTags removes the CSS tags which were not written since the last RemoveOtherCSSTags.
+type RemoveOtherCSSTags struct{}
+
+// RemoveOtherJSTags removes the JS tags which were not written since the last RemoveOtherJSTags.
+type RemoveOtherJSTags struct{}
+
+// SetProperty sets a JS property on the current element.
+type SetProperty struct{ Key, JSONValue string }
+
+// SelectQuery selects the element matching a selector.
+type SelectQuery struct{ Selector string }
+
+// BufferInnerHTML is part of the HTML for the following SetInnerHTML.
+type BufferInnerHTML struct{ HTML string }
+
+// SetAttrNSStr sets an attribute with a namespace on the current element.
+type SetAttrNSStr struct{ Namespace, Name, Value string }
+
+// SetElementNS makes the current node an element with NodeName in Namespace.
+type SetElementNS struct{ NodeName, Namespace string }
+
+// Callback calls back to Go.
+type Callback struct{ CallbackID uint32 }
+
+// CallbackLastElement calls back to Go with the current element.
+type CallbackLastElement struct{ CallbackID uint32 }
+
+// HydrateExpect checks the node about to be synced when hydrating.
+type HydrateExpect struct {
+	PositionID string
+	NodeType   uint8 // the DOM nodeType
+	NodeName   string
+}
+
+// RemoveKeyed removes the child of the current element with Key.
+type RemoveKeyed struct{ Key string }
+
+// MoveKeyed moves the child with Key into the position about to be synced.
+type MoveKeyed struct{ Key string }
+
+// SkipNode selects the next node without changing it.
+type SkipNode struct{}
+
+// SelectPortal selects the element holding a portal's children in its target.
+type SelectPortal struct{ Selector, PortalID string }
+
+// RemovePortal removes the element holding a portal's children from its target.
+type RemovePortal struct{ Selector, PortalID string }
+
+// RemoveAttr removes an attribute from the current element, Namespace is empty for an attribute without one.
+type RemoveAttr struct{ Namespace, Name string }
+
+// SkipNodes selects the next node and Count-1 siblings after it without changing them.
+type SkipNodes struct{ Count uint32 }
+
+// SetDelegatedEvents sets the listeners of the current element which are called from a listener at the
+// mount point.  Events has an event key ("type|capture|passive|flags:keys") per line.
+type SetDelegatedEvents struct{ PositionID, Events string }
+
+func (ClearEl) Opcode() Opcode                   { return OpClearEl }
+func (RemoveOtherAttrs) Opcode() Opcode          { return OpRemoveOtherAttrs }
+func (SetAttrStr) Opcode() Opcode                { return OpSetAttrStr }
+func (SelectMountPoint) Opcode() Opcode          { return OpSelectMountPoint }
+func (MoveToFirstChild) Opcode() Opcode          { return OpMoveToFirstChild }
+func (SetElement) Opcode() Opcode                { return OpSetElement }
+func (SetText) Opcode() Opcode                   { return OpSetText }
+func (SetComment) Opcode() Opcode                { return OpSetComment }
+func (MoveToParent) Opcode() Opcode              { return OpMoveToParent }
+func (MoveToNextSibling) Opcode() Opcode         { return OpMoveToNextSibling }
+func (RemoveOtherEventListeners) Opcode() Opcode { return OpRemoveOtherEventListeners }
+func (SetEventListener) Opcode() Opcode          { return OpSetEventListener }
+func (SetInnerHTML) Opcode() Opcode              { return OpSetInnerHTML }
+func (SetCSSTag) Opcode() Opcode                 { return OpSetCSSTag }
+func (RemoveOtherCSSTags) Opcode() Opcode        { return OpRemoveOtherCSSTags }
+func (RemoveOtherJSTags) Opcode() Opcode         { return OpRemoveOtherJSTags }
+func (SetProperty) Opcode() Opcode               { return OpSetProperty }
+func (SelectQuery) Opcode() Opcode               { return OpSelectQuery }
+func (BufferInnerHTML) Opcode() Opcode           { return OpBufferInnerHTML }
+func (SetAttrNSStr) Opcode() Opcode              { return OpSetAttrNSStr }
+func (SetElementNS) Opcode() Opcode              { return OpSetElementNS }
+func (Callback) Opcode() Opcode                  { return OpCallback }
+func (CallbackLastElement) Opcode() Opcode       { return OpCallbackLastElement }
+func (HydrateExpect) Opcode() Opcode             { return OpHydrateExpect }
+func (RemoveKeyed) Opcode() Opcode               { return OpRemoveKeyed }
+func (MoveKeyed) Opcode() Opcode                 { return OpMoveKeyed }
+func (SkipNode) Opcode() Opcode                  { return OpSkipNode }
+func (SelectPortal) Opcode() Opcode              { return OpSelectPortal }
+func (RemovePortal) Opcode() Opcode              { return OpRemovePortal }
+func (RemoveAttr) Opcode() Opcode                { return OpRemoveAttr }
+func (SkipNodes) Opcode() Opcode                 { return OpSkipNodes }
+func (SetDelegatedEvents) Opcode() Opcode        { return OpSetDelegatedEvents }
+
+// ErrShort is returned (wrapped) when the buffer ends in the middle of an instruction.
+var ErrShort = errors.New("instruction buffer ends in the middle of an instruction")
+
+// Decode decodes the instructions in b, up to an End opcode or the end of b.
+// If there is an error the instructions decoded before it are also returned.
+func Decode(b []byte) ([]Instruction, error) {
+
+	d := decoder{b: b}
+	var ret []Instruction
+
+	for d.pos < len(d.b) {
+		start := d.pos
+		op := Opcode(d.uint8())
+		if op == OpEnd {
+			break
+		}
+
+		var in Instruction
+		switch op {
+		case OpClearEl:
+			in = ClearEl{}
+		case OpRemoveOtherAttrs:
+			in = RemoveOtherAttrs{}
+		case OpSetAttrStr:
+			in = SetAttrStr{Name: d.string(), Value: d.string()}
+		case OpSelectMountPoint:
+			in = SelectMountPoint{Selector: d.string(), NodeName: d.string()}
+		case OpMoveToFirstChild:
+			in = MoveToFirstChild{}
+		case OpSetElement:
+			in = SetElement{NodeName: d.string()}
+		case OpSetText:
+			in = SetText{Text: d.string()}
+		case OpSetComment:
+			in = SetComment{Comment: d.string()}
+		case OpMoveToParent:
+			in = MoveToParent{}
+		case OpMoveToNextSibling:
+			in = MoveToNextSibling{}
+		case OpRemoveOtherEventListeners:
+			in = RemoveOtherEventListeners{PositionID: d.string()}
+		case OpSetEventListener:
+			in = SetEventListener{PositionID: d.string(), EventType: d.string(), Capture: d.uint8() != 0,
+				Passive: d.uint8() != 0, Flags: d.uint8(), Keys: d.string()}
+		case OpSetInnerHTML:
+			in = SetInnerHTML{HTML: d.string()}
+		case OpSetCSSTag:
+			t := SetCSSTag{ElementName: d.string(), TextContent: d.string()}
+			n := int(d.uint8())
+			for i := 0; i < n && d.err == nil; i++ {
+				t.AttrPairs = append(t.AttrPairs, d.string())
+			}
+			in = t
+		case OpRemoveOtherCSSTags:
+			in = RemoveOtherCSSTags{}
+		case OpRemoveOtherJSTags:
+			in = RemoveOtherJSTags{}
+		case OpSetProperty:
+			in = SetProperty{Key: d.string(), JSONValue: d.string()}
+		case OpSelectQuery:
+			in = SelectQuery{Selector: d.string()}
+		case OpBufferInnerHTML:
+			in = BufferInnerHTML{HTML: d.string()}
+		case OpSetAttrNSStr:
+			in = SetAttrNSStr{Namespace: d.string(), Name: d.string(), Value: d.string()}
+		case OpSetElementNS:
+			in = SetElementNS{NodeName: d.string(), Namespace: d.string()}
+		case OpCallback:
+			in = Callback{CallbackID: d.uint32()}
+		case OpCallbackLastElement:
+			in = CallbackLastElement{CallbackID: d.uint32()}
+		case OpHydrateExpect:
+			in = HydrateExpect{PositionID: d.string(), NodeType: d.uint8(), NodeName: d.string()}
+		case OpRemoveKeyed:
+			in = RemoveKeyed{Key: d.string()}
+		case OpMoveKeyed:
+			in = MoveKeyed{Key: d.string()}
+		case OpSkipNode:
+			in = SkipNode{}
+		case OpSelectPortal:
+			in = SelectPortal{Selector: d.string(), PortalID: d.string()}
+		case OpRemovePortal:
+			in = RemovePortal{Selector: d.string(), PortalID: d.string()}
+		case OpRemoveAttr:
+			in = RemoveAttr{Namespace: d.string(), Name: d.string()}
+		case OpSkipNodes:
+			in = SkipNodes{Count: d.uint32()}
+		case OpSetDelegatedEvents:
+			in = SetDelegatedEvents{PositionID: d.string(), Events: d.string()}
+		case OpSetJSTag:
+			return ret, fmt.Errorf("%v at offset %d is not supported", op, start)
+		default:
+			return ret, fmt.Errorf("unknown opcode %d at offset %d", op, start)
+		}
+
+		if d.err != nil {
+			return ret, fmt.Errorf("%v at offset %d: %w", op, start, d.err)
+		}
+		ret = append(ret, in)
+	}
+
+	return ret, nil
+}
+
+// decoder reads the values in an instruction, after an error it returns zero values
+type decoder struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.b)-d.pos < n {
+		d.err = ErrShort
+		return nil
+	}
+	ret := d.b[d.pos : d.pos+n]
+	d.pos += n
+	return ret
+}
+
+func (d *decoder) uint8() uint8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) string() string {
+	return string(d.next(int(d.uint32())))
+}
+
+// Format returns the instruction as its opcode name followed by its fields, e.g. `SetElement NodeName="div"`.
+func Format(in Instruction) string {
+	var sb strings.Builder
+	sb.WriteString(in.Opcode().String())
+	v := reflect.ValueOf(in)
+	for i := 0; i < v.NumField(); i++ {
+		switch f := v.Field(i).Interface().(type) {
+		case string:
+			fmt.Fprintf(&sb, " %s=%q", v.Type().Field(i).Name, f)
+		case []string:
+			fmt.Fprintf(&sb, " %s=%q", v.Type().Field(i).Name, f)
+		default:
+			fmt.Fprintf(&sb, " %s=%v", v.Type().Field(i).Name, f)
+		}
+	}
+	return sb.String()
+}
+
+// Disassemble decodes the instructions in b and returns them formatted with Format, one per line.
+// If there is an error the instructions before it are also returned.
+func Disassemble(b []byte) (string, error) {
+	ins, err := Decode(b)
+	var sb strings.Builder
+	for _, in := range ins {
+		sb.WriteString(Format(in))
+		sb.WriteByte('\n')
+	}
+	return sb.String(), err
+}
+
+// Recorder keeps a copy of the instructions a renderer sends, it implements domrender.InstructionRecorder.
+// The zero value is ready to use.
+type Recorder struct {
+	buf []byte
+}
+
+// RecordInstructions implements domrender.InstructionRecorder.
+func (r *Recorder) RecordInstructions(b []byte) {
+	r.buf = append(r.buf, b...)
+}
+
+// Bytes returns the instructions recorded since the last Reset.
+func (r *Recorder) Bytes() []byte {
+	return r.buf
+}
+
+// Instructions decodes the instructions recorded since the last Reset.
+func (r *Recorder) Instructions() ([]Instruction, error) {
+	return Decode(r.buf)
+}
+
+// Disassemble returns the instructions recorded since the last Reset, see Disassemble.
+func (r *Recorder) Disassemble() (string, error) {
+	return Disassemble(r.buf)
+}
+
+// Reset discards the recorded instructions, usually before each render.
+func (r *Recorder) Reset() {
+	r.buf = r.buf[:0]
+}
@@ -0,0 +1,78 @@
+package ildecode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+
+	assert := assert.New(t)
+
+	b := []byte{
+		21, 0, 0, 0, 3, 'd', 'i', 'v', // SetElement
+		6, 0, 0, 0, 2, 'i', 'd', 0, 0, 0, 1, 'x', // SetAttrStr
+		20,             // MoveToFirstChild
+		49, 0, 0, 1, 0, // SkipNodes
+		41, 0, 0, 0, 7, // CallbackLastElement
+		0,                   // End
+		23, 0, 0, 0, 1, 'z', // after the end, ignored
+	}
+
+	ins, err := Decode(b)
+	assert.NoError(err)
+	assert.Equal([]Instruction{
+		SetElement{NodeName: "div"},
+		SetAttrStr{Name: "id", Value: "x"},
+		MoveToFirstChild{},
+		SkipNodes{Count: 256},
+		CallbackLastElement{CallbackID: 7},
+	}, ins)
+
+	out, err := Disassemble(b)
+	assert.NoError(err)
+	assert.Equal(`SetElement NodeName="div"
+SetAttrStr Name="id" Value="x"
+MoveToFirstChild
+SkipNodes Count=256
+CallbackLastElement CallbackID=7
+`, out)
+
+	// cut off in the middle of the attribute value
+	ins, err = Decode(b[:18])
+	assert.True(errors.Is(err, ErrShort))
+	assert.EqualError(err, "SetAttrStr at offset 8: "+ErrShort.Error())
+	assert.Equal([]Instruction{SetElement{NodeName: "div"}}, ins)
+
+	_, err = Decode([]byte{20, 200})
+	assert.EqualError(err, "unknown opcode 200 at offset 1")
+}
+
+func TestFormat(t *testing.T) {
+
+	assert := assert.New(t)
+
+	assert.Equal(`SetEventListener PositionID="0_1" EventType="click" Capture=false Passive=true Flags=1 Keys=""`,
+		Format(SetEventListener{PositionID: "0_1", EventType: "click", Passive: true, Flags: 1}))
+	assert.Equal(`SetCSSTag ElementName="link" TextContent="" AttrPairs=["rel" "stylesheet"]`,
+		Format(SetCSSTag{ElementName: "link", AttrPairs: []string{"rel", "stylesheet"}}))
+	assert.Equal("SetElement", OpSetElement.String())
+	assert.Equal("Opcode(2)", Opcode(2).String())
+}
+
+func TestRecorder(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var r Recorder
+	r.RecordInstructions([]byte{20})
+	r.RecordInstructions([]byte{45, 25})
+	ins, err := r.Instructions()
+	assert.NoError(err)
+	assert.Equal([]Instruction{MoveToFirstChild{}, SkipNode{}, MoveToParent{}}, ins)
+
+	r.Reset()
+	assert.Empty(r.Bytes())
+}
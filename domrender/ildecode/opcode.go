@@ -0,0 +1,90 @@
+package ildecode
+
+import "strconv"
+
+// Opcode is the first byte of each instruction.
+type Opcode uint8
+
+// The opcodes, these must match renderer-js-instructions.go and renderer-js-script.js in domrender.
+// OpSetJSTag is reserved but never written, Decode returns an error for it.
+const (
+	OpEnd                       Opcode = 0
+	OpClearEl                   Opcode = 1
+	OpRemoveOtherAttrs          Opcode = 5
+	OpSetAttrStr                Opcode = 6
+	OpSelectMountPoint          Opcode = 7
+	OpMoveToFirstChild          Opcode = 20
+	OpSetElement                Opcode = 21
+	OpSetText                   Opcode = 23
+	OpSetComment                Opcode = 24
+	OpMoveToParent              Opcode = 25
+	OpMoveToNextSibling         Opcode = 26
+	OpRemoveOtherEventListeners Opcode = 27
+	OpSetEventListener          Opcode = 28
+	OpSetInnerHTML              Opcode = 29
+	OpSetCSSTag                 Opcode = 30
+	OpRemoveOtherCSSTags        Opcode = 31
+	OpSetJSTag                  Opcode = 32
+	OpRemoveOtherJSTags         Opcode = 33
+	OpSetProperty               Opcode = 35
+	OpSelectQuery               Opcode = 36
+	OpBufferInnerHTML           Opcode = 37
+	OpSetAttrNSStr              Opcode = 38
+	OpSetElementNS              Opcode = 39
+	OpCallback                  Opcode = 40
+	OpCallbackLastElement       Opcode = 41
+	OpHydrateExpect             Opcode = 42
+	OpRemoveKeyed               Opcode = 43
+	OpMoveKeyed                 Opcode = 44
+	OpSkipNode                  Opcode = 45
+	OpSelectPortal              Opcode = 46
+	OpRemovePortal              Opcode = 47
+	OpRemoveAttr                Opcode = 48
+	OpSkipNodes                 Opcode = 49
+	OpSetDelegatedEvents        Opcode = 50
+)
+
+var opcodeNames = map[Opcode]string{
+	OpEnd:                       "End",
+	OpClearEl:                   "ClearEl",
+	OpRemoveOtherAttrs:          "RemoveOtherAttrs",
+	OpSetAttrStr:                "SetAttrStr",
+	OpSelectMountPoint:          "SelectMountPoint",
+	OpMoveToFirstChild:          "MoveToFirstChild",
+	OpSetElement:                "SetElement",
+	OpSetText:                   "SetText",
+	OpSetComment:                "SetComment",
+	OpMoveToParent:              "MoveToParent",
+	OpMoveToNextSibling:         "MoveToNextSibling",
+	OpRemoveOtherEventListeners: "RemoveOtherEventListeners",
+	OpSetEventListener:          "SetEventListener",
+	OpSetInnerHTML:              "SetInnerHTML",
+	OpSetCSSTag:                 "SetCSSTag",
+	OpRemoveOtherCSSTags:        "RemoveOtherCSSTags",
+	OpSetJSTag:                  "SetJSTag",
+	OpRemoveOtherJSTags:         "RemoveOtherJSTags",
+	OpSetProperty:               "SetProperty",
+	OpSelectQuery:               "SelectQuery",
+	OpBufferInnerHTML:           "BufferInnerHTML",
+	OpSetAttrNSStr:              "SetAttrNSStr",
+	OpSetElementNS:              "SetElementNS",
+	OpCallback:                  "Callback",
+	OpCallbackLastElement:       "CallbackLastElement",
+	OpHydrateExpect:             "HydrateExpect",
+	OpRemoveKeyed:               "RemoveKeyed",
+	OpMoveKeyed:                 "MoveKeyed",
+	OpSkipNode:                  "SkipNode",
+	OpSelectPortal:              "SelectPortal",
+	OpRemovePortal:              "RemovePortal",
+	OpRemoveAttr:                "RemoveAttr",
+	OpSkipNodes:                 "SkipNodes",
+	OpSetDelegatedEvents:        "SetDelegatedEvents",
+}
+
+// String returns the name of the opcode, e.g. "SetElement", the same as the keys of domrender.RenderStats.OpcodeCounts.
+func (op Opcode) String() string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	return "Opcode(" + strconv.Itoa(int(op)) + ")"
+}
@@ -0,0 +1,73 @@
+package domrender
+
+import (
+	"time"
+)
+
+// RenderStats describes a single render.
+type RenderStats struct {
+	Start    time.Time
+	Duration time.Duration // from the start of the render until the last instructions were flushed and processed
+
+	Instructions int            // total instructions written
+	OpcodeCounts map[string]int // instructions written by opcode name, e.g. "SetElement"
+	Flushes      int            // number of times the instruction buffer was sent to JS
+	BytesFlushed int            // total size of the instructions sent to JS
+
+	RenderCounts // how much of the render was skipped
+}
+
+// RenderTracer receives the stats for each render, see JSRenderer.SetTracer.
+type RenderTracer interface {
+	RenderDone(stats RenderStats)
+}
+
+// SetTracer sets a RenderTracer which is called after each render.  Pass nil to remove it.
+func (r *JSRenderer) SetTracer(t RenderTracer) {
+	r.tracer = t
+}
+
+// InstructionRecorder receives the instructions sent to JS, see JSRenderer.SetInstructionRecorder.
+// The ildecode package has an implementation which can decode and disassemble them.
+type InstructionRecorder interface {
+	// RecordInstructions is called with each buffer of instructions just before it is sent to JS, a render
+	// sends one or more.  b is only valid during the call.
+	RecordInstructions(b []byte)
+}
+
+// SetInstructionRecorder sets an InstructionRecorder which is called with the exact instructions sent
+// for each render, for tests and debugging.  Pass nil to remove it.
+func (r *JSRenderer) SetInstructionRecorder(rec InstructionRecorder) {
+	r.instructionList.recorder = rec
+}
+
+// LastRenderStats returns the stats for the most recent render.
+func (r *JSRenderer) LastRenderStats() RenderStats {
+	return r.stats
+}
+
+// finishStats is called at the end of a render to fill in stats and call the tracer
+func (r *JSRenderer) finishStats(start time.Time) {
+
+	il := r.instructionList
+	stats := RenderStats{
+		Start:        start,
+		Duration:     time.Since(start),
+		OpcodeCounts: make(map[string]int),
+		Flushes:      il.flushes,
+		BytesFlushed: il.bytesFlushed,
+		RenderCounts: r.counts,
+	}
+	for op, n := range il.opcodeCounts {
+		if n == 0 {
+			continue
+		}
+		stats.Instructions += n
+		stats.OpcodeCounts[opcodeNames[uint8(op)]] += n
+	}
+
+	r.stats = stats
+	if r.tracer != nil {
+		r.tracer.RenderDone(stats)
+	}
+}
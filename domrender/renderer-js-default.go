@@ -1,9 +1,11 @@
 package domrender
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/vugu/vugu"
+	js "github.com/vugu/vugu/js"
 )
 
 func (r *JSRenderer) sendEventWaitCh() {
@@ -35,6 +37,10 @@ func (r *JSRenderer) Render(buildResults *vugu.BuildResults) error {
 	r.eventRWMU.RLock()
 	defer r.eventRWMU.RUnlock()
 
+	if !js.Global().Truthy() {
+		return errors.New("js environment not available")
+	}
+
 	err := r.render(buildResults)
 	return err
 }
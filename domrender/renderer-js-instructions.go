@@ -64,8 +64,60 @@ const (
 	opcodeCallback            uint8 = 40 // issue callback, sends just callbackID
 	opcodeCallbackLastElement uint8 = 41 // issue callback with callbackID and most recent element reference
 
+	opcodeHydrateExpect uint8 = 42 // check that the node about to be synced matches what we expect, record a mismatch if not
+
+	opcodeRemoveKeyed uint8 = 43 // remove the child of the current element with the specified key
+	opcodeMoveKeyed   uint8 = 44 // move the node with the specified key into the position about to be synced
+
+	opcodeSkipNode uint8 = 45 // select the next node and leave it as is, used for output which has not changed
+
+	opcodeSelectPortal uint8 = 46 // select the element (created if needed) inside the target element which holds a portal's children
+	opcodeRemovePortal uint8 = 47 // remove the element inside the target element which holds a portal's children
+
+	opcodeRemoveAttr uint8 = 48 // remove an attribute from the current element
+	opcodeSkipNodes  uint8 = 49 // select the next node and the specified number minus one of its siblings after it, leaving them as is
+
+	opcodeSetDelegatedEvents uint8 = 50 // set the event listeners of the current element which are called from a listener at the mount point
 )
 
+// opcodeNames is used for reporting instruction counts in RenderStats
+var opcodeNames = map[uint8]string{
+	opcodeEnd:                       "End",
+	opcodeClearEl:                   "ClearEl",
+	opcodeRemoveOtherAttrs:          "RemoveOtherAttrs",
+	opcodeSetAttrStr:                "SetAttrStr",
+	opcodeSelectMountPoint:          "SelectMountPoint",
+	opcodeMoveToFirstChild:          "MoveToFirstChild",
+	opcodeSetElement:                "SetElement",
+	opcodeSetText:                   "SetText",
+	opcodeSetComment:                "SetComment",
+	opcodeMoveToParent:              "MoveToParent",
+	opcodeMoveToNextSibling:         "MoveToNextSibling",
+	opcodeRemoveOtherEventListeners: "RemoveOtherEventListeners",
+	opcodeSetEventListener:          "SetEventListener",
+	opcodeSetInnerHTML:              "SetInnerHTML",
+	opcodeSetCSSTag:                 "SetCSSTag",
+	opcodeRemoveOtherCSSTags:        "RemoveOtherCSSTags",
+	opcodeSetJSTag:                  "SetJSTag",
+	opcodeRemoveOtherJSTags:         "RemoveOtherJSTags",
+	opcodeSetProperty:               "SetProperty",
+	opcodeSelectQuery:               "SelectQuery",
+	opcodeBufferInnerHTML:           "BufferInnerHTML",
+	opcodeSetAttrNSStr:              "SetAttrNSStr",
+	opcodeSetElementNS:              "SetElementNS",
+	opcodeCallback:                  "Callback",
+	opcodeCallbackLastElement:       "CallbackLastElement",
+	opcodeHydrateExpect:             "HydrateExpect",
+	opcodeRemoveKeyed:               "RemoveKeyed",
+	opcodeMoveKeyed:                 "MoveKeyed",
+	opcodeSkipNode:                  "SkipNode",
+	opcodeSelectPortal:              "SelectPortal",
+	opcodeRemovePortal:              "RemovePortal",
+	opcodeRemoveAttr:                "RemoveAttr",
+	opcodeSkipNodes:                 "SkipNodes",
+	opcodeSetDelegatedEvents:        "SetDelegatedEvents",
+}
+
 // newInstructionList will create a new instance backed by the specified slice and with a clearBufFunc
 // that is called when the buffer is about to overflow.
 func newInstructionList(buf []byte, flushBufFunc func(il *instructionList) error) *instructionList {
@@ -86,6 +138,19 @@ type instructionList struct {
 	pos          int
 	flushBufFunc func(il *instructionList) error
 	logWriter    io.Writer // set to non-nil to enable debug log output
+	recorder     InstructionRecorder
+
+	// stats since the last resetStats
+	opcodeCounts [256]int
+	flushes      int
+	bytesFlushed int
+}
+
+// resetStats clears the instruction counts, it is called at the start of each render
+func (il *instructionList) resetStats() {
+	il.opcodeCounts = [256]int{}
+	il.flushes = 0
+	il.bytesFlushed = 0
 }
 
 var errDoesNotFit = errors.New("requested instruction does not fit in the buffer")
@@ -107,6 +172,13 @@ func (il *instructionList) flush() error {
 		return err
 	}
 
+	il.flushes++
+	il.bytesFlushed += il.pos
+
+	if il.recorder != nil {
+		il.recorder.RecordInstructions(il.buf[:il.pos])
+	}
+
 	err = il.flushBufFunc(il)
 	if err != nil {
 		return err
@@ -166,7 +238,7 @@ func (il *instructionList) writeClearEl() error {
 		return err
 	}
 
-	il.writeValUint8(opcodeClearEl)
+	il.writeOpcode(opcodeClearEl)
 
 	return nil
 }
@@ -182,7 +254,7 @@ func (il *instructionList) writeRemoveOtherAttrs() error {
 		return err
 	}
 
-	il.writeValUint8(opcodeRemoveOtherAttrs)
+	il.writeOpcode(opcodeRemoveOtherAttrs)
 
 	return nil
 }
@@ -200,7 +272,7 @@ func (il *instructionList) writeSetAttrStr(name, value string) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeSetAttrStr)
+	il.writeOpcode(opcodeSetAttrStr)
 	il.writeValString(name)
 	il.writeValString(value)
 
@@ -219,7 +291,7 @@ func (il *instructionList) writeSetAttrNSStr(namespace, name, value string) erro
 		return err
 	}
 
-	il.writeValUint8(opcodeSetAttrNSStr)
+	il.writeOpcode(opcodeSetAttrNSStr)
 	il.writeValString(namespace)
 	il.writeValString(name)
 	il.writeValString(value)
@@ -237,7 +309,7 @@ func (il *instructionList) writeSelectQuery(selector string) error {
 	if err != nil {
 		return err
 	}
-	il.writeValUint8(opcodeSelectQuery)
+	il.writeOpcode(opcodeSelectQuery)
 	il.writeValString(selector)
 	return nil
 }
@@ -253,7 +325,7 @@ func (il *instructionList) writeSelectMountPoint(selector, nodeName string) erro
 		return err
 	}
 
-	il.writeValUint8(opcodeSelectMountPoint)
+	il.writeOpcode(opcodeSelectMountPoint)
 	il.writeValString(selector)
 	il.writeValString(nodeName)
 
@@ -272,7 +344,7 @@ func (il *instructionList) writeMoveToFirstChild() error {
 		return err
 	}
 
-	il.writeValUint8(opcodeMoveToFirstChild)
+	il.writeOpcode(opcodeMoveToFirstChild)
 
 	return nil
 }
@@ -288,7 +360,7 @@ func (il *instructionList) writeSetElement(nodeName string) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeSetElement)
+	il.writeOpcode(opcodeSetElement)
 	il.writeValString(nodeName)
 
 	return nil
@@ -308,7 +380,7 @@ func (il *instructionList) writeSetElementNS(nodeName, namespace string) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeSetElementNS)
+	il.writeOpcode(opcodeSetElementNS)
 	il.writeValString(nodeName)
 	il.writeValString(namespace)
 
@@ -327,7 +399,7 @@ func (il *instructionList) writeSetText(text string) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeSetText)
+	il.writeOpcode(opcodeSetText)
 	il.writeValString(text)
 
 	return nil
@@ -345,7 +417,7 @@ func (il *instructionList) writeSetComment(comment string) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeSetComment)
+	il.writeOpcode(opcodeSetComment)
 	il.writeValString(comment)
 
 	return nil
@@ -363,7 +435,7 @@ func (il *instructionList) writeMoveToParent() error {
 		return err
 	}
 
-	il.writeValUint8(opcodeMoveToParent)
+	il.writeOpcode(opcodeMoveToParent)
 
 	return nil
 }
@@ -379,7 +451,7 @@ func (il *instructionList) writeMoveToNextSibling() error {
 		return err
 	}
 
-	il.writeValUint8(opcodeMoveToNextSibling)
+	il.writeOpcode(opcodeMoveToNextSibling)
 
 	return nil
 }
@@ -408,7 +480,7 @@ func (il *instructionList) writeSetInnerHTML(html string) error {
 			return err
 		}
 
-		il.writeValUint8(opcodeBufferInnerHTML)
+		il.writeOpcode(opcodeBufferInnerHTML)
 		il.writeValString(chunk)
 		il.flush()
 	}
@@ -418,24 +490,24 @@ func (il *instructionList) writeSetInnerHTML(html string) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeSetInnerHTML)
+	il.writeOpcode(opcodeSetInnerHTML)
 	il.writeValString(remaining)
 
 	return nil
 }
 
-func (il *instructionList) writeSetEventListener(positionID []byte, eventType string, capture, passive bool) error {
-	err := il.logf("writeSetInnerHTML[%d](positionID=%q, eventType=%q, capture=%v, passive=%v)", opcodeSetEventListener, positionID, eventType, capture, passive)
+func (il *instructionList) writeSetEventListener(positionID []byte, eventType string, capture, passive bool, flags uint8, keys string) error {
+	err := il.logf("writeSetEventListener[%d](positionID=%q, eventType=%q, capture=%v, passive=%v, flags=%d, keys=%q)", opcodeSetEventListener, positionID, eventType, capture, passive, flags, keys)
 	if err != nil {
 		return err
 	}
 
-	err = il.checkLenAndFlush(len(positionID) + len(eventType) + 11)
+	err = il.checkLenAndFlush(len(positionID) + len(eventType) + len(keys) + 16)
 	if err != nil {
 		return err
 	}
 
-	il.writeValUint8(opcodeSetEventListener)
+	il.writeOpcode(opcodeSetEventListener)
 	il.writeValBytes(positionID)
 	il.writeValString(eventType)
 
@@ -451,6 +523,9 @@ func (il *instructionList) writeSetEventListener(positionID []byte, eventType st
 	}
 	il.writeValUint8(passiveB)
 
+	il.writeValUint8(flags)
+	il.writeValString(keys)
+
 	return nil
 
 }
@@ -466,13 +541,33 @@ func (il *instructionList) writeRemoveOtherEventListeners(positionID []byte) err
 		return err
 	}
 
-	il.writeValUint8(opcodeRemoveOtherEventListeners)
+	il.writeOpcode(opcodeRemoveOtherEventListeners)
 	il.writeValBytes(positionID)
 
 	return nil
 
 }
 
+// writeSetDelegatedEvents sets the delegated listeners for the current element, eventKeys is the
+// event key of each one (see eventKey) separated by newlines.
+func (il *instructionList) writeSetDelegatedEvents(positionID []byte, eventKeys string) error {
+	err := il.logf("writeSetDelegatedEvents[%d](positionID=%q, eventKeys=%q)", opcodeSetDelegatedEvents, positionID, eventKeys)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(9 + len(positionID) + len(eventKeys))
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeSetDelegatedEvents)
+	il.writeValBytes(positionID)
+	il.writeValString(eventKeys)
+
+	return nil
+}
+
 func (il *instructionList) writeSetCSSTag(elementName string, textContent []byte, attrPairs []string) error {
 	err := il.logf("writeSetCSSTag[%d](elementName=%q, textContext=%q, attrPairs=%#v)", opcodeSetCSSTag, elementName, textContent, attrPairs)
 	if err != nil {
@@ -500,7 +595,7 @@ func (il *instructionList) writeSetCSSTag(elementName string, textContent []byte
 		return err
 	}
 
-	il.writeValUint8(opcodeSetCSSTag)
+	il.writeOpcode(opcodeSetCSSTag)
 	// il.writeValUint64(hashCode)
 	il.writeValString(elementName)
 	il.writeValBytes(textContent)
@@ -524,7 +619,7 @@ func (il *instructionList) writeRemoveOtherCSSTags() error {
 		return err
 	}
 
-	il.writeValUint8(opcodeRemoveOtherCSSTags)
+	il.writeOpcode(opcodeRemoveOtherCSSTags)
 
 	return nil
 }
@@ -541,7 +636,7 @@ func (il *instructionList) writeSetProperty(key string, jsonValue []byte) error
 		return err
 	}
 
-	il.writeValUint8(opcodeSetProperty)
+	il.writeOpcode(opcodeSetProperty)
 	il.writeValString(key)
 	il.writeValBytes(jsonValue)
 
@@ -560,7 +655,7 @@ func (il *instructionList) writeCallback(callbackID uint32) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeCallback)
+	il.writeOpcode(opcodeCallback)
 	il.writeValUint32(callbackID)
 
 	return nil
@@ -578,12 +673,122 @@ func (il *instructionList) writeCallbackLastElement(callbackID uint32) error {
 		return err
 	}
 
-	il.writeValUint8(opcodeCallbackLastElement)
+	il.writeOpcode(opcodeCallbackLastElement)
 	il.writeValUint32(callbackID)
 
 	return nil
 }
 
+func (il *instructionList) writeHydrateExpect(positionID []byte, nodeType uint8, nodeName string) error {
+	err := il.logf("writeHydrateExpect[%d](positionID=%q, nodeType=%d, nodeName=%q)", opcodeHydrateExpect, positionID, nodeType, nodeName)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(len(positionID) + len(nodeName) + 10)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeHydrateExpect)
+	il.writeValBytes(positionID)
+	il.writeValUint8(nodeType)
+	il.writeValString(nodeName)
+
+	return nil
+}
+
+func (il *instructionList) writeRemoveKeyed(key string) error {
+	err := il.logf("writeRemoveKeyed[%d](key=%q)", opcodeRemoveKeyed, key)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(len(key) + 5)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeRemoveKeyed)
+	il.writeValString(key)
+
+	return nil
+}
+
+func (il *instructionList) writeMoveKeyed(key string) error {
+	err := il.logf("writeMoveKeyed[%d](key=%q)", opcodeMoveKeyed, key)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(len(key) + 5)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeMoveKeyed)
+	il.writeValString(key)
+
+	return nil
+}
+
+func (il *instructionList) writeSkipNode() error {
+	err := il.logf("writeSkipNode[%d]()", opcodeSkipNode)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(1)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeSkipNode)
+
+	return nil
+}
+
+func (il *instructionList) writeSkipNodes(count uint32) error {
+	err := il.logf("writeSkipNodes[%d](count=%d)", opcodeSkipNodes, count)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(5)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeSkipNodes)
+	il.writeValUint32(count)
+
+	return nil
+}
+
+func (il *instructionList) writeRemoveAttr(namespace, name string) error {
+	err := il.logf("writeRemoveAttr[%d](ns=%q, name=%q)", opcodeRemoveAttr, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(len(namespace) + len(name) + 9)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeRemoveAttr)
+	il.writeValString(namespace)
+	il.writeValString(name)
+
+	return nil
+}
+
+// writeOpcode writes the opcode which begins an instruction and counts it
+func (il *instructionList) writeOpcode(opcode uint8) {
+	il.opcodeCounts[opcode]++
+	il.writeValUint8(opcode)
+}
+
 func (il *instructionList) writeValUint8(b uint8) {
 	il.buf[il.pos] = b
 	il.pos++
@@ -637,3 +842,39 @@ func (il *instructionList) writeValBytes(s []byte) {
 
 // 	return nil
 // }
+
+func (il *instructionList) writeSelectPortal(selector, portalID string) error {
+	err := il.logf("writeSelectPortal[%d](selector=%q, portalID=%q)", opcodeSelectPortal, selector, portalID)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(len(selector) + len(portalID) + 9)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeSelectPortal)
+	il.writeValString(selector)
+	il.writeValString(portalID)
+
+	return nil
+}
+
+func (il *instructionList) writeRemovePortal(selector, portalID string) error {
+	err := il.logf("writeRemovePortal[%d](selector=%q, portalID=%q)", opcodeRemovePortal, selector, portalID)
+	if err != nil {
+		return err
+	}
+
+	err = il.checkLenAndFlush(len(selector) + len(portalID) + 9)
+	if err != nil {
+		return err
+	}
+
+	il.writeOpcode(opcodeRemovePortal)
+	il.writeValString(selector)
+	il.writeValString(portalID)
+
+	return nil
+}
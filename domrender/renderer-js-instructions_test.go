@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/vugu/vugu/domrender/ildecode"
 )
 
 func TestWriteSetInnerHTML(t *testing.T) {
@@ -97,3 +99,64 @@ func TestWriteSetInnerHTML(t *testing.T) {
 		assert.Equal(t, test.outputBuffer, buffer, test.description)
 	}
 }
+
+// TestInstructionsDecode writes each instruction and checks that ildecode gets the same thing back,
+// so the two stay in sync
+func TestInstructionsDecode(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var rec ildecode.Recorder
+	il := newInstructionList(make([]byte, 64), func(il *instructionList) error { return nil })
+	il.recorder = &rec
+
+	var expected []ildecode.Instruction
+	write := func(err error, in ildecode.Instruction) {
+		assert.NoError(err)
+		expected = append(expected, in)
+	}
+	write(il.writeClearEl(), ildecode.ClearEl{})
+	write(il.writeRemoveOtherAttrs(), ildecode.RemoveOtherAttrs{})
+	write(il.writeSetAttrStr("id", "main"), ildecode.SetAttrStr{Name: "id", Value: "main"})
+	write(il.writeSelectMountPoint("#app", "div"), ildecode.SelectMountPoint{Selector: "#app", NodeName: "div"})
+	write(il.writeMoveToFirstChild(), ildecode.MoveToFirstChild{})
+	write(il.writeSetElement("p"), ildecode.SetElement{NodeName: "p"})
+	write(il.writeSetText("hello"), ildecode.SetText{Text: "hello"})
+	write(il.writeSetComment("c"), ildecode.SetComment{Comment: "c"})
+	write(il.writeMoveToParent(), ildecode.MoveToParent{})
+	write(il.writeMoveToNextSibling(), ildecode.MoveToNextSibling{})
+	write(il.writeRemoveOtherEventListeners([]byte("0_1")), ildecode.RemoveOtherEventListeners{PositionID: "0_1"})
+	write(il.writeSetEventListener([]byte("0_1"), "keyup", true, false, 3, "enter"),
+		ildecode.SetEventListener{PositionID: "0_1", EventType: "keyup", Capture: true, Flags: 3, Keys: "enter"})
+	write(il.writeSetInnerHTML("<b>x</b>"), ildecode.SetInnerHTML{HTML: "<b>x</b>"})
+	write(il.writeSetCSSTag("style", []byte("p{}"), []string{"media", "print"}),
+		ildecode.SetCSSTag{ElementName: "style", TextContent: "p{}", AttrPairs: []string{"media", "print"}})
+	write(il.writeRemoveOtherCSSTags(), ildecode.RemoveOtherCSSTags{})
+	write(il.writeSetProperty("value", []byte(`"v"`)), ildecode.SetProperty{Key: "value", JSONValue: `"v"`})
+	write(il.writeSelectQuery("body"), ildecode.SelectQuery{Selector: "body"})
+	write(il.writeSetAttrNSStr("http://www.w3.org/1999/xlink", "href", "#a"),
+		ildecode.SetAttrNSStr{Namespace: "http://www.w3.org/1999/xlink", Name: "href", Value: "#a"})
+	write(il.writeSetElementNS("svg", "http://www.w3.org/2000/svg"), ildecode.SetElementNS{NodeName: "svg", Namespace: "http://www.w3.org/2000/svg"})
+	write(il.writeCallback(5), ildecode.Callback{CallbackID: 5})
+	write(il.writeCallbackLastElement(6), ildecode.CallbackLastElement{CallbackID: 6})
+	write(il.writeHydrateExpect([]byte("0"), 1, "div"), ildecode.HydrateExpect{PositionID: "0", NodeType: 1, NodeName: "div"})
+	write(il.writeRemoveKeyed("k1"), ildecode.RemoveKeyed{Key: "k1"})
+	write(il.writeMoveKeyed("k2"), ildecode.MoveKeyed{Key: "k2"})
+	write(il.writeSkipNode(), ildecode.SkipNode{})
+	write(il.writeSelectPortal("#modal", "0_2"), ildecode.SelectPortal{Selector: "#modal", PortalID: "0_2"})
+	write(il.writeRemovePortal("#modal", "0_2"), ildecode.RemovePortal{Selector: "#modal", PortalID: "0_2"})
+	write(il.writeRemoveAttr("", "class"), ildecode.RemoveAttr{Name: "class"})
+	write(il.writeRemoveAttr("http://www.w3.org/1999/xlink", "href"), ildecode.RemoveAttr{Namespace: "http://www.w3.org/1999/xlink", Name: "href"})
+	write(il.writeSkipNodes(12), ildecode.SkipNodes{Count: 12})
+	write(il.writeSetDelegatedEvents([]byte("0_1"), "click|0|0|2:"), ildecode.SetDelegatedEvents{PositionID: "0_1", Events: "click|0|0|2:"})
+	assert.NoError(il.flush())
+
+	ins, err := rec.Instructions()
+	assert.NoError(err)
+	assert.Equal(expected, ins)
+
+	// and the names are the same as in RenderStats
+	for op, name := range opcodeNames {
+		assert.Equal(name, ildecode.Opcode(op).String())
+	}
+}
@@ -46,8 +46,320 @@
     const opcodeCallback = 40 // issue callback, sends just callbackID
     const opcodeCallbackLastElement = 41 // issue callback with callbackID and most recent element reference
 
+    const opcodeHydrateExpect = 42 // check that the node about to be synced matches what we expect, record a mismatch if not
+
+    const opcodeRemoveKeyed = 43 // remove the child of the current element with the specified key
+    const opcodeMoveKeyed = 44 // move the node with the specified key into the position about to be synced
+
+    const opcodeSkipNode = 45 // select the next node and leave it as is, used for output which has not changed
+
+    const opcodeSelectPortal = 46 // select the element (created if needed) inside the target element which holds a portal's children
+    const opcodeRemovePortal = 47 // remove the element inside the target element which holds a portal's children
+
+    const opcodeRemoveAttr = 48 // remove an attribute from the current element
+    const opcodeSkipNodes = 49 // select the next node and the specified number minus one of its siblings after it, leaving them as is
+
+    const opcodeSetDelegatedEvents = 50 // set the event listeners of the current element which are called from a listener at the mount point
+
     /*DEBUG OPCODE STRINGS*/
 
+    // event modifier flags, must match the Go side
+    const eventFlagPreventDefault = 1
+    const eventFlagStopPropagation = 2
+    const eventFlagOnce = 4
+    const eventFlagSelf = 8
+
+    // aliases for key modifiers to values of KeyboardEvent.key
+    const eventKeyAliases = {
+        "enter": ["enter"],
+        "tab": ["tab"],
+        "delete": ["delete", "backspace"],
+        "esc": ["escape", "esc"],
+        "space": [" ", "spacebar"],
+        "up": ["arrowup", "up"],
+        "down": ["arrowdown", "down"],
+        "left": ["arrowleft", "left"],
+        "right": ["arrowright", "right"],
+    };
+
+    // mouse button modifiers to values of MouseEvent.button
+    const eventMouseButtons = {"left": 0, "middle": 1, "right": 2};
+
+    // summarizeFiles converts a FileList to an array of plain objects
+    function summarizeFiles(files) {
+        let ret = [];
+        for (let i = 0; files && i < files.length; i++) {
+            let f = files[i];
+            ret.push({name: f.name, type: f.type, size: f.size, lastModified: f.lastModified});
+        }
+        return ret;
+    }
+
+    // summarizeTouches converts a TouchList to an array of plain objects
+    function summarizeTouches(touches) {
+        let ret = [];
+        for (let i = 0; touches && i < touches.length; i++) {
+            let t = touches[i];
+            ret.push({
+                identifier: t.identifier,
+                clientX: t.clientX, clientY: t.clientY,
+                screenX: t.screenX, screenY: t.screenY,
+                pageX: t.pageX, pageY: t.pageY,
+                radiusX: t.radiusX, radiusY: t.radiusY,
+                rotationAngle: t.rotationAngle,
+                force: t.force,
+            });
+        }
+        return ret;
+    }
+
+    // eventKeysMatch returns true if the event satisfies all of the key modifiers
+    function eventKeysMatch(event, keys) {
+        for (let i = 0; i < keys.length; i++) {
+            let k = keys[i];
+            switch (k) {
+                case "ctrl":
+                case "alt":
+                case "shift":
+                case "meta":
+                    if (!event[k + "Key"]) {
+                        return false;
+                    }
+                    continue;
+            }
+            // mouse events use left/middle/right as buttons
+            if (typeof event.button == "number" && !("key" in event) && (k in eventMouseButtons)) {
+                if (event.button != eventMouseButtons[k]) {
+                    return false;
+                }
+                continue;
+            }
+            if (typeof event.key != "string") {
+                return false;
+            }
+            // compare against the key in kebab-case, e.g. "page-down" for "PageDown"
+            let eventKey = event.key.replace(/([a-z])([A-Z])/g, "$1-$2").toLowerCase();
+            let names = eventKeyAliases[k] || [k];
+            if (names.indexOf(eventKey) < 0 && names.indexOf(event.key.toLowerCase()) < 0) {
+                return false;
+            }
+        }
+        return true;
+    }
+
+    // applyPendingKey assigns the key from opcodeMoveKeyed to the element just synced,
+    // elements synced without a key have any previous key cleared
+    function applyPendingKey(state) {
+        if (state.pendingKey !== undefined || state.el.vuguKey !== undefined) {
+            state.el.vuguKey = state.pendingKey;
+        }
+        state.pendingKey = undefined;
+    }
+
+    // sendEvent passes an event to the Go side, for the listener registered by positionID with the
+    // type, phase and modifiers given.  currentTarget is the element the listener is for.
+    function sendEvent(state, event, currentTarget, positionID, eventType, capture, passive, modifiers) {
+
+        // set the active event, so the Go code and call back in and examine it if needed
+        state.activeEvent = event;
+        state.activeCurrentTarget = currentTarget;
+
+        let eventObj = {};
+        // console.log(event);
+        for (let i in event) {
+            try {
+                // accessing `selectionDirection`, `selectionStart`, or `selectionEnd` throws in WebKit-based browsers.
+                let itype = typeof (event[i]);
+                // copy primitive values directly
+                if (itype == "boolean" || itype == "number" || itype == "string") {
+                    eventObj[i] = event[i];
+                }
+            } catch {}
+        }
+
+        // also do the same for anything in "target"
+        if (event.target) {
+            eventObj.target = {};
+            let et = event.target;
+            for (let i in et) {
+                try {
+                    let itype = typeof (et[i]);
+                    if (itype == "boolean" || itype == "number" || itype == "string") {
+                        eventObj.target[i] = et[i];
+                    }
+                } catch {}
+            }
+        }
+
+        // non-primitive values used by the typed event accessors on the Go side
+        if (event.dataTransfer) {
+            let dt = event.dataTransfer;
+            eventObj.dataTransfer = {
+                dropEffect: dt.dropEffect,
+                effectAllowed: dt.effectAllowed,
+                types: Array.from(dt.types || []),
+                files: summarizeFiles(dt.files),
+            };
+        }
+        if (event.touches) {
+            eventObj.touches = summarizeTouches(event.touches);
+            eventObj.targetTouches = summarizeTouches(event.targetTouches);
+            eventObj.changedTouches = summarizeTouches(event.changedTouches);
+        }
+        if (event.target && event.target.files) {
+            eventObj.target.files = summarizeFiles(event.target.files);
+        }
+
+        // console.log(eventObj);
+        // console.log(JSON.stringify(eventObj));
+
+        let fullJSON = JSON.stringify({
+
+            // include properties from event registration
+            position_id: positionID,
+            event_type: eventType,
+            capture: !!capture,
+            passive: !!passive,
+            modifiers: modifiers,
+
+            // the event object data as extracted above
+            event_summary: eventObj,
+
+        });
+
+        // console.log(state.eventBuffer);
+
+        // write JSON to state.eventBuffer with uint32 length prefix
+
+        let encodeResultBuffer = utf8encoder.encode(fullJSON);
+
+        const dataSize = encodeResultBuffer.byteLength - encodeResultBuffer.byteOffset
+        // we need to allocate more bytes for storing data size in the beginning of the buffer
+        const requiredBufferSize = dataSize + 4
+
+        const computeEventBufferSize = (requiredBufferSize) => {
+            const sixteen_kb = 16384
+            const actualRequired = requiredBufferSize + 1
+            const remainder = actualRequired % sixteen_kb
+
+            // but for now this needs to be at least one byte shorter
+            // than Go's buffer
+            if (remainder === 0) {
+                return actualRequired - 1
+            }
+
+            return actualRequired + (sixteen_kb - remainder) - 1
+        }
+
+        // before eventHandlerFunc is called make sure eventBuffer and eventBufferView are setup,
+        // and allocateEventBuffer is called
+        let eventBuffer = state.eventBuffer;
+        if (!eventBuffer || eventBuffer.length < requiredBufferSize) {
+            const eventBufferSize = computeEventBufferSize(requiredBufferSize)
+            eventBuffer = new Uint8Array(eventBufferSize);
+            state.eventBuffer = eventBuffer;
+            state.eventBufferView = new DataView(eventBuffer.buffer, eventBuffer.byteOffset, eventBuffer.byteLength);
+        }
+        //console.log("encodeResult", encodeResult);
+        state.eventBuffer.set(encodeResultBuffer, 4); // copy encoded string to event buffer
+        // now write length using DataView as uint32
+        state.eventBufferView.setUint32(0, dataSize);
+
+        // let result = textEncoder.encodeInto(fullJSON, state.eventBuffer);
+        // let eventBufferDataView = new DataView(state.eventBuffer.buffer, state.eventBuffer.byteOffset, state.eventBuffer.byteLength);
+        // eventBufferDataView.setUint8(result.written, 0);
+
+        // write length after, since only now do we know the final length
+        // state.eventBufferView.setUint32(0, result.written);
+
+        // serialize event into the event buffer, somehow,
+        // and keep track of the target element, also consider grabbing
+        // the value or relevant properties as appropriate for form things
+
+        /*DEBUG*/ console.log("event handler calling state.eventHandlerFunc", eventBuffer);
+        state.eventHandlerFunc.call(null, eventBuffer); // call with null this avoids unnecessary js.Value reference
+
+        // unset the active event
+        state.activeEvent = null;
+        state.activeCurrentTarget = null;
+    }
+
+    // delegateEvent makes sure there is a listener for the event type and phase at the mount point (or the
+    // document if there isn't one), which dispatches the event to the elements set by opcodeSetDelegatedEvents
+    function delegateEvent(state, eventType, capture, passive) {
+        let root = state.mountPointEl || document;
+        let key = eventType + "|" + capture + "|" + passive;
+        let dl = state.delegateListeners[key];
+        if (dl && dl.root === root) {
+            return;
+        }
+        if (dl) {
+            // the mount point was replaced
+            dl.root.removeEventListener(eventType, dl.f, {capture: capture, passive: passive});
+        } else {
+            dl = {f: delegateListener(state, eventType, capture, passive)};
+            state.delegateListeners[key] = dl;
+        }
+        dl.root = root;
+        root.addEventListener(eventType, dl.f, {capture: capture, passive: passive});
+    }
+
+    // delegateListener returns the listener added by delegateEvent.  It calls the delegated listeners
+    // on the elements from the target up to the mount point, in the same order and with the same
+    // modifiers as if each had been added to its element with opcodeSetEventListener.
+    function delegateListener(state, eventType, capture, passive) {
+        return function (event) {
+
+            /*DEBUG*/ console.log("delegated event listener called with event", event);
+
+            let root = event.currentTarget;
+            let path = [];
+            for (let el = event.target; el; el = el.parentNode) {
+                if (el.vuguDelegated) {
+                    path.push(el);
+                }
+                if (el === root) {
+                    break;
+                }
+            }
+            // capture goes from the outside in
+            if (capture) {
+                path.reverse();
+            }
+
+            for (let i = 0; i < path.length; i++) {
+                let el = path[i];
+                let d = el.vuguDelegated;
+                for (let j = 0; j < d.listeners.length; j++) {
+                    let l = d.listeners[j];
+                    if (l.eventType != eventType || l.capture != capture || l.passive != passive || l.fired) {
+                        continue;
+                    }
+                    if ((l.flags & eventFlagSelf) && event.target !== el) {
+                        continue;
+                    }
+                    if (l.keys && !eventKeysMatch(event, l.keys.split(","))) {
+                        continue;
+                    }
+                    if (l.flags & eventFlagPreventDefault) {
+                        event.preventDefault();
+                    }
+                    if (l.flags & eventFlagStopPropagation) {
+                        event.stopPropagation();
+                    }
+                    if (l.flags & eventFlagOnce) {
+                        l.fired = true;
+                    }
+                    sendEvent(state, event, el, d.positionID, eventType, capture, passive, l.modifiers);
+                }
+                // stopped by a modifier or the Go handler, the other listeners on the same element still get it
+                if (event.cancelBubble) {
+                    break;
+                }
+            }
+        };
+    }
+
     // Decoder provides our binary decoding.
     // Using a class because that's what all the cool JS kids are doing these days.
     class Decoder {
@@ -93,6 +405,7 @@
     }
 
     let utf8decoder = new TextDecoder();
+    let utf8encoder = new TextEncoder();
 
     window.vuguGetActiveEvent = function () {
         let state = window.vuguState || {};
@@ -107,7 +420,8 @@
     window.vuguGetActiveEventCurrentTarget = function () {
         let state = window.vuguState || {};
         window.vuguState = state;
-        return state.activeEvent && state.activeEvent.currentTarget;
+        // for a delegated listener this is the element it was set on, not the mount point
+        return state.activeEvent && (state.activeCurrentTarget || state.activeEvent.currentTarget);
     }
     window.vuguActiveEventPreventDefault = function () {
         let state = window.vuguState || {};
@@ -146,6 +460,15 @@
         state.callbackHandlerFunc = callbackHandlerFunc;
     }
 
+    // returns (as JSON) and clears the list of mismatches found by opcodeHydrateExpect
+    window.vuguHydrateMismatches = function () {
+        let state = window.vuguState || {};
+        window.vuguState = state;
+        let ret = JSON.stringify(state.hydrateMismatches || []);
+        state.hydrateMismatches = [];
+        return ret;
+    }
+
     window.vuguGetRenderArray = function () {
         if (!window.vuguRenderArray) {
             window.vuguRenderArray = new Uint8Array(16384);
@@ -171,8 +494,6 @@
 
         // console.log("vuguRender called");
 
-        let textEncoder = new TextEncoder();
-
         let bufferView = new DataView(buffer.buffer, buffer.byteOffset, buffer.byteLength);
 
         var decoder = new Decoder(bufferView, 0);
@@ -200,6 +521,9 @@
         // (Parents always exist and so doesn't use this mechanism.)
         state.nextElMove = state.nextElMove || null;
 
+        // key from opcodeMoveKeyed to be assigned by the next opcodeSetElement
+        state.pendingKey = undefined;
+
         // keeps track of attributes that are being set on the current element, so we can remove any extras
         state.elAttrNames = state.elAttrNames || {};
 
@@ -209,6 +533,9 @@
         // keeps track of event listeners that are being set on the current element, so we can remvoe any extras
         state.elEventKeys = state.elEventKeys || {};
 
+        // map of event type and phase -> listener at the mount point, see delegateEvent
+        state.delegateListeners = state.delegateListeners || {};
+
         instructionLoop: while (true) {
 
             let opcode = decoder.readUint8();
@@ -285,6 +612,7 @@
 
                         state.elAttrNames = {}; // reset attribute list
                         state.elEventKeys = {};
+                        state.elDelegated = false;
 
                         // select mount point using selector or if it was done earlier re-use the one from before
                         let selector = decoder.readString();
@@ -446,6 +774,7 @@
 
                         state.elAttrNames = {};
                         state.elEventKeys = {};
+                        state.elDelegated = false;
 
                         // handle nextElMove cases
 
@@ -459,6 +788,7 @@
                                 newEl = document.createElement(nodeName);
                                 state.el.appendChild(newEl);
                                 state.el = newEl;
+                                applyPendingKey(state);
                                 break; // we're done here, since we just created the right element
                             }
                         } else if (state.nextElMove == "next_sibling") {
@@ -473,6 +803,7 @@
                                 // state.el.insertAdjacentElement(newEl, 'afterend');
                                 state.el.parentNode.appendChild(newEl);
                                 state.el = newEl;
+                                applyPendingKey(state);
                                 break; // we're done here, since we just created the right element
                             }
                         } else if (state.nextElMove) {
@@ -491,6 +822,8 @@
 
                         }
 
+                        applyPendingKey(state);
+
                         break;
                     }
                     // assign current selected node as an element of the specified type
@@ -503,6 +836,7 @@
 
                         state.elAttrNames = {};
                         state.elEventKeys = {};
+                        state.elDelegated = false;
 
                         // handle nextElMove cases
 
@@ -516,6 +850,7 @@
                                 newEl = document.createElementNS(namespace, nodeName);
                                 state.el.appendChild(newEl);
                                 state.el = newEl;
+                                applyPendingKey(state);
                                 break; // we're done here, since we just created the right element
                             }
                         } else if (state.nextElMove == "next_sibling") {
@@ -530,6 +865,7 @@
                                 // state.el.insertAdjacentElement(newEl, 'afterend');
                                 state.el.parentNode.appendChild(newEl);
                                 state.el = newEl;
+                                applyPendingKey(state);
                                 break; // we're done here, since we just created the right element
                             }
                         } else if (state.nextElMove) {
@@ -548,6 +884,8 @@
 
                         }
 
+                        applyPendingKey(state);
+
                         break;
                     }
 
@@ -556,6 +894,9 @@
 
                         let content = decoder.readString();
 
+                        // keys only apply to elements
+                        state.pendingKey = undefined;
+
                         /*DEBUG*/ console.log("opcodeSetText", content);
 
                         // this.console.log("opcodeSetText:", content);
@@ -620,6 +961,9 @@
 
                         let content = decoder.readString();
 
+                        // keys only apply to elements
+                        state.pendingKey = undefined;
+
                         /*DEBUG*/ console.log("opcodeSetComment", content);
 
                         // handle nextElMove cases
@@ -725,6 +1069,22 @@
                             delete emap[k];
                         }
 
+                        // an element moved by opcodeMoveKeyed still has the listeners from its previous position
+                        let prevPositionID = state.el.vuguPositionID;
+                        if (prevPositionID !== positionID) {
+                            let pmap = (prevPositionID !== undefined && state.eventHandlerMap[prevPositionID]) || {};
+                            for (let k in pmap) {
+                                let kparts = k.split("|");
+                                state.el.removeEventListener(kparts[0], pmap[k], {capture: +kparts[1], passive: +kparts[2]});
+                            }
+                            state.el.vuguPositionID = positionID;
+                        }
+
+                        // delegated listeners which were not just set are removed the same way
+                        if (!state.elDelegated && state.el.vuguDelegated) {
+                            delete state.el.vuguDelegated;
+                        }
+
                         // if emap is empty now, remove the entry from eventHandlerMap altogether
                         if (Object.keys(emap).length == 0) {
                             delete state.eventHandlerMap[positionID];
@@ -741,14 +1101,19 @@
                         let eventType = decoder.readString();
                         let capture = decoder.readUint8();
                         let passive = decoder.readUint8();
+                        let flags = decoder.readUint8();
+                        let keys = decoder.readString();
 
-                        /*DEBUG*/ console.log("opcodeSetEventListener", positionID, eventType, capture, passive);
+                        /*DEBUG*/ console.log("opcodeSetEventListener", positionID, eventType, capture, passive, flags, keys);
 
                         if (!state.el) {
                             throw "must have state.el set in order to call opcodeSetEventListener";
                         }
 
-                        var eventKey = eventType + "|" + (capture ? "1" : "0") + "|" + (passive ? "1" : "0");
+                        // must match eventModifierKey on the Go side
+                        let modifiers = flags + ":" + keys;
+
+                        var eventKey = eventType + "|" + (capture ? "1" : "0") + "|" + (passive ? "1" : "0") + "|" + modifiers;
                         state.elEventKeys[eventKey] = true;
 
                         // map of positionID -> map of listener spec and handler function, for all elements
@@ -762,106 +1127,28 @@
 
                                 /*DEBUG*/ console.log("event listener called with event", event);
 
-                                // set the active event, so the Go code and call back in and examine it if needed
-                                state.activeEvent = event;
-
-                                let eventObj = {};
-                                // console.log(event);
-                                for (let i in event) {
-                                    try {
-                                        // accessing `selectionDirection`, `selectionStart`, or `selectionEnd` throws in WebKit-based browsers.
-                                        let itype = typeof (event[i]);
-                                        // copy primitive values directly
-                                        if (itype == "boolean" || itype == "number" || itype == "string") {
-                                            eventObj[i] = event[i];
-                                        }
-                                    } catch {}
+                                // modifiers which filter out the event entirely
+                                if ((flags & eventFlagSelf) && event.target !== event.currentTarget) {
+                                    return;
                                 }
-
-                                // also do the same for anything in "target"
-                                if (event.target) {
-                                    eventObj.target = {};
-                                    let et = event.target;
-                                    for (let i in et) {
-                                        try {
-                                            let itype = typeof (et[i]);
-                                            if (itype == "boolean" || itype == "number" || itype == "string") {
-                                                eventObj.target[i] = et[i];
-                                            }
-                                        } catch {}
-                                    }
+                                if (keys && !eventKeysMatch(event, keys.split(","))) {
+                                    return;
                                 }
 
-                                // console.log(eventObj);
-                                // console.log(JSON.stringify(eventObj));
-
-                                let fullJSON = JSON.stringify({
-
-                                    // include properties from event registration
-                                    position_id: positionID,
-                                    event_type: eventType,
-                                    capture: !!capture,
-                                    passive: !!passive,
-
-                                    // the event object data as extracted above
-                                    event_summary: eventObj,
-
-                                });
-
-                                // console.log(state.eventBuffer);
-
-                                // write JSON to state.eventBuffer with uint32 length prefix
-
-                                let encodeResultBuffer = textEncoder.encode(fullJSON);
-
-                                const dataSize = encodeResultBuffer.byteLength - encodeResultBuffer.byteOffset
-                                // we need to allocate more bytes for storing data size in the beginning of the buffer
-                                const requiredBufferSize = dataSize + 4
-
-                                const computeEventBufferSize = (requiredBufferSize) => {
-                                    const sixteen_kb = 16384
-                                    const actualRequired = requiredBufferSize + 1
-                                    const remainder = actualRequired % sixteen_kb
-
-                                    // but for now this needs to be at least one byte shorter
-                                    // than Go's buffer
-                                    if (remainder === 0) {
-                                        return actualRequired - 1
-                                    }
-
-                                    return actualRequired + (sixteen_kb - remainder) - 1
+                                // these need to happen now, the Go handler is too late for them to take effect
+                                if (flags & eventFlagPreventDefault) {
+                                    event.preventDefault();
                                 }
-
-                                // before eventHandlerFunc is called make sure eventBuffer and eventBufferView are setup,
-                                // and allocateEventBuffer is called
-                                let eventBuffer = state.eventBuffer;
-                                if (!eventBuffer || eventBuffer.length < requiredBufferSize) {
-                                    const eventBufferSize = computeEventBufferSize(requiredBufferSize)
-                                    eventBuffer = new Uint8Array(eventBufferSize);
-                                    state.eventBuffer = eventBuffer;
-                                    state.eventBufferView = new DataView(eventBuffer.buffer, eventBuffer.byteOffset, eventBuffer.byteLength);
+                                if (flags & eventFlagStopPropagation) {
+                                    event.stopPropagation();
+                                }
+                                if (flags & eventFlagOnce) {
+                                    // remove the listener but keep it in emap marked as fired so it is not added again
+                                    f.vuguFired = true;
+                                    event.currentTarget.removeEventListener(eventType, f, {capture: capture, passive: passive});
                                 }
-                                //console.log("encodeResult", encodeResult);
-                                state.eventBuffer.set(encodeResultBuffer, 4); // copy encoded string to event buffer
-                                // now write length using DataView as uint32
-                                state.eventBufferView.setUint32(0, dataSize);
-
-                                // let result = textEncoder.encodeInto(fullJSON, state.eventBuffer);
-                                // let eventBufferDataView = new DataView(state.eventBuffer.buffer, state.eventBuffer.byteOffset, state.eventBuffer.byteLength);
-                                // eventBufferDataView.setUint8(result.written, 0);
-
-                                // write length after, since only now do we know the final length
-                                // state.eventBufferView.setUint32(0, result.written);
-
-                                // serialize event into the event buffer, somehow,
-                                // and keep track of the target element, also consider grabbing
-                                // the value or relevant properties as appropriate for form things
-
-                                /*DEBUG*/ console.log("event handler calling state.eventHandlerFunc", eventBuffer);
-                                state.eventHandlerFunc.call(null, eventBuffer); // call with null this avoids unnecessary js.Value reference
 
-                                // unset the active event
-                                state.activeEvent = null;
+                                sendEvent(state, event, event.currentTarget, positionID, eventType, capture, passive, modifiers);
                             };
                             emap[eventKey] = f;
 
@@ -872,9 +1159,11 @@
 
                         }
 
-                        // we always re-add the event listener, see note above
+                        // we always re-add the event listener, see note above (unless it's a once listener that already fired)
                         //this.console.log("addEventListener", eventType);
-                        state.el.addEventListener(eventType, f, {capture: capture, passive: passive});
+                        if (!f.vuguFired) {
+                            state.el.addEventListener(eventType, f, {capture: capture, passive: passive});
+                        }
 
                         state.eventHandlerMap[positionID] = emap;
 
@@ -882,6 +1171,52 @@
                         break;
                     }
 
+                    // set the event listeners of the current element which are called from a listener at the mount point
+                    case opcodeSetDelegatedEvents: {
+                        let positionID = decoder.readString();
+                        let eventKeys = decoder.readString().split("\n");
+
+                        /*DEBUG*/ console.log("opcodeSetDelegatedEvents", positionID, eventKeys);
+
+                        if (!state.el) {
+                            throw "must have state.el set in order to call opcodeSetDelegatedEvents";
+                        }
+
+                        // once listeners which already fired stay that way while the element is at the same position
+                        let prev = state.el.vuguDelegated;
+                        let fired = {};
+                        if (prev && prev.positionID === positionID) {
+                            for (let i = 0; i < prev.listeners.length; i++) {
+                                fired[prev.listeners[i].eventKey] = prev.listeners[i].fired;
+                            }
+                        }
+
+                        // each one is an event key as computed in opcodeSetEventListener
+                        let listeners = [];
+                        for (let i = 0; i < eventKeys.length; i++) {
+                            let eventKey = eventKeys[i];
+                            let kparts = eventKey.split("|");
+                            let modifiers = kparts[3];
+                            let sep = modifiers.indexOf(":");
+                            let l = {
+                                eventKey: eventKey,
+                                eventType: kparts[0],
+                                capture: +kparts[1],
+                                passive: +kparts[2],
+                                modifiers: modifiers,
+                                flags: +modifiers.slice(0, sep),
+                                keys: modifiers.slice(sep + 1),
+                                fired: !!fired[eventKey],
+                            };
+                            listeners.push(l);
+                            delegateEvent(state, l.eventType, l.capture, l.passive);
+                        }
+
+                        state.el.vuguDelegated = {positionID: positionID, listeners: listeners};
+                        state.elDelegated = true;
+                        break;
+                    }
+
                     case opcodeSetCSSTag: {
 
                         let elementName = decoder.readString();
@@ -1013,6 +1348,247 @@
                         break;
                     }
 
+                    case opcodeHydrateExpect: {
+                        let positionID = decoder.readString();
+                        let nodeType = decoder.readUint8();
+                        let nodeName = decoder.readString();
+
+                        /*DEBUG*/ console.log("opcodeHydrateExpect", positionID, nodeType, nodeName);
+
+                        // find the node that the next opcodeSetElement, opcodeSetText, etc. will use,
+                        // without actually moving there
+                        let el = state.el;
+                        if (state.nextElMove == "first_child") {
+                            el = el && el.firstChild;
+                        } else if (state.nextElMove == "next_sibling") {
+                            el = el && el.nextSibling;
+                        }
+
+                        let ok = el && el.nodeType == nodeType;
+                        if (ok && nodeType == 1) {
+                            ok = el.nodeName.toUpperCase() == nodeName.toUpperCase();
+                            // if the server wrote a position marker it must agree with ours
+                            let marker = el.getAttribute("data-vgpos");
+                            if (ok && marker != null && marker != positionID) {
+                                ok = false;
+                            }
+                        }
+
+                        if (!ok) {
+                            let found = "(none)";
+                            if (el) {
+                                found = el.nodeName.toLowerCase();
+                                if (el.nodeType == 1 && el.getAttribute("data-vgpos") != null) {
+                                    found += "[data-vgpos=" + el.getAttribute("data-vgpos") + "]";
+                                }
+                            }
+                            state.hydrateMismatches = state.hydrateMismatches || [];
+                            state.hydrateMismatches.push({
+                                "position_id": positionID,
+                                "expected": nodeName,
+                                "found": found,
+                            });
+                        }
+
+                        break;
+                    }
+
+                    // remove a keyed child which is no longer in the output, it is selected by key
+                    // rather than position so the other keyed children are left alone
+                    case opcodeRemoveKeyed: {
+
+                        let key = decoder.readString();
+
+                        /*DEBUG*/ console.log("opcodeRemoveKeyed", key);
+
+                        let el = state.el;
+                        if (!el || state.nextElMove) {
+                            throw "opcodeRemoveKeyed must be called with the parent element selected";
+                        }
+                        for (let c = el.firstChild; c; c = c.nextSibling) {
+                            if (c.vuguKey === key) {
+                                el.removeChild(c);
+                                break;
+                            }
+                        }
+
+                        break;
+                    }
+
+                    // bring the node with the key into the position that the next opcodeSetElement will use,
+                    // so the existing DOM node (with its focus, selection, etc.) follows the data
+                    case opcodeMoveKeyed: {
+
+                        let key = decoder.readString();
+
+                        /*DEBUG*/ console.log("opcodeMoveKeyed", key);
+
+                        let parent, slot;
+                        if (state.nextElMove == "first_child") {
+                            parent = state.el;
+                            slot = parent.firstChild;
+                        } else if (state.nextElMove == "next_sibling") {
+                            parent = state.el.parentNode;
+                            slot = state.el.nextSibling;
+                        } else {
+                            throw "opcodeMoveKeyed must follow opcodeMoveToFirstChild or opcodeMoveToNextSibling";
+                        }
+
+                        state.pendingKey = key;
+
+                        if (slot && slot.vuguKey === key) {
+                            break; // already in place
+                        }
+
+                        // nodes before the slot have already been synced, so only look after it
+                        let found = null;
+                        for (let c = slot; c; c = c.nextSibling) {
+                            if (c.vuguKey === key) {
+                                found = c;
+                                break;
+                            }
+                        }
+
+                        if (found) {
+                            parent.insertBefore(found, slot);
+                        } else if (slot && slot.vuguKey !== undefined) {
+                            // the slot belongs to another key which may still be needed, so put a placeholder
+                            // in front of it for opcodeSetElement to replace with the new element
+                            parent.insertBefore(document.createComment(""), slot);
+                        }
+
+                        break;
+                    }
+
+                    // select the node which would be synced next without changing it, the Go side
+                    // knows it has the same output as the last render
+                    case opcodeSkipNode: {
+
+                        /*DEBUG*/ console.log("opcodeSkipNode");
+
+                        if (state.nextElMove == "first_child") {
+                            state.el = state.el.firstChild;
+                        } else if (state.nextElMove == "next_sibling") {
+                            state.el = state.el.nextSibling;
+                        }
+                        state.nextElMove = null;
+                        state.pendingKey = undefined;
+
+                        if (!state.el) {
+                            throw "opcodeSkipNode: no node to skip";
+                        }
+
+                        break;
+                    }
+
+                    // like opcodeSkipNode but for a run of siblings, the last of them is selected afterward
+                    case opcodeSkipNodes: {
+
+                        let count = decoder.readUint32();
+
+                        /*DEBUG*/ console.log("opcodeSkipNodes", count);
+
+                        if (state.nextElMove == "first_child") {
+                            state.el = state.el.firstChild;
+                        } else if (state.nextElMove == "next_sibling") {
+                            state.el = state.el.nextSibling;
+                        }
+                        state.nextElMove = null;
+                        state.pendingKey = undefined;
+
+                        for (let i = 1; i < count && state.el; i++) {
+                            state.el = state.el.nextSibling;
+                        }
+
+                        if (!state.el) {
+                            throw "opcodeSkipNodes: not enough nodes to skip";
+                        }
+
+                        break;
+                    }
+
+                    // remove an attribute which was set in the last render but is not now, the Go side
+                    // sends this instead of opcodeRemoveOtherAttrs when it knows which attributes the element has
+                    case opcodeRemoveAttr: {
+
+                        let attrNS = decoder.readString();
+                        let attrName = decoder.readString();
+
+                        /*DEBUG*/ console.log("opcodeRemoveAttr", attrNS, attrName);
+
+                        if (!state.el) {
+                            throw "no element selected";
+                        }
+
+                        if (state.nextElMove) {
+                            throw "cannot call opcodeRemoveAttr when nextElMove is set";
+                        }
+
+                        // namespaced attributes were set with setAttributeNS and must be removed by namespace and local name
+                        if (attrNS) {
+                            state.el.removeAttributeNS(attrNS, attrName);
+                        } else {
+                            state.el.removeAttribute(attrName);
+                        }
+
+                        break;
+                    }
+
+                    // select the element which holds the children of a portal, it is a child of the target
+                    // element with display: contents so it does not affect the layout
+                    case opcodeSelectPortal: {
+
+                        let selector = decoder.readString();
+                        let portalID = decoder.readString();
+
+                        /*DEBUG*/ console.log("opcodeSelectPortal", selector, portalID);
+
+                        let target = document.querySelector(selector);
+                        if (!target) {
+                            throw "opcodeSelectPortal: target element not found for selector " + JSON.stringify(selector);
+                        }
+
+                        let el = null;
+                        for (let c = target.firstElementChild; c; c = c.nextElementSibling) {
+                            if (c.getAttribute("data-vg-portal") === portalID) {
+                                el = c;
+                                break;
+                            }
+                        }
+                        if (!el) {
+                            el = document.createElement("div");
+                            el.setAttribute("data-vg-portal", portalID);
+                            el.setAttribute("style", "display: contents");
+                            target.appendChild(el);
+                        }
+
+                        state.el = el;
+                        state.nextElMove = null;
+
+                        break;
+                    }
+
+                    // remove the element which holds the children of a portal which is no longer in the output
+                    case opcodeRemovePortal: {
+
+                        let selector = decoder.readString();
+                        let portalID = decoder.readString();
+
+                        /*DEBUG*/ console.log("opcodeRemovePortal", selector, portalID);
+
+                        let target = document.querySelector(selector);
+                        if (target) {
+                            for (let c = target.firstElementChild; c; c = c.nextElementSibling) {
+                                if (c.getAttribute("data-vg-portal") === portalID) {
+                                    target.removeChild(c);
+                                    break;
+                                }
+                            }
+                        }
+
+                        break;
+                    }
+
                     default: {
                         console.error("found invalid opcode", opcode);
                         return;
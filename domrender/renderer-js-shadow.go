@@ -0,0 +1,347 @@
+package domrender
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"strings"
+
+	"github.com/vugu/vugu"
+)
+
+// The renderer keeps a shadow of what it last wrote to the DOM: for each node its type, name (or text),
+// key, attributes and event listeners, plus a hash of the output for it and everything under it.
+// When syncing, the shadow node for the DOM node about to be synced is known, and so:
+//
+//   - a node (and its children) whose output hashes the same as last time is skipped entirely
+//   - an element whose name is the same only gets instructions for the attributes that changed, and its
+//     event listeners are left alone if they are the same and at the same positionID
+//
+// which makes the size of the instructions for a render proportional to what changed rather than to the
+// size of the page.  JS properties are always written, since the DOM changes them (e.g. an input's value),
+// and output with vg-js-create, vg-js-populate, vg-ref or a portal in it is never skipped.  The shadow
+// is only trusted for nodes the renderer has synced itself, e.g. not while hydrating or after vg-html,
+// and changes made to attributes by other JS code are not undone unless the attribute changes in the output.
+
+// shadowNode is what the renderer last wrote to a DOM node
+type shadowNode struct {
+	typ      vugu.VGNodeType
+	data     string // element name or text content
+	ns       string
+	key      string // the key the JS side has for the element, see opcodeMoveKeyed
+	hash     uint64 // see hashNode
+	pos      string // positionID the event listeners were registered with
+	attrs    []vugu.VGAttribute
+	events   string // see eventSignature
+	children []*shadowNode
+}
+
+// sameElement returns true if old is an element that the JS side will reuse when syncing n
+func sameElement(old *shadowNode, n *vugu.VGNode) bool {
+	return old != nil && old.typ == vugu.ElementNode && strings.EqualFold(old.data, n.Data) && old.ns == n.Namespace
+}
+
+// shadowCursor follows the children of an element as they are synced, starting with the shadow from
+// the last render and doing the same removals and moves as the JS side, so the shadow of the DOM node
+// about to be synced is known.  A nil shadow means the node is not known and must be synced in full.
+type shadowCursor struct {
+	old        []*shadowNode // the remaining children from the last render, the first is next
+	out        []*shadowNode // the children synced so far
+	pendingKey string        // the key from the last moveKeyed, for the next child
+}
+
+func newShadowCursor(old []*shadowNode) *shadowCursor {
+	// old is shared with the last render's shadow, which may still be referenced by skipped nodes
+	return &shadowCursor{old: append([]*shadowNode(nil), old...)}
+}
+
+// peek returns the shadow of the node about to be synced
+func (c *shadowCursor) peek() *shadowNode {
+	if len(c.old) == 0 {
+		return nil
+	}
+	return c.old[0]
+}
+
+// put records sn as the node just synced and moves on to the next
+func (c *shadowCursor) put(sn *shadowNode) {
+	if len(c.old) > 0 {
+		c.old = c.old[1:]
+	}
+	c.out = append(c.out, sn)
+	c.pendingKey = ""
+}
+
+// forget marks all of the remaining children as not known
+func (c *shadowCursor) forget() {
+	for i := range c.old {
+		c.old[i] = nil
+	}
+}
+
+// known returns true if all of the remaining children are known, the JS side finds keyed elements
+// among all of them
+func (c *shadowCursor) known() bool {
+	for _, sn := range c.old {
+		if sn == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// removeKeyed does the same as opcodeRemoveKeyed
+func (c *shadowCursor) removeKeyed(key string) {
+	if !c.known() {
+		c.forget()
+		return
+	}
+	for i, sn := range c.old {
+		if sn.key == key {
+			c.old = append(c.old[:i], c.old[i+1:]...)
+			return
+		}
+	}
+}
+
+// moveKeyed does the same as opcodeMoveKeyed
+func (c *shadowCursor) moveKeyed(key string) {
+	c.pendingKey = key
+	if !c.known() {
+		c.forget()
+		return
+	}
+	for i, sn := range c.old {
+		if sn.key == key {
+			copy(c.old[1:i+1], c.old[:i])
+			c.old[0] = sn
+			return
+		}
+	}
+	if len(c.old) > 0 && c.old[0].key != "" {
+		// a placeholder comment is put in front of the slot
+		c.old = append([]*shadowNode{{typ: vugu.CommentNode}}, c.old...)
+	}
+}
+
+// nodeHash is the hash of the output for a node and everything under it, see hashNode
+type nodeHash struct {
+	hash      uint64
+	noSkip    bool // there is output which must be synced on every render (properties, JS callbacks, refs, portals)
+	hasEvents bool // there are event listeners, which are registered by positionID
+}
+
+// hashNode returns the nodeHash for n, with components replaced by their output.
+// The results are kept for the rest of the render so each node is only hashed once.
+func (r *JSRenderer) hashNode(state *jsRenderState, br *vugu.BuildResults, n *vugu.VGNode) nodeHash {
+
+	if nh, ok := state.hashes[n]; ok {
+		return nh
+	}
+
+	var nh nodeHash
+
+	if n.Component != nil {
+		bo := br.ResultFor(n.Component)
+		if bo == nil || len(bo.Out) != 1 {
+			nh.noSkip = true
+		} else {
+			nh = r.hashNode(state, br, bo.Out[0])
+		}
+		state.hashes[n] = nh
+		return nh
+	}
+
+	var h maphash.Hash
+	h.SetSeed(state.hashSeed)
+	var b [8]byte
+	writeUint := func(v uint64) {
+		binary.LittleEndian.PutUint64(b[:], v)
+		h.Write(b[:])
+	}
+	writeString := func(s string) {
+		writeUint(uint64(len(s)))
+		h.WriteString(s)
+	}
+	writeBool := func(v bool) {
+		if v {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	}
+
+	writeUint(uint64(n.Type))
+	writeString(n.Data)
+	writeString(n.Namespace)
+	writeString(n.Key)
+
+	writeUint(uint64(len(n.Attr)))
+	for _, a := range n.Attr {
+		writeString(a.Namespace)
+		writeString(a.Key)
+		writeString(a.Val)
+	}
+
+	writeBool(n.InnerHTML != nil)
+	if n.InnerHTML != nil {
+		writeString(*n.InnerHTML)
+	}
+
+	writeUint(uint64(len(n.DOMEventHandlerSpecList)))
+	for i := range n.DOMEventHandlerSpecList {
+		hs := &n.DOMEventHandlerSpecList[i]
+		writeString(hs.EventType)
+		writeBool(hs.Capture)
+		writeBool(hs.Passive)
+		writeString(eventModifierKey(hs))
+	}
+	nh.hasEvents = len(n.DOMEventHandlerSpecList) > 0
+
+	nh.noSkip = len(n.Prop) > 0 || n.JSCreateHandler != nil || n.JSPopulateHandler != nil || n.Ref != nil || n.IsPortal()
+
+	for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
+		ch := r.hashNode(state, br, nchild)
+		writeUint(ch.hash)
+		nh.noSkip = nh.noSkip || ch.noSkip
+		nh.hasEvents = nh.hasEvents || ch.hasEvents
+	}
+
+	nh.hash = h.Sum64()
+	state.hashes[n] = nh
+	return nh
+}
+
+// eventSignature returns a string which is the same for two lists of event listeners that are
+// registered the same way on the JS side
+func eventSignature(specs []vugu.DOMEventHandlerSpec) string {
+	if len(specs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i := range specs {
+		hs := &specs[i]
+		fmt.Fprintf(&sb, "%s|%t|%t|%s\n", hs.EventType, hs.Capture, hs.Passive, eventModifierKey(hs))
+	}
+	return sb.String()
+}
+
+// findAttr returns the value of the attribute with the namespace and key, the last one if it is repeated
+func findAttr(attrs []vugu.VGAttribute, ns, key string) (string, bool) {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Namespace == ns && attrs[i].Key == key {
+			return attrs[i].Val, true
+		}
+	}
+	return "", false
+}
+
+// trySkip checks if n, the child about to be synced at positionID, can be left as it is in the DOM.
+// If so the state for it is carried over to the next render and true is returned, the caller must
+// write opcodeSkipNode (or opcodeSkipNodes) for it.
+func (r *JSRenderer) trySkip(state *jsRenderState, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte, cur *shadowCursor) bool {
+
+	if r.hydrating {
+		return false
+	}
+
+	old := cur.peek()
+
+	// a component whose output is known to be unchanged, this doesn't need the hash
+	if n.Component != nil {
+		compBuildOut := br.ResultFor(n.Component)
+		if len(compBuildOut.Out) == 1 && r.canSkip(state, br, n.Component, compBuildOut, positionID) {
+			r.counts.SkippedSubtrees++
+			r.keepSkipped(state, br, n.Component)
+			cur.put(old)
+			return true
+		}
+	}
+
+	if old == nil || old.key != cur.pendingKey {
+		return false
+	}
+
+	// only a single node can be skipped, not a template
+	rn := n
+	for rn.Component != nil {
+		bo := br.ResultFor(rn.Component)
+		if len(bo.Out) != 1 {
+			return false
+		}
+		rn = bo.Out[0]
+	}
+	if rn.IsTemplate() {
+		return false
+	}
+
+	nh := r.hashNode(state, br, n)
+	if nh.noSkip || nh.hash != old.hash || (nh.hasEvents && old.pos != string(positionID)) {
+		return false
+	}
+
+	r.counts.SkippedNodes++
+	r.keepNode(state, br, n, positionID)
+	cur.put(old)
+	return true
+}
+
+// keepNode records the state for n, which was skipped by trySkip, for the next render
+func (r *JSRenderer) keepNode(state *jsRenderState, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte) {
+
+	if n.Component != nil {
+		r.counts.Components++
+		r.counts.SkippedComponents++
+		state.nextRenderedAt[n.Component] = string(positionID)
+		r.keepNode(state, br, br.ResultFor(n.Component).Out[0], positionID)
+		return
+	}
+
+	if n.IsTemplate() {
+		childIndex := 1
+		for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
+			r.keepNode(state, br, nchild, append(positionID, fmt.Appendf(nil, "_t_%d", childIndex)...))
+			childIndex++
+		}
+		return
+	}
+
+	if len(n.DOMEventHandlerSpecList) > 0 {
+		state.domHandlerMap[string(positionID)] = n.DOMEventHandlerSpecList
+	}
+
+	if n.InnerHTML != nil {
+		return
+	}
+
+	// the same keys that syncKeyedChildren records
+	var keys []string
+	var keyed map[string]bool
+	for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
+		if nchild.Key == "" || keyed[nchild.Key] {
+			continue
+		}
+		if keyed == nil {
+			keyed = make(map[string]bool)
+		}
+		keyed[nchild.Key] = true
+		keys = append(keys, nchild.Key)
+	}
+	if len(keys) > 0 {
+		state.nextKeyedChildren[string(positionID)] = keys
+	}
+
+	childIndex := 1
+	for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
+		r.keepNode(state, br, nchild, append(positionID, fmt.Appendf(nil, "_%d", childIndex)...))
+		childIndex++
+	}
+}
+
+// writeSkips writes the instruction to skip count nodes
+func (r *JSRenderer) writeSkips(count uint32) error {
+	if count == 1 {
+		return r.instructionList.writeSkipNode()
+	}
+	return r.instructionList.writeSkipNodes(count)
+}
@@ -1,6 +1,11 @@
 package domrender
 
-import "github.com/vugu/vugu"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/vugu/vugu"
+)
 
 // namespaceToURI resolves the given namespaces to the URI with the specifications
 func namespaceToURI(namespace string) string {
@@ -22,6 +27,85 @@ func namespaceToURI(namespace string) string {
 	}
 }
 
+// event modifier flags, sent with opcodeSetEventListener
+const (
+	eventFlagPreventDefault uint8 = 1 << iota
+	eventFlagStopPropagation
+	eventFlagOnce
+	eventFlagSelf
+)
+
+// eventModifiers returns the modifier flags and keys for a handler spec
+func eventModifiers(hs *vugu.DOMEventHandlerSpec) (flags uint8, keys string) {
+	if hs.PreventDefault {
+		flags |= eventFlagPreventDefault
+	}
+	if hs.StopPropagation {
+		flags |= eventFlagStopPropagation
+	}
+	if hs.Once {
+		flags |= eventFlagOnce
+	}
+	if hs.Self {
+		flags |= eventFlagSelf
+	}
+	return flags, strings.Join(hs.Keys, ",")
+}
+
+// eventModifierKey returns a string which distinguishes handlers for the same event type with different modifiers.
+// It must be the same as what is computed on the JS side in opcodeSetEventListener.
+func eventModifierKey(hs *vugu.DOMEventHandlerSpec) string {
+	flags, keys := eventModifiers(hs)
+	return strconv.Itoa(int(flags)) + ":" + keys
+}
+
+// eventKey returns a string which identifies a listener on an element, the event type and phase and eventModifierKey.
+// It must be the same as what is computed on the JS side in opcodeSetEventListener.
+func eventKey(hs *vugu.DOMEventHandlerSpec) string {
+	return hs.EventType + "|" + boolDigit(hs.Capture) + "|" + boolDigit(hs.Passive) + "|" + eventModifierKey(hs)
+}
+
+func boolDigit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// EventBubbles returns false for the event types which do not bubble in the browser (focus, blur,
+// mouseenter and the like).  These always get a listener on their element, see JSRenderer.SetEventDelegation.
+func EventBubbles(eventType string) bool {
+	return !nonBubblingEvents[eventType]
+}
+
+// nonBubblingEvents are the event types which do not bubble up to the mount point, see EventBubbles
+var nonBubblingEvents = map[string]bool{
+	"focus":          true,
+	"blur":           true,
+	"mouseenter":     true,
+	"mouseleave":     true,
+	"pointerenter":   true,
+	"pointerleave":   true,
+	"load":           true,
+	"unload":         true,
+	"error":          true,
+	"abort":          true,
+	"scroll":         true,
+	"invalid":        true,
+	"toggle":         true,
+	"play":           true,
+	"pause":          true,
+	"ended":          true,
+	"timeupdate":     true,
+	"volumechange":   true,
+	"loadeddata":     true,
+	"loadedmetadata": true,
+	"canplay":        true,
+	"canplaythrough": true,
+	"seeked":         true,
+	"seeking":        true,
+}
+
 type renderedCtx struct {
 	eventEnv vugu.EventEnv
 	first    bool
@@ -5,9 +5,12 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/maphash"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/vugu/vjson"
 
@@ -109,11 +112,61 @@ type jsRenderState struct {
 
 	// callback stuff is handled by callbackManager
 	callbackManager callbackManager
+
+	// stores positionID of parent to the keys of its keyed children from the last render,
+	// and for the render in progress
+	keyedChildren     map[string][]string
+	nextKeyedChildren map[string][]string
+
+	// stores the positionID each component was rendered at in the last render,
+	// and for the render in progress
+	renderedAt     map[any]string
+	nextRenderedAt map[any]string
+
+	// portals found in the render in progress, which are synced after the main output
+	portals []pendingPortal
+
+	// true while elements which are not under the mount point are synced, i.e. html, head and body
+	// and the children of portals, events on these do not go through the mount point so are not delegated
+	outsideMount bool
+
+	// stores the positionID of each portal with children to its target selector from the last render,
+	// and for the render in progress
+	renderedPortals     map[string]string
+	nextRenderedPortals map[string]string
+
+	// stores each vg-ref to the positionID of its element from the last render,
+	// and for the render in progress
+	refs     map[*js.Element]string
+	nextRefs map[*js.Element]string
+
+	// stores the shadow of what was last written to the DOM (see renderer-js-shadow.go) for the
+	// html, head and body tags and the mount point by name, and for the render in progress
+	shadow     map[string]*shadowNode
+	nextShadow map[string]*shadowNode
+
+	// stores the positionID of each portal with children to the shadow of its children from the last render,
+	// and for the render in progress
+	portalShadow     map[string][]*shadowNode
+	nextPortalShadow map[string][]*shadowNode
+
+	// hashes of the nodes in the render in progress, see hashNode
+	hashes   map[*vugu.VGNode]nodeHash
+	hashSeed maphash.Seed
+}
+
+// pendingPortal is a portal whose children are synced into its target after the main output
+type pendingPortal struct {
+	bo         *vugu.BuildOut
+	n          *vugu.VGNode
+	positionID string
 }
 
 func newJsRenderState() *jsRenderState {
 	return &jsRenderState{
 		domHandlerMap: make(map[string][]vugu.DOMEventHandlerSpec, 8),
+		hashes:        make(map[*vugu.VGNode]nodeHash),
+		hashSeed:      maphash.MakeSeed(),
 	}
 }
 
@@ -140,6 +193,39 @@ type JSRenderer struct {
 	// manages the Rendered lifecycle callback stuff
 	lifecycleStateMap map[any]lifecycleState
 	lifecyclePassNum  uint8
+
+	hydrate             bool // adopt existing server-rendered DOM on the first render
+	hydrating           bool // true only during the render that performs hydration
+	hydrated            bool // the hydration render has been done
+	hydrationMismatches []HydrationMismatch
+
+	delegateEvents bool // see SetEventDelegation
+
+	counts RenderCounts
+	stats  RenderStats
+	tracer RenderTracer
+}
+
+// RenderCounts reports how much of a render was skipped because the output was unchanged.
+// A component's subtree is skipped when its BuildOut and those of all the components under it
+// were reused by the BuildEnv (see vugu.BuildResults.Reused) and it is at the same position as the last render.
+// Any other node is skipped when its output, including everything under it, is the same as what the
+// renderer last wrote to the DOM node in its place.
+// No instructions are written for a skipped subtree, so vg-js-populate callbacks are not called in it either.
+type RenderCounts struct {
+	Components        int // components in the render, including those in skipped subtrees and nodes
+	SkippedComponents int // components in skipped subtrees and nodes
+	SkippedSubtrees   int // number of component subtrees skipped
+	SkippedNodes      int // number of other nodes skipped, with their children
+}
+
+// HydrationMismatch describes a place where the existing DOM did not match the first render
+// when hydrating.  The existing node is replaced in the same way as any other render would,
+// so a mismatch is not fatal, but it usually means the server and client disagree about the output.
+type HydrationMismatch struct {
+	PositionID string // position of the node that did not match
+	Expected   string // the node the renderer was about to sync, e.g. "div" or "#text"
+	Found      string // the node that was found in the DOM, or "(none)"
 }
 
 type lifecycleState struct {
@@ -151,6 +237,36 @@ func (r *JSRenderer) EventEnv() vugu.EventEnv {
 	return r.eventEnv
 }
 
+// SetHydrate enables or disables hydration.  When enabled, the first render adopts the DOM
+// already present under the mount point (typically produced by staticrender with hydration
+// markers turned on) instead of rebuilding it, attaching event listeners and checking that each
+// node matches what is being rendered.  Must be called before the first Render.
+func (r *JSRenderer) SetHydrate(v bool) {
+	r.hydrate = v
+}
+
+// SetEventDelegation enables or disables delegated event listeners.  When enabled, instead of adding a
+// listener to each element with an @event handler, one listener per event type is added at the mount
+// point and it dispatches each event to the handlers of the elements it went through, in the same order
+// and with the same modifiers (including .capture and .stop).  This is much cheaper for long lists with
+// a handler on each row.  Events which do not bubble (focus, blur, mouseenter and the like) and elements
+// whose events do not go through the mount point (html, head, body and those inside of a portal) still
+// get their own listeners.
+// Must be called before the first Render.
+func (r *JSRenderer) SetEventDelegation(v bool) {
+	r.delegateEvents = v
+}
+
+// HydrationMismatches returns the mismatches found during the hydration render, if any.
+func (r *JSRenderer) HydrationMismatches() []HydrationMismatch {
+	return r.hydrationMismatches
+}
+
+// LastRenderCounts returns the counts for the most recent render.
+func (r *JSRenderer) LastRenderCounts() RenderCounts {
+	return r.counts
+}
+
 // Release calls release on any resources that this renderer allocated.
 func (r *JSRenderer) Release() {
 	// NOTE: seems sensible to leave this here in case we do need something to be released, better than
@@ -162,10 +278,6 @@ func (r *JSRenderer) render(buildResults *vugu.BuildResults) error {
 
 	bo := buildResults.Out
 
-	if !js.Global().Truthy() {
-		return errors.New("js environment not available")
-	}
-
 	if bo == nil {
 		return errors.New("BuildOut is nil")
 	}
@@ -178,6 +290,9 @@ func (r *JSRenderer) render(buildResults *vugu.BuildResults) error {
 		return errors.New("BuildOut.Out[0].Type is not vugu.ElementNode: " + strconv.Itoa(int(bo.Out[0].Type)))
 	}
 
+	start := time.Now()
+	r.instructionList.resetStats()
+
 	// always make sure we have at least a non-nil render state
 	if r.jsRenderState == nil {
 		r.jsRenderState = newJsRenderState()
@@ -188,6 +303,18 @@ func (r *JSRenderer) render(buildResults *vugu.BuildResults) error {
 	state.callbackManager.startRender()
 	defer state.callbackManager.doneRender()
 
+	r.hydrating = r.hydrate && !r.hydrated
+
+	state.nextKeyedChildren = make(map[string][]string)
+	state.nextRenderedAt = make(map[any]string)
+	state.nextRenderedPortals = make(map[string]string)
+	state.nextRefs = make(map[*js.Element]string)
+	state.nextShadow = make(map[string]*shadowNode)
+	state.nextPortalShadow = make(map[string][]*shadowNode)
+	clear(state.hashes)
+	state.portals = state.portals[:0]
+	r.counts = RenderCounts{}
+
 	// TODO: move this next chunk out to it's own func at least
 
 	visitCSSList := func(cssList []*vugu.VGNode) error {
@@ -258,6 +385,16 @@ func (r *JSRenderer) render(buildResults *vugu.BuildResults) error {
 	if err != nil {
 		return err
 	}
+	err = r.visitPortals(state, buildResults)
+	if err != nil {
+		return err
+	}
+	state.keyedChildren = state.nextKeyedChildren
+	state.renderedAt = state.nextRenderedAt
+	state.renderedPortals = state.nextRenderedPortals
+	state.shadow = state.nextShadow
+	state.portalShadow = state.nextPortalShadow
+	clear(state.hashes) // don't hold on to the nodes
 
 	// // JS stuff last
 	// // log.Printf("TODO: handle JS")
@@ -267,6 +404,25 @@ func (r *JSRenderer) render(buildResults *vugu.BuildResults) error {
 		return err
 	}
 
+	// the refs were assigned during the flush, clear the ones whose elements are gone
+	for ref := range state.refs {
+		if _, ok := state.nextRefs[ref]; !ok {
+			*ref = js.Element{}
+		}
+	}
+	state.refs = state.nextRefs
+
+	if r.hydrating {
+		r.hydrating = false
+		r.hydrated = true
+		err = r.readHydrationMismatches()
+		if err != nil {
+			return err
+		}
+	}
+
+	r.finishStats(start)
+
 	// handle Rendered lifecycle callback
 	if r.lifecycleStateMap == nil {
 		r.lifecycleStateMap = make(map[any]lifecycleState, len(bo.Components))
@@ -386,7 +542,10 @@ func (r *JSRenderer) syncHtml(state *jsRenderState, n *vugu.VGNode, positionID [
 	if err != nil {
 		return err
 	}
-	return r.syncElement(state, n, positionID)
+	state.outsideMount = true
+	state.nextShadow["html"], err = r.syncElement(state, n, positionID, r.oldShadow(state, "html"))
+	state.outsideMount = false
+	return err
 }
 
 func (r *JSRenderer) visitHead(state *jsRenderState, bo *vugu.BuildOut, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte) error {
@@ -395,7 +554,9 @@ func (r *JSRenderer) visitHead(state *jsRenderState, bo *vugu.BuildOut, br *vugu
 	if err != nil {
 		return err
 	}
-	err = r.syncElement(state, n, positionID)
+	state.outsideMount = true
+	state.nextShadow["head"], err = r.syncElement(state, n, positionID, r.oldShadow(state, "head"))
+	state.outsideMount = false
 	if err != nil {
 		return err
 	}
@@ -409,7 +570,9 @@ func (r *JSRenderer) visitBody(state *jsRenderState, bo *vugu.BuildOut, br *vugu
 	if err != nil {
 		return err
 	}
-	err = r.syncElement(state, n, positionID)
+	state.outsideMount = true
+	state.nextShadow["body"], err = r.syncElement(state, n, positionID, r.oldShadow(state, "body"))
+	state.outsideMount = false
 	if err != nil {
 		return err
 	}
@@ -425,16 +588,43 @@ func (r *JSRenderer) visitMount(state *jsRenderState, bo *vugu.BuildOut, br *vug
 
 	// log.Printf("visitMount got here")
 
+	// the mount point is checked before it is selected, since selecting it replaces it if the element name is different
+	if r.hydrating {
+		err := r.instructionList.writeSelectQuery(r.MountPointSelector)
+		if err != nil {
+			return err
+		}
+		err = r.writeHydrateExpect(n, positionID)
+		if err != nil {
+			return err
+		}
+	}
+
 	err := r.instructionList.writeSelectMountPoint(r.MountPointSelector, n.Data)
 	if err != nil {
 		return err
 	}
 
-	return r.visitSyncElementEtc(state, bo, br, n, positionID)
+	// the mount point is replaced if the element name is different
+	old := r.oldShadow(state, "mount")
+	if !sameElement(old, n) {
+		old = nil
+	}
+	state.nextShadow["mount"], err = r.visitSyncElementEtc(state, bo, br, n, positionID, old)
+	return err
+
+}
 
+// oldShadow returns the shadow from the last render for the html, head or body tag or the mount point,
+// or nil if it is not known
+func (r *JSRenderer) oldShadow(state *jsRenderState, name string) *shadowNode {
+	if r.hydrating {
+		return nil
+	}
+	return state.shadow[name]
 }
 
-func (r *JSRenderer) visitSyncNode(state *jsRenderState, bo *vugu.BuildOut, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte) error {
+func (r *JSRenderer) visitSyncNode(state *jsRenderState, bo *vugu.BuildOut, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte, cur *shadowCursor) error {
 
 	// log.Printf("visitSyncNode")
 
@@ -447,39 +637,89 @@ func (r *JSRenderer) visitSyncNode(state *jsRenderState, bo *vugu.BuildOut, br *
 			return fmt.Errorf("component %#v expected exactly one Out element but got %d instead",
 				n.Component, len(compBuildOut.Out))
 		}
-		return r.visitSyncNode(state, compBuildOut, br, compBuildOut.Out[0], positionID)
+		r.counts.Components++
+		state.nextRenderedAt[n.Component] = string(positionID)
+		return r.visitSyncNode(state, compBuildOut, br, compBuildOut.Out[0], positionID, cur)
+	}
+
+	// check for portal, a placeholder is output here and the children are synced into the target by visitPortals
+	if n.IsPortal() {
+		err = r.writeHydrateExpect(&vugu.VGNode{Type: vugu.CommentNode}, positionID)
+		if err != nil {
+			return err
+		}
+		state.portals = append(state.portals, pendingPortal{bo: bo, n: n, positionID: string(positionID)})
+		cur.put(&shadowNode{typ: vugu.CommentNode, data: portalComment})
+		return r.instructionList.writeSetComment(portalComment)
 	}
 
 	// check for template (used by vg-template and vg-slot) in which case we process the children directly and ignore n
 	if n.IsTemplate() {
 
+		var skips uint32
+		started := false // a child has been synced or skipped, the next one is its next sibling
 		childIndex := 1
 		for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
 
 			// use a different character here for the position to ensure it's unique
 			childPositionID := append(positionID, fmt.Appendf(nil, "_t_%d", childIndex)...)
+			childIndex++
 
-			err = r.visitSyncNode(state, bo, br, nchild, childPositionID)
-			if err != nil {
-				return err
+			if !r.hasDOMNodes(br, nchild) {
+				err = r.visitSyncNode(state, bo, br, nchild, childPositionID, cur)
+				if err != nil {
+					return err
+				}
+				continue
 			}
 
-			// if there are more children, advance to the next
-			if nchild.NextSibling != nil {
+			if r.trySkip(state, br, nchild, childPositionID, cur) {
+				if started && skips == 0 {
+					err = r.instructionList.writeMoveToNextSibling()
+					if err != nil {
+						return err
+					}
+				}
+				started = true
+				skips++
+				continue
+			}
+			if skips > 0 {
+				err = r.writeSkips(skips)
+				if err != nil {
+					return err
+				}
+				skips = 0
+			}
+			if started {
 				err = r.instructionList.writeMoveToNextSibling()
 				if err != nil {
 					return err
 				}
+			}
+			started = true
 
+			err = r.visitSyncNode(state, bo, br, nchild, childPositionID, cur)
+			if err != nil {
+				return err
 			}
+		}
 
-			childIndex++
+		if skips > 0 {
+			return r.writeSkips(skips)
 		}
 
 		// element is fully handled
 		return nil
 	}
 
+	err = r.writeHydrateExpect(n, positionID)
+	if err != nil {
+		return err
+	}
+
+	old := cur.peek()
+
 	switch n.Type {
 	case vugu.ElementNode:
 		// check if this element has a namespace set
@@ -491,78 +731,363 @@ func (r *JSRenderer) visitSyncNode(state *jsRenderState, bo *vugu.BuildOut, br *
 		if err != nil {
 			return err
 		}
+		// otherwise the JS side replaces the node with a new element
+		if !sameElement(old, n) {
+			old = nil
+		}
 	case vugu.TextNode:
+		cur.put(&shadowNode{typ: vugu.TextNode, data: n.Data, hash: r.hashNode(state, br, n).hash})
 		return r.instructionList.writeSetText(n.Data) // no children possible, just return
 	case vugu.CommentNode:
+		cur.put(&shadowNode{typ: vugu.CommentNode, data: n.Data, hash: r.hashNode(state, br, n).hash})
 		return r.instructionList.writeSetComment(n.Data) // no children possible, just return
 	default:
 		return errors.New("unknown node type: " + strconv.Itoa(int(n.Type)))
 	}
 
 	// only elements have attributes, child or events
-	return r.visitSyncElementEtc(state, bo, br, n, positionID)
+	sn, err := r.visitSyncElementEtc(state, bo, br, n, positionID, old)
+	if err != nil {
+		return err
+	}
+	sn.key = cur.pendingKey
+	cur.put(sn)
+	return nil
 
 }
 
-// visitSyncElementEtc syncs the rest of the stuff that only applies to elements
-func (r *JSRenderer) visitSyncElementEtc(state *jsRenderState, bo *vugu.BuildOut, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte) error {
+// visitSyncElementEtc syncs the rest of the stuff that only applies to elements.
+// old is the shadow of the element from the last render if the JS side reused it, and the shadow
+// for this render is returned.
+func (r *JSRenderer) visitSyncElementEtc(state *jsRenderState, bo *vugu.BuildOut, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte, old *shadowNode) (*shadowNode, error) {
 
-	err := r.syncElement(state, n, positionID)
+	sn, err := r.syncElement(state, n, positionID, old)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	sn.hash = r.hashNode(state, br, n).hash
 
 	if n.InnerHTML != nil {
-		return r.instructionList.writeSetInnerHTML(*n.InnerHTML)
+		err := r.instructionList.writeSetInnerHTML(*n.InnerHTML)
+		if err != nil {
+			return nil, err
+		}
+		return sn, r.writeSelectValue(n)
 	}
 
 	// tell callbackManager about the create and populate functions
 	// (if present, otherwise this is a nop and will return 0,0)
-	cid, pid := state.callbackManager.addCreateAndPopulateHandlers(n.JSCreateHandler, n.JSPopulateHandler)
+	create := n.JSCreateHandler
+	if n.Ref != nil {
+		// vg-ref is assigned by the create callback
+		create = refHandler{ref: n.Ref, next: create}
+		state.nextRefs[n.Ref] = string(positionID)
+	}
+	cid, pid := state.callbackManager.addCreateAndPopulateHandlers(create, n.JSPopulateHandler)
 
 	// for vg-js-create, send an instruction to call us back when this element is created
 	// (handled by callbackManager)
 	if cid != 0 {
 		err := r.instructionList.writeCallbackLastElement(cid)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if n.FirstChild != nil {
+		var oldChildren []*shadowNode
+		if old != nil {
+			oldChildren = old.children
+		}
+		sn.children, err = r.syncChildren(state, bo, br, n, positionID, oldChildren)
+		if err != nil {
+			return nil, err
+		}
+	} else if old != nil {
+		// any children are left as they are
+		sn.children = old.children
+	}
+
+	err = r.writeSelectValue(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// for vg-js-populate, send an instruction to call us back again with the populate flag for this same one
+	// (handled by callbackManager)
+	if pid != 0 {
+		err := r.instructionList.writeCallback(pid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sn, nil
+}
+
+// isSelectValue reports whether key is the value property of a select element, which
+// only takes effect once the option it names exists.
+func isSelectValue(n *vugu.VGNode, key string) bool {
+	return key == "value" && n.Namespace == "" && strings.EqualFold(n.Data, "select")
+}
+
+// writeSelectValue sets the value property of a select element, which syncElement leaves
+// until after its children are synced so the browser can find the selected option.
+// The select must be the current element.  It is a nop for other elements.
+func (r *JSRenderer) writeSelectValue(n *vugu.VGNode) error {
+	for _, p := range n.Prop {
+		if !isSelectValue(n, p.Key) {
+			continue
+		}
+		err := r.instructionList.writeSetProperty(p.Key, []byte(p.JSONVal))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		err = r.instructionList.writeMoveToFirstChild()
+// syncChildren syncs the children of n into the current element, which is the current element again afterward.
+// n must have at least one child.  old is the shadow of the children from the last render, if known,
+// and the shadow for this render is returned.
+func (r *JSRenderer) syncChildren(state *jsRenderState, bo *vugu.BuildOut, br *vugu.BuildResults, n *vugu.VGNode, positionID []byte, old []*shadowNode) ([]*shadowNode, error) {
+
+	cur := newShadowCursor(old)
+
+	keyed, err := r.syncKeyedChildren(state, n, positionID, cur)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.instructionList.writeMoveToFirstChild()
+	if err != nil {
+		return nil, err
+	}
+
+	// consecutive children which are skipped are written as one instruction
+	var skips uint32
+	flushSkips := func() error {
+		if skips == 0 {
+			return nil
+		}
+		err := r.writeSkips(skips)
 		if err != nil {
 			return err
 		}
+		skips = 0
+		return r.instructionList.writeMoveToNextSibling()
+	}
 
-		childIndex := 1
-		for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
+	childIndex := 1
+	for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
 
-			childPositionID := append(positionID, fmt.Appendf(nil, "_%d", childIndex)...)
+		childPositionID := append(positionID, fmt.Appendf(nil, "_%d", childIndex)...)
+		childIndex++
 
-			err = r.visitSyncNode(state, bo, br, nchild, childPositionID)
+		// nothing is output to the DOM, so there is nothing to move past
+		if !r.hasDOMNodes(br, nchild) {
+			err = r.visitSyncNode(state, bo, br, nchild, childPositionID, cur)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			// log.Printf("GOT HERE X: %#v", n)
-			err = r.instructionList.writeMoveToNextSibling()
+			continue
+		}
+
+		if keyed[nchild.Key] {
+			keyed[nchild.Key] = false // any duplicates after the first are synced by position
+			err = flushSkips()
+			if err != nil {
+				return nil, err
+			}
+			err = r.instructionList.writeMoveKeyed(nchild.Key)
+			if err != nil {
+				return nil, err
+			}
+			cur.moveKeyed(nchild.Key)
+		}
+
+		if r.trySkip(state, br, nchild, childPositionID, cur) {
+			skips++
+			continue
+		}
+		err = flushSkips()
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.visitSyncNode(state, bo, br, nchild, childPositionID, cur)
+		if err != nil {
+			return nil, err
+		}
+		// log.Printf("GOT HERE X: %#v", n)
+		err = r.instructionList.writeMoveToNextSibling()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = flushSkips()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.instructionList.writeMoveToParent()
+	if err != nil {
+		return nil, err
+	}
+
+	return cur.out, nil
+}
+
+// hasDOMNodes returns false if n is a template (or a component whose output is one) with nothing in it
+// which is output to the DOM, the instructions to move to the next sibling must not be written for such a node.
+func (r *JSRenderer) hasDOMNodes(br *vugu.BuildResults, n *vugu.VGNode) bool {
+	for n.Component != nil {
+		bo := br.ResultFor(n.Component)
+		if bo == nil || len(bo.Out) != 1 {
+			return true // this is an error, which visitSyncNode reports
+		}
+		n = bo.Out[0]
+	}
+	if !n.IsTemplate() {
+		return true
+	}
+	for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
+		if r.hasDOMNodes(br, nchild) {
+			return true
+		}
+	}
+	return false
+}
+
+// canSkip returns true if the output of the component c at positionID is the same as in the last render and so
+// the existing DOM can be left alone.  Only a single element is skipped, so output which is a template is always synced.
+func (r *JSRenderer) canSkip(state *jsRenderState, br *vugu.BuildResults, c any, bo *vugu.BuildOut, positionID []byte) bool {
+
+	if r.hydrating {
+		return false
+	}
+
+	if pos, ok := state.renderedAt[c]; !ok || pos != string(positionID) {
+		return false
+	}
+
+	root := bo.Out[0]
+	if root.Type != vugu.ElementNode || root.IsTemplate() || root.Component != nil {
+		return false
+	}
+
+	var reused func(c any) bool
+	reused = func(c any) bool {
+		if !br.Reused(c) {
+			return false
+		}
+		for _, cc := range br.ResultFor(c).Components {
+			if !reused(cc) {
+				return false
+			}
+		}
+		return true
+	}
+	return reused(c)
+}
+
+// keepSkipped carries over the state from the last render for the skipped subtree of component c
+// so it is still there for the next render.
+func (r *JSRenderer) keepSkipped(state *jsRenderState, br *vugu.BuildResults, c any) {
+
+	pos := state.renderedAt[c]
+
+	var walk func(c any)
+	walk = func(c any) {
+		r.counts.Components++
+		r.counts.SkippedComponents++
+		if p, ok := state.renderedAt[c]; ok {
+			state.nextRenderedAt[c] = p
+		}
+		for _, cc := range br.ResultFor(c).Components {
+			walk(cc)
+		}
+	}
+	walk(c)
+
+	for k, v := range state.keyedChildren {
+		if k == pos || strings.HasPrefix(k, pos+"_") {
+			state.nextKeyedChildren[k] = v
+		}
+	}
+
+	// the elements for refs in the subtree are still there
+	for ref, p := range state.refs {
+		if p == pos || strings.HasPrefix(p, pos+"_") {
+			state.nextRefs[ref] = p
+		}
+	}
+
+	// the children of portals in the subtree are left alone in their targets
+	for k, v := range state.renderedPortals {
+		if k == pos || strings.HasPrefix(k, pos+"_") {
+			state.nextRenderedPortals[k] = v
+			state.nextPortalShadow[k] = state.portalShadow[k]
+		}
+	}
+}
+
+// portalComment is the content of the comment output in place of a portal
+const portalComment = "vg-portal"
+
+// visitPortals syncs the children of the portals found during the main output into their targets,
+// and removes the children of the portals from the last render which are no longer there.
+// The children are put in an element inside the target which is identified by the portal's positionID.
+func (r *JSRenderer) visitPortals(state *jsRenderState, br *vugu.BuildResults) error {
+
+	state.outsideMount = true
+	defer func() { state.outsideMount = false }()
+
+	// portals inside of portals are appended as they are found, so check the length each time
+	for i := 0; i < len(state.portals); i++ {
+		p := state.portals[i]
+
+		if p.n.IsBlankPortal() {
+			return fmt.Errorf("vg-portal at position %s has an empty target selector", p.positionID)
+		}
+
+		// a portal with no children is the same as no portal
+		if p.n.FirstChild == nil {
+			continue
+		}
+
+		var old []*shadowNode
+		if sel, ok := state.renderedPortals[p.positionID]; ok && sel != p.n.Portal {
+			err := r.instructionList.writeRemovePortal(sel, p.positionID)
 			if err != nil {
 				return err
 			}
-			childIndex++
+		} else if !r.hydrating {
+			old = state.portalShadow[p.positionID]
+		}
+		state.nextRenderedPortals[p.positionID] = p.n.Portal
+
+		err := r.instructionList.writeSelectPortal(p.n.Portal, p.positionID)
+		if err != nil {
+			return err
 		}
 
-		err = r.instructionList.writeMoveToParent()
+		state.nextPortalShadow[p.positionID], err = r.syncChildren(state, p.bo, br, p.n, []byte(p.positionID), old)
 		if err != nil {
 			return err
 		}
 	}
 
-	// for vg-js-populate, send an instruction to call us back again with the populate flag for this same one
-	// (handled by callbackManager)
-	if pid != 0 {
-		err := r.instructionList.writeCallback(pid)
+	var removed []string
+	for k := range state.renderedPortals {
+		if _, ok := state.nextRenderedPortals[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+	for _, k := range removed {
+		err := r.instructionList.writeRemovePortal(state.renderedPortals[k], k)
 		if err != nil {
 			return err
 		}
@@ -571,57 +1096,193 @@ func (r *JSRenderer) visitSyncElementEtc(state *jsRenderState, bo *vugu.BuildOut
 	return nil
 }
 
-func (r *JSRenderer) syncElement(state *jsRenderState, n *vugu.VGNode, positionID []byte) error {
-	if namespaceToURI(n.Namespace) != "" {
-		for _, a := range n.Attr {
-			ns := namespaceToURI(a.Namespace)
-			// FIXME: we skip Namespace="" && Key = "xmlns" here, because this WILL cause an js exception
-			// the correct way would be, to parse the xmlns attribute in the generator, set the namespace of the holding element
-			// and then forget about this attribute
-			if ns == "" && a.Key == "xmlns" {
+// syncKeyedChildren records the keys of the children of n (see vugu.VGNode.Key) and removes the elements
+// for keys which were there in the last render but are not now.  It must be called with n as the current element.
+// The returned map has true for each key which is to be moved into place as its child is synced.
+func (r *JSRenderer) syncKeyedChildren(state *jsRenderState, n *vugu.VGNode, positionID []byte, cur *shadowCursor) (map[string]bool, error) {
+
+	var keys []string
+	var keyed map[string]bool
+	for nchild := n.FirstChild; nchild != nil; nchild = nchild.NextSibling {
+		if nchild.Key == "" || keyed[nchild.Key] {
+			continue
+		}
+		if keyed == nil {
+			keyed = make(map[string]bool)
+		}
+		keyed[nchild.Key] = true
+		keys = append(keys, nchild.Key)
+	}
+
+	if len(keys) > 0 {
+		state.nextKeyedChildren[string(positionID)] = keys
+	}
+
+	for _, k := range state.keyedChildren[string(positionID)] {
+		if keyed[k] {
+			continue
+		}
+		err := r.instructionList.writeRemoveKeyed(k)
+		if err != nil {
+			return nil, err
+		}
+		cur.removeKeyed(k)
+	}
+
+	return keyed, nil
+}
+
+// writeHydrateExpect writes the check for the node about to be synced, only while hydrating.
+func (r *JSRenderer) writeHydrateExpect(n *vugu.VGNode, positionID []byte) error {
+	if !r.hydrating {
+		return nil
+	}
+	// these are the DOM nodeType values
+	switch n.Type {
+	case vugu.ElementNode:
+		return r.instructionList.writeHydrateExpect(positionID, 1, n.Data)
+	case vugu.TextNode:
+		return r.instructionList.writeHydrateExpect(positionID, 3, "#text")
+	case vugu.CommentNode:
+		return r.instructionList.writeHydrateExpect(positionID, 8, "#comment")
+	}
+	return nil
+}
+
+// readHydrationMismatches gets the mismatches recorded by the JS side during the hydration render.
+func (r *JSRenderer) readHydrationMismatches() error {
+	r.hydrationMismatches = nil
+	if !r.window.Truthy() {
+		return nil
+	}
+	b := []byte(r.window.Call("vuguHydrateMismatches").String())
+
+	var mml []any
+	err := vjson.Unmarshal(b, &mml)
+	if err != nil {
+		return fmt.Errorf("unable to parse hydration mismatches: %w", err)
+	}
+
+	// manually extract fields
+	for _, mmi := range mml {
+		mm, _ := mmi.(map[string]any)
+		var hm HydrationMismatch
+		hm.PositionID, _ = mm["position_id"].(string)
+		hm.Expected, _ = mm["expected"].(string)
+		hm.Found, _ = mm["found"].(string)
+		r.hydrationMismatches = append(r.hydrationMismatches, hm)
+	}
+
+	return nil
+}
+
+// syncElement syncs the attributes, properties and event listeners of the current element.  If old is not nil
+// it is the shadow of the element from the last render and only what changed is written.
+// The shadow for this render is returned, without hash or children.
+func (r *JSRenderer) syncElement(state *jsRenderState, n *vugu.VGNode, positionID []byte, old *shadowNode) (*shadowNode, error) {
+
+	sn := &shadowNode{
+		typ:    vugu.ElementNode,
+		data:   n.Data,
+		ns:     n.Namespace,
+		pos:    string(positionID),
+		attrs:  n.Attr,
+		events: eventSignature(n.DOMEventHandlerSpecList),
+	}
+
+	nsElement := namespaceToURI(n.Namespace) != ""
+	for i, a := range n.Attr {
+		ns := namespaceToURI(a.Namespace)
+		// FIXME: we skip Namespace="" && Key = "xmlns" here, because this WILL cause an js exception
+		// the correct way would be, to parse the xmlns attribute in the generator, set the namespace of the holding element
+		// and then forget about this attribute
+		if nsElement && ns == "" && a.Key == "xmlns" {
+			continue
+		}
+		if old != nil {
+			// only the last of a repeated attribute has an effect
+			if _, ok := findAttr(n.Attr[i+1:], a.Namespace, a.Key); ok {
 				continue
 			}
-			err := r.instructionList.writeSetAttrNSStr(ns, a.Key, a.Val)
-			if err != nil {
-				return err
+			if v, ok := findAttr(old.attrs, a.Namespace, a.Key); ok && v == a.Val {
+				continue
 			}
 		}
+		var err error
+		if nsElement {
+			err = r.instructionList.writeSetAttrNSStr(ns, a.Key, a.Val)
+		} else {
+			err = r.instructionList.writeSetAttrStr(a.Key, a.Val)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if old == nil {
+		err := r.instructionList.writeRemoveOtherAttrs()
+		if err != nil {
+			return nil, err
+		}
 	} else {
-		for _, a := range n.Attr {
-			err := r.instructionList.writeSetAttrStr(a.Key, a.Val)
+		for i, a := range old.attrs {
+			if _, ok := findAttr(n.Attr, a.Namespace, a.Key); ok {
+				continue
+			}
+			if _, ok := findAttr(old.attrs[i+1:], a.Namespace, a.Key); ok {
+				continue
+			}
+			err := r.instructionList.writeRemoveAttr(namespaceToURI(a.Namespace), a.Key)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
-	err := r.instructionList.writeRemoveOtherAttrs()
-	if err != nil {
-		return err
-	}
-
 	// do any JS properties
 	for _, p := range n.Prop {
+		if isSelectValue(n, p.Key) {
+			continue // written after the options, see writeSelectValue
+		}
 		err := r.instructionList.writeSetProperty(p.Key, []byte(p.JSONVal))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if len(n.DOMEventHandlerSpecList) > 0 {
-
 		// store in domHandlerMap
 		state.domHandlerMap[string(positionID)] = n.DOMEventHandlerSpecList
+	}
 
-		for _, hs := range n.DOMEventHandlerSpecList {
-			err := r.instructionList.writeSetEventListener(positionID, hs.EventType, hs.Capture, hs.Passive)
-			if err != nil {
-				return err
-			}
+	// the same listeners at the same position are already registered, the JS side looks up
+	// the handler in domHandlerMap when an event happens
+	if old != nil && old.events == sn.events && old.pos == sn.pos {
+		return sn, nil
+	}
+
+	var delegated []string
+	for i := range n.DOMEventHandlerSpecList {
+		hs := &n.DOMEventHandlerSpecList[i]
+		if r.delegateEvents && !state.outsideMount && !nonBubblingEvents[hs.EventType] {
+			delegated = append(delegated, eventKey(hs))
+			continue
+		}
+		flags, keys := eventModifiers(hs)
+		err := r.instructionList.writeSetEventListener(positionID, hs.EventType, hs.Capture, hs.Passive, flags, keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(delegated) > 0 {
+		err := r.instructionList.writeSetDelegatedEvents(positionID, strings.Join(delegated, "\n"))
+		if err != nil {
+			return nil, err
 		}
 	}
-	// always write the remove for event listeners so any previous ones are taken away
-	return r.instructionList.writeRemoveOtherEventListeners(positionID)
+	// always write the remove for event listeners so any previous ones are taken away,
+	// this includes delegated listeners
+	return sn, r.instructionList.writeRemoveOtherEventListeners(positionID)
 }
 
 // // writeAllStaticAttrs is a helper to write all the static attrs from a VGNode
@@ -654,6 +1315,7 @@ func (r *JSRenderer) handleDOMEvent() {
 		EventType  string // `json:"event_type"`
 		Capture    bool   // `json:"capture"`
 		Passive    bool   // `json:"passive"`
+		Modifiers  string // `json:"modifiers"`
 
 		// the event object data as extracted above
 		EventSummary map[string]any // `json:"event_summary"`
@@ -671,6 +1333,7 @@ func (r *JSRenderer) handleDOMEvent() {
 	eventDetail.EventType, _ = edm["event_type"].(string)
 	eventDetail.Capture, _ = edm["capture"].(bool)
 	eventDetail.Passive, _ = edm["passive"].(bool)
+	eventDetail.Modifiers, _ = edm["modifiers"].(string)
 	eventDetail.EventSummary, _ = edm["event_summary"].(map[string]any)
 
 	domEvent := vugu.NewDOMEvent(r.eventEnv, eventDetail.EventSummary)
@@ -683,8 +1346,9 @@ func (r *JSRenderer) handleDOMEvent() {
 	r.eventRWMU.Lock()
 	handlers := r.jsRenderState.domHandlerMap[eventDetail.PositionID]
 	var f func(vugu.DOMEvent)
-	for _, h := range handlers {
-		if h.EventType == eventDetail.EventType && h.Capture == eventDetail.Capture {
+	for i := range handlers {
+		h := &handlers[i]
+		if h.EventType == eventDetail.EventType && h.Capture == eventDetail.Capture && eventModifierKey(h) == eventDetail.Modifiers {
 			f = h.Func
 			break
 		}
@@ -693,8 +1357,8 @@ func (r *JSRenderer) handleDOMEvent() {
 	// make sure we found something, panic if not
 	if f == nil {
 		r.eventRWMU.Unlock()
-		panic(fmt.Errorf("Unable to find event handler for positionID=%q, eventType=%q, capture=%v",
-			eventDetail.PositionID, eventDetail.EventType, eventDetail.Capture))
+		panic(fmt.Errorf("Unable to find event handler for positionID=%q, eventType=%q, capture=%v, modifiers=%q",
+			eventDetail.PositionID, eventDetail.EventType, eventDetail.Capture, eventDetail.Modifiers))
 	}
 
 	// NOTE: For tinygo support we are not using defer here for now - it would probably be better to do so since
@@ -0,0 +1,670 @@
+package domrender
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vugu/vugu"
+	js "github.com/vugu/vugu/js"
+)
+
+func TestHydrateExpect(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+	r.SetHydrate(true)
+
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		span := &vugu.VGNode{Type: vugu.ElementNode, Data: "span"}
+		span.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: "hello"})
+		div.AppendChild(span)
+		tmpl := &vugu.VGNode{Type: vugu.ElementNode}
+		tmpl.AppendChild(&vugu.VGNode{Type: vugu.CommentNode, Data: "c"})
+		div.AppendChild(tmpl)
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	// first render checks every node against the existing DOM
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Contains(out, `writeHydrateExpect[42](positionID="0", nodeType=1, nodeName="div")`)
+	// the mount point is checked before opcodeSelectMountPoint replaces it
+	assert.Less(strings.Index(out, `positionID="0", nodeType=1`), strings.Index(out, "writeSelectMountPoint"))
+	assert.Contains(out, `writeHydrateExpect[42](positionID="0_1", nodeType=1, nodeName="span")`)
+	assert.Contains(out, `writeHydrateExpect[42](positionID="0_1_1", nodeType=3, nodeName="#text")`)
+	assert.Contains(out, `writeHydrateExpect[42](positionID="0_2_t_1", nodeType=8, nodeName="#comment")`)
+	assert.Empty(r.HydrationMismatches())
+
+	// subsequent renders are normal, the nodes synced while hydrating are known and skipped
+	logBuf.Reset()
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.NotContains(logBuf.String(), "writeHydrateExpect")
+	assert.Contains(logBuf.String(), "writeSkipNode")
+
+}
+
+func TestHandleDOMEventModifiers(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var got string
+	specs := []vugu.DOMEventHandlerSpec{
+		{EventType: "keydown", Keys: []string{"enter", "ctrl"}, Func: func(vugu.DOMEvent) { got = "enter" }},
+		{EventType: "keydown", Keys: []string{"esc"}, PreventDefault: true, Func: func(vugu.DOMEvent) { got = "esc" }},
+		{EventType: "keydown", Func: func(vugu.DOMEvent) { got = "any" }},
+	}
+
+	r := &JSRenderer{jsRenderState: newJsRenderState()}
+	r.jsRenderState.domHandlerMap["0_1"] = specs
+
+	fire := func(modifiers string) {
+		got = ""
+		b := []byte(`{"position_id":"0_1","event_type":"keydown","capture":false,"passive":false,"modifiers":"` + modifiers + `","event_summary":{}}`)
+		r.eventHandlerBuffer = binary.BigEndian.AppendUint32(nil, uint32(len(b)))
+		r.eventHandlerBuffer = append(r.eventHandlerBuffer, b...)
+		r.handleDOMEvent()
+	}
+
+	assert.Equal("0:enter,ctrl", eventModifierKey(&specs[0]))
+	assert.Equal("1:esc", eventModifierKey(&specs[1]))
+	assert.Equal("0:", eventModifierKey(&specs[2]))
+
+	fire("1:esc")
+	assert.Equal("esc", got)
+	fire("0:enter,ctrl")
+	assert.Equal("enter", got)
+	fire("0:")
+	assert.Equal("any", got)
+	assert.Panics(func() { fire("4:") })
+}
+
+func TestKeyedChildren(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	var keys []string
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		ul := &vugu.VGNode{Type: vugu.ElementNode, Data: "ul"}
+		for _, k := range keys {
+			li := &vugu.VGNode{Type: vugu.ElementNode, Data: "li"}
+			li.SetKey(k)
+			ul.AppendChild(li)
+		}
+		ul.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "p"})
+		return &vugu.BuildOut{Out: []*vugu.VGNode{ul}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	keys = []string{"a", "b", "c"}
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Contains(out, `writeMoveKeyed[44](key="a")`)
+	assert.Contains(out, `writeMoveKeyed[44](key="c")`)
+	assert.NotContains(out, "writeRemoveKeyed")
+
+	// keys which are gone are removed by key before the children are synced, the rest are moved into place
+	logBuf.Reset()
+	keys = []string{"c", "a", "a"}
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out = logBuf.String()
+	assert.Contains(out, `writeRemoveKeyed[43](key="b")`)
+	assert.Less(strings.Index(out, `writeRemoveKeyed[43](key="b")`), strings.Index(out, "writeMoveToFirstChild"))
+	assert.Less(strings.Index(out, `writeMoveKeyed[44](key="c")`), strings.Index(out, `writeMoveKeyed[44](key="a")`))
+	assert.Equal(2, strings.Count(out, "writeMoveKeyed"), "duplicate key should be synced by position")
+
+	// no keys at all
+	logBuf.Reset()
+	keys = nil
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out = logBuf.String()
+	assert.Contains(out, `writeRemoveKeyed[43](key="c")`)
+	assert.Contains(out, `writeRemoveKeyed[43](key="a")`)
+	assert.NotContains(out, "writeMoveKeyed")
+}
+
+// skipChild returns the BuildOut from its last Build while Text is the same
+type skipChild struct {
+	Text     string
+	lastText string
+	out      *vugu.BuildOut
+}
+
+func (c *skipChild) Build(in *vugu.BuildIn) *vugu.BuildOut {
+	if c.out != nil && c.lastText == c.Text {
+		return c.out
+	}
+	span := &vugu.VGNode{Type: vugu.ElementNode, Data: "span"}
+	span.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: c.Text})
+	c.out, c.lastText = &vugu.BuildOut{Out: []*vugu.VGNode{span}}, c.Text
+	return c.out
+}
+
+func TestSkipUnchanged(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	a, b := &skipChild{Text: "a"}, &skipChild{Text: "b"}
+	swap := false
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		first, second := a, b
+		if swap {
+			first, second = b, a
+		}
+		div.AppendChild(&vugu.VGNode{Component: first})
+		div.AppendChild(&vugu.VGNode{Component: second})
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}, Components: []vugu.Builder{first, second}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.NotContains(logBuf.String(), "writeSkipNode")
+	assert.Equal(RenderCounts{Components: 2}, r.LastRenderCounts())
+
+	// b is changed, a is skipped
+	logBuf.Reset()
+	b.Text = "B"
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Equal(1, strings.Count(out, "writeSkipNode"))
+	assert.NotContains(out, `writeSetText[23](text="a")`)
+	assert.Contains(out, `text="B"`)
+	assert.Equal(RenderCounts{Components: 2, SkippedComponents: 1, SkippedSubtrees: 1}, r.LastRenderCounts())
+	stats := r.LastRenderStats()
+	assert.Equal(1, stats.OpcodeCounts["SkipNode"])
+	assert.Equal(r.LastRenderCounts(), stats.RenderCounts)
+	assert.Positive(stats.Instructions)
+
+	// nothing changed, both skipped
+	logBuf.Reset()
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.Contains(logBuf.String(), "writeSkipNodes[49](count=2)")
+
+	// unchanged output at a different position must be synced
+	logBuf.Reset()
+	swap = true
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.NotContains(logBuf.String(), "writeSkipNode")
+	assert.Equal(RenderCounts{Components: 2}, r.LastRenderCounts())
+}
+
+func TestEmptyTemplate(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "span"})
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode}) // e.g. a vg-for with no items
+		tmpl := &vugu.VGNode{Type: vugu.ElementNode}
+		tmpl.AppendChild(&vugu.VGNode{Type: vugu.ElementNode})
+		tmpl.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "i"})
+		tmpl.AppendChild(&vugu.VGNode{Type: vugu.ElementNode})
+		div.AppendChild(tmpl)
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "b"})
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	// one move to each of i and b and one past b, the empty templates are passed over
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.Equal(3, strings.Count(logBuf.String(), "writeMoveToNextSibling"))
+}
+
+func TestEmptyTemplateBetweenSiblings(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	var items []string
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "span"})
+		tmpl := &vugu.VGNode{Type: vugu.ElementNode} // a vg-for over items
+		for _, it := range items {
+			tmpl.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: it})
+		}
+		div.AppendChild(tmpl)
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "b"})
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	// the empty template is between span and b but nothing is moved past for it
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Equal(2, strings.Count(out, "writeMoveToNextSibling"))
+	assert.Less(strings.Index(out, `writeSetElement[21](nodeName="span")`), strings.Index(out, "writeMoveToNextSibling"))
+
+	// once the template has children they are synced in the place of b, and b after them
+	logBuf.Reset()
+	items = []string{"i", "em"}
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out = logBuf.String()
+	iIdx := strings.Index(out, `writeSetElement[21](nodeName="i")`)
+	emIdx := strings.Index(out, `writeSetElement[21](nodeName="em")`)
+	bIdx := strings.Index(out, `writeSetElement[21](nodeName="b")`)
+	assert.True(iIdx >= 0 && iIdx < emIdx && emIdx < bIdx, out)
+	assert.Equal(4, strings.Count(out[:bIdx], "writeMoveTo"), out) // first child of div, then i, em and b
+
+	// and when it is empty again b goes back to being the next sibling of span
+	logBuf.Reset()
+	items = nil
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out = logBuf.String()
+	assert.NotContains(out, `nodeName="i"`)
+	assert.Equal(1, strings.Count(out[:strings.Index(out, `nodeName="b"`)], "writeMoveToNextSibling"), out)
+}
+
+func TestSelectValue(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		sel := &vugu.VGNode{Type: vugu.ElementNode, Data: "select"}
+		sel.Prop = append(sel.Prop, vugu.VGProperty{Key: "value", JSONVal: []byte(`"b"`)})
+		for _, v := range []string{"a", "b"} {
+			opt := &vugu.VGNode{Type: vugu.ElementNode, Data: "option", Attr: []vugu.VGAttribute{{Key: "value", Val: v}}}
+			sel.AppendChild(opt)
+		}
+		return &vugu.BuildOut{Out: []*vugu.VGNode{sel}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	// the preset value is set once the options exist, otherwise the browser drops it
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	propIdx := strings.Index(out, `writeSetProperty[`)
+	optIdx := strings.LastIndex(out, `writeSetElement[21](nodeName="option")`)
+	assert.True(optIdx >= 0 && optIdx < propIdx, out)
+	assert.Equal(1, strings.Count(out, "writeSetProperty"), out)
+}
+
+func TestEventDelegation(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+	r.SetEventDelegation(true)
+
+	click := func(vugu.DOMEvent) {}
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		ul := &vugu.VGNode{Type: vugu.ElementNode, Data: "ul"}
+		for i := 0; i < 10; i++ {
+			li := &vugu.VGNode{Type: vugu.ElementNode, Data: "li"}
+			li.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+				{EventType: "click", Func: click},
+				{EventType: "keydown", Capture: true, StopPropagation: true, Keys: []string{"enter"}, Func: click},
+			}
+			ul.AppendChild(li)
+		}
+		input := &vugu.VGNode{Type: vugu.ElementNode, Data: "input"}
+		input.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{{EventType: "focus", Func: click}}
+		ul.AppendChild(input)
+		portal := &vugu.VGNode{Type: vugu.ElementNode, Portal: "#modal"}
+		btn := &vugu.VGNode{Type: vugu.ElementNode, Data: "button"}
+		btn.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{{EventType: "click", Func: click}}
+		portal.AppendChild(btn)
+		ul.AppendChild(portal)
+		return &vugu.BuildOut{Out: []*vugu.VGNode{ul}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Equal(10, strings.Count(out, "writeSetDelegatedEvents"))
+	assert.Contains(out, `eventKeys="click|0|0|0:\nkeydown|1|0|2:enter"`)
+
+	// focus does not bubble and the portal is not under the mount point, so they get their own listeners
+	assert.Equal(2, strings.Count(out, "writeSetEventListener"))
+	assert.Contains(out, `eventType="focus"`)
+	assert.Contains(out, `eventType="click"`)
+
+	// the handlers are still found by positionID
+	assert.Len(r.jsRenderState.domHandlerMap, 12)
+}
+
+func TestPortal(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	target := "#modal"
+	show := true
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "h1"})
+		if show {
+			portal := &vugu.VGNode{Type: vugu.ElementNode, Portal: target}
+			p := &vugu.VGNode{Type: vugu.ElementNode, Data: "p"}
+			p.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: "in the modal"})
+			portal.AppendChild(p)
+			div.AppendChild(portal)
+		}
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "footer"})
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	// a placeholder is output in place and the children are synced into the target after the main output
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Contains(out, `writeSetComment[24](comment="vg-portal")`)
+	assert.Contains(out, `writeSelectPortal[46](selector="#modal", portalID="0_2")`)
+	assert.Less(strings.Index(out, `writeSetElement[21](nodeName="footer")`), strings.Index(out, "writeSelectPortal"))
+	assert.Less(strings.Index(out, "writeSelectPortal"), strings.Index(out, `writeSetText[23](text="in the modal")`))
+	assert.NotContains(out, "writeRemovePortal")
+
+	// a different target moves the children
+	logBuf.Reset()
+	target = "#other"
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out = logBuf.String()
+	assert.Contains(out, `writeRemovePortal[47](selector="#modal", portalID="0_2")`)
+	assert.Contains(out, `writeSelectPortal[46](selector="#other", portalID="0_2")`)
+
+	// when the portal is gone its children are removed from the target
+	logBuf.Reset()
+	show = false
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out = logBuf.String()
+	assert.NotContains(out, "writeSelectPortal")
+	assert.Contains(out, `writeRemovePortal[47](selector="#other", portalID="0_2")`)
+
+	logBuf.Reset()
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.NotContains(logBuf.String(), "writeRemovePortal")
+
+	// an empty target (e.g. from a vg-portal :target expression) is an error rather than output in place
+	show, target = true, vugu.PortalTarget("")
+	assert.EqualError(r.render(buildEnv.RunBuild(root)), "vg-portal at position 0_2 has an empty target selector")
+}
+
+func TestRef(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	var inputEl js.Element
+	show := true
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		if show {
+			div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "input", Ref: &inputEl})
+		}
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	// the element is sent back with a callback, which assigns it
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.Contains(logBuf.String(), "writeCallbackLastElement")
+	cb := r.jsRenderState.callbackManager.callbackInfoMap[1]
+	cb.f.JSValueHandle(js.Undefined())
+	assert.True(inputEl.IsSet())
+
+	// still rendered, so it is left alone
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.True(inputEl.IsSet())
+
+	// cleared once the element is gone
+	show = false
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.False(inputEl.IsSet())
+
+	// a vg-js-create handler on the same element is still called
+	var created bool
+	h := refHandler{ref: &inputEl, next: vugu.JSValueFunc(func(js.Value) { created = true })}
+	h.JSValueHandle(js.Undefined())
+	assert.True(created)
+	assert.True(inputEl.IsSet())
+}
+
+func TestShadowDiff(t *testing.T) {
+
+	assert := assert.New(t)
+
+	// renders a list of n items and then again with one attribute changed, returning the log and stats
+	// for the second render
+	renderChange := func(n int) (string, RenderStats) {
+
+		var logBuf bytes.Buffer
+		r := &JSRenderer{MountPointSelector: "#app"}
+		r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+
+		selected := -1
+		root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+			ul := &vugu.VGNode{Type: vugu.ElementNode, Data: "ul"}
+			for i := 0; i < n; i++ {
+				li := &vugu.VGNode{Type: vugu.ElementNode, Data: "li", Attr: []vugu.VGAttribute{
+					{Key: "id", Val: "item" + strconv.Itoa(i)},
+					{Key: "class", Val: "item"},
+					{Key: "title", Val: "Item number " + strconv.Itoa(i)},
+				}}
+				if i == selected {
+					li.Attr[1].Val = "item selected"
+				}
+				li.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: "Item " + strconv.Itoa(i)})
+				ul.AppendChild(li)
+			}
+			return &vugu.BuildOut{Out: []*vugu.VGNode{ul}}
+		})
+
+		buildEnv, err := vugu.NewBuildEnv()
+		assert.NoError(err)
+
+		assert.NoError(r.render(buildEnv.RunBuild(root)))
+
+		selected = 5
+		r.instructionList.logWriter = &logBuf
+		assert.NoError(r.render(buildEnv.RunBuild(root)))
+		assert.Equal(n, r.LastRenderCounts().SkippedNodes) // the other items and the text of the changed one
+
+		return logBuf.String(), r.LastRenderStats()
+	}
+
+	out10, stats10 := renderChange(10)
+	_, stats100 := renderChange(100)
+	assert.Equal(stats10.BytesFlushed, stats100.BytesFlushed, "a one attribute change should be the same size regardless of the size of the list")
+	assert.Equal(stats10.OpcodeCounts, stats100.OpcodeCounts)
+
+	assert.Equal(1, strings.Count(out10, "writeSetAttrStr"))
+	assert.Contains(out10, `writeSetAttrStr[6](name="class", value="item selected")`)
+	assert.Equal(1, strings.Count(out10, "writeSetElement"))
+	assert.Contains(out10, "writeSkipNodes[49](count=5)")
+	assert.Contains(out10, "writeSkipNodes[49](count=4)")
+	assert.NotContains(out10, "writeSetText")
+	assert.NotContains(out10, "writeRemoveOtherAttrs")
+	assert.NotContains(out10, "writeRemoveOtherEventListeners")
+}
+
+func TestShadowDiffElement(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	attrs := []vugu.VGAttribute{{Key: "class", Val: "a"}, {Key: "title", Val: "t"}}
+	var clicks []string
+	label := "one"
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		l := label
+		button := &vugu.VGNode{Type: vugu.ElementNode, Data: "button", Attr: attrs}
+		button.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{{EventType: "click", Func: func(vugu.DOMEvent) { clicks = append(clicks, l) }}}
+		button.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: "Click"})
+		div.AppendChild(button)
+		input := &vugu.VGNode{Type: vugu.ElementNode, Data: "input"}
+		input.Prop = []vugu.VGProperty{{Key: "value", JSONVal: []byte(`"x"`)}}
+		div.AppendChild(input)
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.Contains(logBuf.String(), "writeSetEventListener")
+
+	// a removed attribute is removed by name, the listener is left alone but the new handler is used
+	logBuf.Reset()
+	attrs = attrs[1:]
+	label = "two"
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Contains(out, `writeRemoveAttr[48](ns="", name="class")`)
+	assert.NotContains(out, "writeSetAttrStr")
+	assert.NotContains(out, "writeSetEventListener")
+	assert.NotContains(out, "writeRemoveOtherEventListeners")
+	r.jsRenderState.domHandlerMap["0_1"][0].Func(nil)
+	assert.Equal([]string{"two"}, clicks)
+
+	// properties are always written, so the input is not skipped
+	assert.Contains(out, `writeSetElement[21](nodeName="input")`)
+	assert.Contains(out, "writeSetProperty")
+	assert.Contains(out, "writeSkipNode[45]()")
+}
+
+func TestShadowDiffNamespacedAttr(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	attrs := []vugu.VGAttribute{{Namespace: "xlink", Key: "href", Val: "#icon"}, {Key: "class", Val: "a"}}
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		svg := &vugu.VGNode{Type: vugu.ElementNode, Data: "svg", Namespace: "svg"}
+		svg.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "use", Namespace: "svg", Attr: attrs})
+		return &vugu.BuildOut{Out: []*vugu.VGNode{svg}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.Contains(logBuf.String(), `writeSetAttrNSStr[38](ns="http://www.w3.org/1999/xlink", name="href", value="#icon")`)
+
+	// the namespace is sent so the attribute set with setAttributeNS can be removed
+	logBuf.Reset()
+	attrs = attrs[1:]
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	assert.Contains(logBuf.String(), `writeRemoveAttr[48](ns="http://www.w3.org/1999/xlink", name="href")`)
+}
+
+func TestShadowDiffKeyed(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var logBuf bytes.Buffer
+	r := &JSRenderer{MountPointSelector: "#app"}
+	r.instructionList = newInstructionList(make([]byte, 4096), func(il *instructionList) error { return nil })
+	r.instructionList.logWriter = &logBuf
+
+	var keys []string
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		ul := &vugu.VGNode{Type: vugu.ElementNode, Data: "ul"}
+		for _, k := range keys {
+			li := &vugu.VGNode{Type: vugu.ElementNode, Data: "li", Attr: []vugu.VGAttribute{{Key: "title", Val: k}}}
+			li.SetKey(k)
+			li.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: k})
+			ul.AppendChild(li)
+		}
+		return &vugu.BuildOut{Out: []*vugu.VGNode{ul}}
+	})
+
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+
+	keys = []string{"a", "b", "c"}
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+
+	// moved elements are still known, so they are skipped after the move
+	logBuf.Reset()
+	keys = []string{"c", "a", "b"}
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out := logBuf.String()
+	assert.Equal(3, strings.Count(out, "writeMoveKeyed"))
+	assert.NotContains(out, "writeSetElement")
+	assert.Equal(3, r.LastRenderCounts().SkippedNodes)
+
+	// a new key in front of the others gets a new element
+	logBuf.Reset()
+	keys = []string{"d", "c", "a"}
+	assert.NoError(r.render(buildEnv.RunBuild(root)))
+	out = logBuf.String()
+	assert.Contains(out, `writeRemoveKeyed[43](key="b")`)
+	assert.Equal(1, strings.Count(out, "writeSetElement"))
+	assert.Contains(out, `writeSetAttrStr[6](name="title", value="d")`)
+	assert.Contains(out, `writeSetText[23](text="d")`)
+	assert.Equal(2, r.LastRenderCounts().SkippedNodes)
+}
@@ -0,0 +1,126 @@
+package vugu
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrorBoundary is implemented by components which handle errors in the components below them.
+// If a component in the subtree of an ErrorBoundary panics during BuildEnv.RunBuild (in its
+// Init, Compute, BeforeBuild or Build), the components built below it are destroyed, BuildError
+// is called and then the ErrorBoundary's Build method is called again, so it can output
+// fallback UI instead of the components which failed, e.g.:
+//
+//	func (c *Boundary) BuildError(err *vugu.BuildError) { c.Err = err }
+//
+//	<div>
+//	  <div vg-if="c.Err != nil" vg-content="c.Err"></div>
+//	  <vg-template vg-if="c.Err == nil"><main:Widget></main:Widget></vg-template>
+//	</div>
+//
+// Panics in the ErrorBoundary's own Build (including in the fallback) are handled by the next
+// ErrorBoundary above it.  A panic with no ErrorBoundary above it is not recovered.
+type ErrorBoundary interface {
+	BuildError(err *BuildError)
+}
+
+// BuildError describes a panic which happened during a build.
+type BuildError struct {
+	Component Builder // the component which panicked
+	Value     any     // the value passed to panic
+	Stack     []byte  // the stack trace at the time of the panic
+}
+
+// Error implements error.
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("panic while building %T: %v", e.Component, e.Value)
+}
+
+// Unwrap returns Value if it is an error.
+func (e *BuildError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// SetErrorHook sets a function which is called for each panic during a build, for reporting.
+// It is called both for panics handled by an ErrorBoundary and ones which are not (before
+// the panic continues).  Pass nil to remove it.
+func (e *BuildEnv) SetErrorHook(f func(err *BuildError)) {
+	e.errorHook = f
+}
+
+// tryBuildComponents calls buildComponents and recovers from a panic in any of the components or
+// their subtrees, the failed components are destroyed and the error is returned
+func (e *BuildEnv) tryBuildComponents(buildIn *BuildIn, comps []Builder) (berr *BuildError) {
+
+	mark := len(e.entered)
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		berr = e.newBuildError(r, mark)
+		e.building = nil
+
+		// the failed components are everything entered since mark
+		failed := append([]Builder(nil), e.entered[mark:]...)
+		e.entered = e.entered[:mark]
+		e.destroyFailed(failed)
+	}()
+
+	e.buildComponents(buildIn, comps)
+
+	return nil
+}
+
+// newBuildError makes a BuildError for a value recovered from a panic, the component is the
+// last one entered since mark
+func (e *BuildEnv) newBuildError(r any, mark int) *BuildError {
+	berr := &BuildError{Value: r, Stack: debug.Stack()}
+	if len(e.entered) > mark {
+		berr.Component = e.entered[len(e.entered)-1]
+	}
+	return berr
+}
+
+// destroyFailed removes the failed components from this build pass and the component cache
+// and calls Destroy on them, so they are created again from scratch if used later
+func (e *BuildEnv) destroyFailed(failed []Builder) {
+
+	isFailed := make(map[Builder]bool, len(failed))
+	for _, c := range failed {
+		isFailed[c] = true
+		k := makeBuildCacheKey(c)
+		delete(e.buildResults, k)
+		delete(e.reused, k)
+		delete(e.compUses, k)
+		delete(e.provides, k)
+	}
+
+	for k, c := range e.compUsed {
+		if isFailed[c] {
+			delete(e.compUsed, k)
+		}
+	}
+
+	for _, c := range failed {
+		st, ok := e.compStateMap[c]
+		if !ok {
+			continue
+		}
+		delete(e.compStateMap, c)
+		e.safeDestroy(c, st.cancel)
+	}
+}
+
+// safeDestroy calls Destroy on a failed component, a panic in Destroy is only reported to the error hook
+func (e *BuildEnv) safeDestroy(c Builder, cancel context.CancelFunc) {
+	defer func() {
+		if r := recover(); r != nil && e.errorHook != nil {
+			e.errorHook(&BuildError{Component: c, Value: r, Stack: debug.Stack()})
+		}
+	}()
+	invokeDestroy(c, e.eventEnv, cancel)
+}
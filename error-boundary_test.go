@@ -0,0 +1,107 @@
+package vugu
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestBuild = errors.New("test build error")
+
+// panicComp panics in Build while panic is set
+type panicComp struct {
+	panic     bool
+	destroyed int
+	children  []Builder
+}
+
+func (c *panicComp) Build(in *BuildIn) (out *BuildOut) {
+	if c.panic {
+		panic(errTestBuild)
+	}
+	return &BuildOut{Out: []*VGNode{{Type: ElementNode, Data: "p"}}, Components: c.children}
+}
+
+func (c *panicComp) Destroy() { c.destroyed++ }
+
+// boundaryComp outputs its children, or a fallback once it has an error
+type boundaryComp struct {
+	children []Builder
+	err      *BuildError
+	fallback Builder
+}
+
+func (c *boundaryComp) BuildError(err *BuildError) { c.err = err }
+
+func (c *boundaryComp) Build(in *BuildIn) (out *BuildOut) {
+	if c.err != nil {
+		if c.fallback != nil {
+			return &BuildOut{Out: []*VGNode{{Component: c.fallback}}, Components: []Builder{c.fallback}}
+		}
+		msg := c.err.Error()
+		return &BuildOut{Out: []*VGNode{{Type: ElementNode, Data: "div", InnerHTML: &msg}}}
+	}
+	n := &VGNode{Type: ElementNode, Data: "div"}
+	for _, c := range c.children {
+		n.AppendChild(&VGNode{Component: c})
+	}
+	return &BuildOut{Out: []*VGNode{n}, Components: c.children}
+}
+
+func TestErrorBoundary(t *testing.T) {
+
+	assert := assert.New(t)
+
+	grandchild := &panicComp{}
+	ok1 := &panicComp{children: []Builder{grandchild}}
+	bad := &panicComp{panic: true}
+	inner := &boundaryComp{children: []Builder{ok1, bad}}
+	sibling := &panicComp{}
+	outer := &boundaryComp{children: []Builder{inner, sibling}}
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+	var hooked []*BuildError
+	be.SetErrorHook(func(err *BuildError) { hooked = append(hooked, err) })
+
+	res := be.RunBuild(outer)
+
+	// the inner boundary handled it and shows the fallback
+	if assert.NotNil(inner.err) {
+		assert.Same(bad, inner.err.Component)
+		assert.ErrorIs(inner.err, errTestBuild)
+		assert.Contains(string(inner.err.Stack), "panicComp")
+		assert.Contains(inner.err.Error(), "*vugu.panicComp")
+	}
+	assert.Nil(outer.err)
+	assert.Len(hooked, 1)
+	assert.Equal("div", res.ResultFor(inner).Out[0].Data)
+	assert.Contains(*res.ResultFor(inner).Out[0].InnerHTML, "test build error")
+
+	// the subtree of the boundary was destroyed and removed from the results
+	assert.Equal(1, ok1.destroyed)
+	assert.Equal(1, grandchild.destroyed)
+	assert.Equal(1, bad.destroyed)
+	assert.Nil(res.ResultFor(ok1))
+	assert.Nil(res.ResultFor(bad))
+
+	// the rest of the tree is unaffected
+	assert.NotNil(res.ResultFor(sibling))
+	assert.Equal(0, sibling.destroyed)
+
+	// a panic in the fallback goes to the next boundary up
+	inner2 := &boundaryComp{children: []Builder{&panicComp{panic: true}}, fallback: &panicComp{panic: true}}
+	outer2 := &boundaryComp{children: []Builder{inner2}}
+	res = be.RunBuild(outer2)
+	assert.NotNil(inner2.err)
+	if assert.NotNil(outer2.err) {
+		assert.Same(inner2.fallback, outer2.err.Component)
+	}
+	assert.Nil(res.ResultFor(inner2))
+	assert.Len(hooked, 3)
+
+	// without a boundary the panic is not recovered, but it is reported
+	assert.PanicsWithError(errTestBuild.Error(), func() { be.RunBuild(&panicComp{panic: true}) })
+	assert.Len(hooked, 4)
+}
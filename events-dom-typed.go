@@ -0,0 +1,292 @@
+package vugu
+
+// The types in this file are typed views of the EventSummary for the common DOM event types,
+// returned by MouseEventOf, KeyboardEventOf, etc.  They are built entirely from the EventSummary
+// (no calls into JS), so they work the same for any DOMEvent, including ones created by NewDOMEvent.  Fields which are not present in the summary
+// (e.g. asking for a KeyboardEvent from a click) are left as the zero value.
+
+// ModifierKeys is the state of the modifier keys during an event.
+type ModifierKeys struct {
+	AltKey   bool
+	CtrlKey  bool
+	MetaKey  bool
+	ShiftKey bool
+}
+
+// MouseEvent corresponds to a DOM MouseEvent (click, mousedown, mousemove, etc.)
+type MouseEvent struct {
+	ModifierKeys
+	ClientX, ClientY     float64
+	ScreenX, ScreenY     float64
+	PageX, PageY         float64
+	OffsetX, OffsetY     float64
+	MovementX, MovementY float64
+	Button               int // which button changed state: 0 main (usually left), 1 auxiliary, 2 secondary
+	Buttons              int // bitmask of buttons currently pressed
+	Detail               int // click count for click events
+}
+
+// KeyboardEvent corresponds to a DOM KeyboardEvent (keydown, keyup).
+type KeyboardEvent struct {
+	ModifierKeys
+	Key         string // the key value, e.g. "a", "Enter", "ArrowUp"
+	Code        string // the physical key, e.g. "KeyA"
+	Location    int
+	Repeat      bool
+	IsComposing bool
+}
+
+// InputEvent corresponds to a DOM InputEvent (input, beforeinput) and also has the
+// value and checked state of the target, which is what is usually needed.
+type InputEvent struct {
+	Data        string
+	InputType   string
+	IsComposing bool
+	Value       string // target.value
+	Checked     bool   // target.checked
+}
+
+// PointerEvent corresponds to a DOM PointerEvent (pointerdown, pointermove, etc.)
+type PointerEvent struct {
+	MouseEvent
+	PointerID          int
+	PointerType        string // "mouse", "pen" or "touch"
+	IsPrimary          bool
+	Width, Height      float64
+	Pressure           float64
+	TangentialPressure float64
+	TiltX, TiltY       float64
+	Twist              float64
+}
+
+// WheelEvent corresponds to a DOM WheelEvent.
+type WheelEvent struct {
+	MouseEvent
+	DeltaX, DeltaY, DeltaZ float64
+	DeltaMode              int // 0 pixels, 1 lines, 2 pages
+}
+
+// File describes a file from a drag and drop or file input.  The contents are not included,
+// use the JS APIs (e.g. via JSEvent) to read them.
+type File struct {
+	Name         string
+	Type         string // MIME type
+	Size         int64
+	LastModified int64 // milliseconds since the epoch
+}
+
+// DataTransfer corresponds to a DOM DataTransfer.
+type DataTransfer struct {
+	DropEffect    string
+	EffectAllowed string
+	Types         []string
+	Files         []File
+}
+
+// DragEvent corresponds to a DOM DragEvent (dragstart, dragover, drop, etc.)
+type DragEvent struct {
+	MouseEvent
+	DataTransfer DataTransfer
+}
+
+// Touch is a single touch point of a TouchEvent.
+type Touch struct {
+	Identifier       int
+	ClientX, ClientY float64
+	ScreenX, ScreenY float64
+	PageX, PageY     float64
+	RadiusX, RadiusY float64
+	RotationAngle    float64
+	Force            float64
+}
+
+// TouchEvent corresponds to a DOM TouchEvent (touchstart, touchmove, etc.)
+type TouchEvent struct {
+	ModifierKeys
+	Touches        []Touch
+	TargetTouches  []Touch
+	ChangedTouches []Touch
+}
+
+// MouseEventOf returns the event as a MouseEvent.
+func MouseEventOf(e DOMEvent) MouseEvent {
+	return mouseEventFrom(e.EventSummary())
+}
+
+// KeyboardEventOf returns the event as a KeyboardEvent.
+func KeyboardEventOf(e DOMEvent) KeyboardEvent {
+	m := e.EventSummary()
+	return KeyboardEvent{
+		ModifierKeys: modifierKeysFrom(m),
+		Key:          summaryString(m, "key"),
+		Code:         summaryString(m, "code"),
+		Location:     int(summaryFloat64(m, "location")),
+		Repeat:       summaryBool(m, "repeat"),
+		IsComposing:  summaryBool(m, "isComposing"),
+	}
+}
+
+// InputEventOf returns the event as an InputEvent.
+func InputEventOf(e DOMEvent) InputEvent {
+	m := e.EventSummary()
+	target, _ := m["target"].(map[string]any)
+	return InputEvent{
+		Data:        summaryString(m, "data"),
+		InputType:   summaryString(m, "inputType"),
+		IsComposing: summaryBool(m, "isComposing"),
+		Value:       summaryString(target, "value"),
+		Checked:     summaryBool(target, "checked"),
+	}
+}
+
+// PointerEventOf returns the event as a PointerEvent.
+func PointerEventOf(e DOMEvent) PointerEvent {
+	m := e.EventSummary()
+	return PointerEvent{
+		MouseEvent:         mouseEventFrom(m),
+		PointerID:          int(summaryFloat64(m, "pointerId")),
+		PointerType:        summaryString(m, "pointerType"),
+		IsPrimary:          summaryBool(m, "isPrimary"),
+		Width:              summaryFloat64(m, "width"),
+		Height:             summaryFloat64(m, "height"),
+		Pressure:           summaryFloat64(m, "pressure"),
+		TangentialPressure: summaryFloat64(m, "tangentialPressure"),
+		TiltX:              summaryFloat64(m, "tiltX"),
+		TiltY:              summaryFloat64(m, "tiltY"),
+		Twist:              summaryFloat64(m, "twist"),
+	}
+}
+
+// WheelEventOf returns the event as a WheelEvent.
+func WheelEventOf(e DOMEvent) WheelEvent {
+	m := e.EventSummary()
+	return WheelEvent{
+		MouseEvent: mouseEventFrom(m),
+		DeltaX:     summaryFloat64(m, "deltaX"),
+		DeltaY:     summaryFloat64(m, "deltaY"),
+		DeltaZ:     summaryFloat64(m, "deltaZ"),
+		DeltaMode:  int(summaryFloat64(m, "deltaMode")),
+	}
+}
+
+// DragEventOf returns the event as a DragEvent.
+func DragEventOf(e DOMEvent) DragEvent {
+	m := e.EventSummary()
+	ret := DragEvent{MouseEvent: mouseEventFrom(m)}
+	dt, _ := m["dataTransfer"].(map[string]any)
+	ret.DataTransfer.DropEffect = summaryString(dt, "dropEffect")
+	ret.DataTransfer.EffectAllowed = summaryString(dt, "effectAllowed")
+	types, _ := dt["types"].([]any)
+	for _, t := range types {
+		if s, ok := t.(string); ok {
+			ret.DataTransfer.Types = append(ret.DataTransfer.Types, s)
+		}
+	}
+	ret.DataTransfer.Files = filesFrom(dt["files"])
+	return ret
+}
+
+// TouchEventOf returns the event as a TouchEvent.
+func TouchEventOf(e DOMEvent) TouchEvent {
+	m := e.EventSummary()
+	return TouchEvent{
+		ModifierKeys:   modifierKeysFrom(m),
+		Touches:        touchesFrom(m["touches"]),
+		TargetTouches:  touchesFrom(m["targetTouches"]),
+		ChangedTouches: touchesFrom(m["changedTouches"]),
+	}
+}
+
+// TargetFilesOf returns the files selected in the target element (a file input).
+func TargetFilesOf(e DOMEvent) []File {
+	target, _ := e.EventSummary()["target"].(map[string]any)
+	return filesFrom(target["files"])
+}
+
+func modifierKeysFrom(m map[string]any) ModifierKeys {
+	return ModifierKeys{
+		AltKey:   summaryBool(m, "altKey"),
+		CtrlKey:  summaryBool(m, "ctrlKey"),
+		MetaKey:  summaryBool(m, "metaKey"),
+		ShiftKey: summaryBool(m, "shiftKey"),
+	}
+}
+
+func mouseEventFrom(m map[string]any) MouseEvent {
+	return MouseEvent{
+		ModifierKeys: modifierKeysFrom(m),
+		ClientX:      summaryFloat64(m, "clientX"),
+		ClientY:      summaryFloat64(m, "clientY"),
+		ScreenX:      summaryFloat64(m, "screenX"),
+		ScreenY:      summaryFloat64(m, "screenY"),
+		PageX:        summaryFloat64(m, "pageX"),
+		PageY:        summaryFloat64(m, "pageY"),
+		OffsetX:      summaryFloat64(m, "offsetX"),
+		OffsetY:      summaryFloat64(m, "offsetY"),
+		MovementX:    summaryFloat64(m, "movementX"),
+		MovementY:    summaryFloat64(m, "movementY"),
+		Button:       int(summaryFloat64(m, "button")),
+		Buttons:      int(summaryFloat64(m, "buttons")),
+		Detail:       int(summaryFloat64(m, "detail")),
+	}
+}
+
+func filesFrom(v any) (ret []File) {
+	l, _ := v.([]any)
+	for _, fi := range l {
+		f, _ := fi.(map[string]any)
+		ret = append(ret, File{
+			Name:         summaryString(f, "name"),
+			Type:         summaryString(f, "type"),
+			Size:         int64(summaryFloat64(f, "size")),
+			LastModified: int64(summaryFloat64(f, "lastModified")),
+		})
+	}
+	return ret
+}
+
+func touchesFrom(v any) (ret []Touch) {
+	l, _ := v.([]any)
+	for _, ti := range l {
+		t, _ := ti.(map[string]any)
+		ret = append(ret, Touch{
+			Identifier:    int(summaryFloat64(t, "identifier")),
+			ClientX:       summaryFloat64(t, "clientX"),
+			ClientY:       summaryFloat64(t, "clientY"),
+			ScreenX:       summaryFloat64(t, "screenX"),
+			ScreenY:       summaryFloat64(t, "screenY"),
+			PageX:         summaryFloat64(t, "pageX"),
+			PageY:         summaryFloat64(t, "pageY"),
+			RadiusX:       summaryFloat64(t, "radiusX"),
+			RadiusY:       summaryFloat64(t, "radiusY"),
+			RotationAngle: summaryFloat64(t, "rotationAngle"),
+			Force:         summaryFloat64(t, "force"),
+		})
+	}
+	return ret
+}
+
+// the summary helpers are safe to call on a nil map
+
+func summaryString(m map[string]any, k string) string {
+	ret, _ := m[k].(string)
+	return ret
+}
+
+func summaryBool(m map[string]any, k string) bool {
+	ret, _ := m[k].(bool)
+	return ret
+}
+
+// summaryFloat64 also accepts the integer types, which is convenient for summaries built by hand in tests
+func summaryFloat64(m map[string]any, k string) float64 {
+	switch v := m[k].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
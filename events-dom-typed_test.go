@@ -0,0 +1,78 @@
+package vugu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDOMEventTyped(t *testing.T) {
+
+	assert := assert.New(t)
+
+	e := NewDOMEvent(nil, map[string]any{
+		"clientX":     10.0,
+		"clientY":     20,
+		"button":      2.0,
+		"ctrlKey":     true,
+		"key":         "Enter",
+		"code":        "Enter",
+		"repeat":      true,
+		"deltaY":      -3.0,
+		"pointerId":   7.0,
+		"pointerType": "pen",
+		"data":        "x",
+		"target": map[string]any{
+			"value":   "abc",
+			"checked": true,
+			"files":   []any{map[string]any{"name": "b.txt", "size": 4.0}},
+		},
+		"dataTransfer": map[string]any{
+			"dropEffect": "copy",
+			"types":      []any{"Files"},
+			"files":      []any{map[string]any{"name": "a.png", "type": "image/png", "size": 123.0, "lastModified": 1000.0}},
+		},
+		"touches": []any{map[string]any{"identifier": 1.0, "clientX": 5.0}},
+	})
+
+	me := MouseEventOf(e)
+	assert.Equal(10.0, me.ClientX)
+	assert.Equal(20.0, me.ClientY)
+	assert.Equal(2, me.Button)
+	assert.True(me.CtrlKey)
+	assert.False(me.ShiftKey)
+
+	ke := KeyboardEventOf(e)
+	assert.Equal("Enter", ke.Key)
+	assert.Equal("Enter", ke.Code)
+	assert.True(ke.Repeat)
+	assert.True(ke.CtrlKey)
+
+	ie := InputEventOf(e)
+	assert.Equal("x", ie.Data)
+	assert.Equal("abc", ie.Value)
+	assert.True(ie.Checked)
+
+	assert.Equal(-3.0, WheelEventOf(e).DeltaY)
+	assert.Equal(7, PointerEventOf(e).PointerID)
+	assert.Equal("pen", PointerEventOf(e).PointerType)
+	assert.Equal(10.0, PointerEventOf(e).ClientX)
+
+	de := DragEventOf(e)
+	assert.Equal("copy", de.DataTransfer.DropEffect)
+	assert.Equal([]string{"Files"}, de.DataTransfer.Types)
+	assert.Equal([]File{{Name: "a.png", Type: "image/png", Size: 123, LastModified: 1000}}, de.DataTransfer.Files)
+
+	te := TouchEventOf(e)
+	assert.Len(te.Touches, 1)
+	assert.Equal(1, te.Touches[0].Identifier)
+	assert.Equal(5.0, te.Touches[0].ClientX)
+	assert.Empty(te.ChangedTouches)
+
+	assert.Equal([]File{{Name: "b.txt", Size: 4}}, TargetFilesOf(e))
+
+	// missing data gives zero values
+	empty := NewDOMEvent(nil, nil)
+	assert.Equal(MouseEvent{}, MouseEventOf(empty))
+	assert.Equal(DragEvent{}, DragEventOf(empty))
+}
@@ -7,12 +7,16 @@ import (
 )
 
 // NewDOMEvent returns a new initialized DOMEvent.
+// Outside of wasm the JS methods are not usable but the Prop methods work as normal.
 func NewDOMEvent(eventEnv EventEnv, eventSummary map[string]any) DOMEvent {
-	return &domEvent{
+	ret := &domEvent{
 		eventSummary: eventSummary,
 		eventEnv:     eventEnv,
-		window:       js.Global().Get("window"),
 	}
+	if js.Global().Truthy() {
+		ret.window = js.Global().Get("window")
+	}
+	return ret
 }
 
 // DOMEvent is an event originated in the browser.  It wraps the JS event that comes in.
@@ -46,7 +50,8 @@ type DOMEvent interface {
 	// Accessing values returns by EventSummary incurs no additional performance or memory
 	// penalty, whereas calls to JSEvent, JSEventTarget, etc. require a call into the browser
 	// JS engine and the attendant resource usage.  So if you can get the information you
-	// need from the EventSummary, that's better.
+	// need from the EventSummary, that's better.  See also MouseEventOf, KeyboardEventOf, etc.
+	// for typed views of it.
 	EventSummary() map[string]any
 
 	// JSEvent returns a js.Value in wasm that corresponds to the event object.
@@ -185,11 +190,22 @@ func (e *domEvent) StopPropagation() {
 }
 
 // DOMEventHandlerSpec describes an event that gets registered with addEventListener.
+//
+// The modifier fields correspond to the modifiers that can be placed after the event name
+// in a template, e.g. @click.prevent.stop or @keydown.enter.ctrl.  They are applied
+// in the browser before the event is passed to Go, so for example PreventDefault
+// takes effect even though the Go handler runs asynchronously.
 type DOMEventHandlerSpec struct {
 	EventType string // "click", "mouseover", etc.
 	Func      func(DOMEvent)
 	Capture   bool
 	Passive   bool
+
+	PreventDefault  bool     // call preventDefault() on the event (.prevent)
+	StopPropagation bool     // call stopPropagation() on the event (.stop)
+	Once            bool     // only fire once, the listener is not added again until it is removed from the element (.once)
+	Self            bool     // only fire if the event target is the element itself, not a child (.self)
+	Keys            []string // key or mouse button names which must match for the handler to fire, e.g. "enter", "ctrl", "left"
 }
 
 // // DOMEventHandler is created in BuildVDOM to represent a method call that is performed to handle an event.
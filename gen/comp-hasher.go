@@ -35,3 +35,9 @@ func compHashCounted(s string) uint64 {
 	}
 	return h.Sum64()
 }
+
+// compHash returns a hash value for the given string, the same value is
+// returned each time for a given s.
+func compHash(s string) uint64 {
+	return xxhash.Sum64String(s)
+}
@@ -0,0 +1,378 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vugu/html"
+)
+
+// Scoped styles: when a component has a <style scoped> tag, every element the component outputs
+// gets an extra attribute unique to the component (see scopeAttrName) and the selectors in the
+// scoped style tags are rewritten to require that attribute on the element they target, e.g.
+// `.list li:hover::after` becomes `.list li:hover[data-vg-1a2b3c4d]::after`.
+//
+// `:deep(...)` is an escape hatch for styling elements the component does not output itself, such
+// as the contents of vg-html or the elements of child components: `.list :deep(a)` becomes
+// `.list[data-vg-1a2b3c4d] a`.
+//
+// At-rules that contain rules (@media, @supports, @container, @layer) have their contents rewritten,
+// other at-rules (@keyframes, @font-face, etc.) are left as-is.
+
+// scopeAttrName returns the attribute name used to scope styles for the given component, pkgID
+// identifies its package (see ParserGo.scopePkgID) so components with the same name in different
+// packages get different attributes
+func scopeAttrName(pkgID, structType string) string {
+	return fmt.Sprintf("data-vg-%08x", uint32(compHash(pkgID+"."+structType)))
+}
+
+// hasScopedStyle returns true if there is a <style scoped> anywhere in the tree
+func hasScopedStyle(n *html.Node) bool {
+	if isScopedStyle(n) {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if hasScopedStyle(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func isScopedStyle(n *html.Node) bool {
+	return n.Type == html.ElementNode && strings.ToLower(n.Data) == "style" && attrWithKey(n, "scoped") != nil
+}
+
+// scopeNodes adds the scope attribute to each element in the tree which will be output as a regular
+// DOM element by this component. Components and their slot contents are not stamped themselves,
+// but the elements inside slot contents are (they belong to this component's template).
+func scopeNodes(n *html.Node, attrName string) {
+
+	if n.Type == html.ElementNode {
+		nodeName := strings.ToLower(n.Data)
+		switch {
+		case isScriptOrStyle(n), nodeName == "head":
+			return
+		case nodeName == "html", nodeName == "body",
+			strings.Contains(n.Data, ":"), strings.HasPrefix(nodeName, "vg-"):
+			// not stamped, but children are
+		default:
+			if attrWithKey(n, attrName) == nil {
+				n.Attr = append(n.Attr, html.Attribute{Key: attrName, OrigKey: attrName})
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		scopeNodes(c, attrName)
+	}
+}
+
+// at-rules whose block contains rules which need to be scoped
+var scopeNestedAtRules = map[string]bool{
+	"media":          true,
+	"supports":       true,
+	"container":      true,
+	"layer":          true,
+	"document":       true,
+	"-moz-document":  true,
+	"starting-style": true,
+}
+
+// scopeCSS rewrites the selectors in css to only match elements having attrName
+func scopeCSS(css, attrName string) (string, error) {
+	var sb strings.Builder
+	_, err := scopeCSSBlock(&sb, css, 0, attrName, false)
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// scopeCSSBlock processes a list of rules starting at i until the end of css, or until
+// the closing brace if nested is true, and returns the position after that brace
+func scopeCSSBlock(sb *strings.Builder, css string, i int, attrName string, nested bool) (int, error) {
+
+	for {
+
+		// whitespace and comments are copied as-is
+		start := i
+		i = skipCSSSpace(css, i)
+		sb.WriteString(css[start:i])
+
+		if i >= len(css) {
+			if nested {
+				return i, fmt.Errorf("unexpected end of CSS, missing '}'")
+			}
+			return i, nil
+		}
+
+		if css[i] == '}' {
+			if !nested {
+				return i, fmt.Errorf("unexpected '}' at offset %d", i)
+			}
+			sb.WriteByte('}')
+			return i + 1, nil
+		}
+
+		end := scanCSS(css, i, "{;}")
+		prelude := css[i:end]
+
+		if end >= len(css) {
+			return i, fmt.Errorf("unexpected end of CSS after %q", prelude)
+		}
+
+		// statements like @import and @charset, or a stray semicolon
+		if css[end] != '{' {
+			if css[end] == ';' {
+				end++
+			}
+			sb.WriteString(css[i:end])
+			i = end
+			continue
+		}
+
+		if strings.HasPrefix(prelude, "@") {
+			name := strings.ToLower(prelude[1:])
+			if idx := strings.IndexAny(name, " \t\r\n(/"); idx >= 0 {
+				name = name[:idx]
+			}
+			sb.WriteString(prelude)
+			if scopeNestedAtRules[name] {
+				sb.WriteByte('{')
+				var err error
+				i, err = scopeCSSBlock(sb, css, end+1, attrName, true)
+				if err != nil {
+					return i, err
+				}
+				continue
+			}
+			blockEnd, err := matchCSSBrace(css, end)
+			if err != nil {
+				return i, err
+			}
+			sb.WriteString(css[end:blockEnd])
+			i = blockEnd
+			continue
+		}
+
+		sel, err := scopeSelectorList(prelude, attrName)
+		if err != nil {
+			return i, err
+		}
+		sb.WriteString(sel)
+
+		blockEnd, err := matchCSSBrace(css, end)
+		if err != nil {
+			return i, err
+		}
+		sb.WriteString(css[end:blockEnd])
+		i = blockEnd
+	}
+}
+
+// scopeSelectorList scopes each selector in a comma separated list
+func scopeSelectorList(list, attrName string) (string, error) {
+
+	trailing := list[len(strings.TrimRight(list, " \t\r\n")):]
+
+	var parts []string
+	for i := 0; i <= len(list); {
+		end := scanCSS(list, i, ",")
+		sel, err := scopeSelector(strings.TrimSpace(list[i:end]), attrName)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, sel)
+		i = end + 1
+	}
+
+	return strings.Join(parts, ", ") + trailing, nil
+}
+
+// scopeSelector scopes a single complex selector
+func scopeSelector(sel, attrName string) (string, error) {
+
+	if sel == "" {
+		return "", fmt.Errorf("empty selector")
+	}
+
+	attrSel := "[" + attrName + "]"
+
+	idx := indexCSS(strings.ToLower(sel), ":deep(")
+	if idx < 0 {
+		return insertScopeAttr(sel, attrSel), nil
+	}
+
+	argStart := idx + len(":deep(")
+	argEnd := scanCSS(sel, argStart, ")")
+	if argEnd >= len(sel) {
+		return "", fmt.Errorf("unterminated :deep() in selector %q", sel)
+	}
+	inner := strings.TrimSpace(sel[argStart:argEnd])
+	rest := sel[argEnd+1:]
+
+	// the attribute goes on the last compound before :deep(), or on its own if there is none
+	before := strings.TrimSpace(sel[:idx])
+	comb := " "
+	if n := len(before); n > 0 && strings.IndexByte(">+~", before[n-1]) >= 0 {
+		comb = " " + before[n-1:] + " "
+		before = strings.TrimSpace(before[:n-1])
+	}
+	if before == "" {
+		return attrSel + comb + inner + rest, nil
+	}
+	return insertScopeAttr(before, attrSel) + comb + inner + rest, nil
+}
+
+// insertScopeAttr adds attrSel to the last compound selector in sel, before any pseudo-element
+func insertScopeAttr(sel, attrSel string) string {
+
+	// find the start of the last compound
+	last := 0
+	for i := 0; i < len(sel); {
+		j := scanCSS(sel, i, " \t\r\n>+~")
+		if j >= len(sel) {
+			break
+		}
+		last = j + 1
+		i = j + 1
+	}
+
+	lower := strings.ToLower(sel)
+	for i := last; i < len(sel); {
+		j := scanCSS(sel, i, ":")
+		if j >= len(sel) {
+			break
+		}
+		if strings.HasPrefix(sel[j:], "::") || isLegacyPseudoElement(lower[j+1:]) {
+			return sel[:j] + attrSel + sel[j:]
+		}
+		i = j + 1
+	}
+
+	return sel + attrSel
+}
+
+// the pseudo-elements which may be written with a single colon
+func isLegacyPseudoElement(s string) bool {
+	for _, name := range []string{"before", "after", "first-line", "first-letter"} {
+		if strings.HasPrefix(s, name) && (len(s) == len(name) || !isCSSIdentChar(s[len(name)])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCSSIdentChar(c byte) bool {
+	return c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// indexCSS is like strings.Index but only matches at the top level (not inside strings, comments, parens or brackets)
+func indexCSS(s, substr string) int {
+	for i := 0; i < len(s); {
+		j := scanCSS(s, i, substr[:1])
+		if j >= len(s) {
+			return -1
+		}
+		if strings.HasPrefix(s[j:], substr) {
+			return j
+		}
+		i = j + 1
+	}
+	return -1
+}
+
+// scanCSS returns the position of the first byte in stops at or after i which is at the top level,
+// i.e. not escaped, inside a string or comment, or inside parens or brackets, or len(s) if none
+func scanCSS(s string, i int, stops string) int {
+	depth := 0
+	for i < len(s) {
+		c := s[i]
+		if depth == 0 && strings.IndexByte(stops, c) >= 0 {
+			return i
+		}
+		switch {
+		case c == '\\':
+			i += 2
+			continue
+		case c == '"' || c == '\'':
+			i = skipCSSString(s, i)
+			continue
+		case c == '/' && strings.HasPrefix(s[i:], "/*"):
+			i = skipCSSComment(s, i)
+			continue
+		case c == '(' || c == '[':
+			depth++
+		case (c == ')' || c == ']') && depth > 0:
+			depth--
+		}
+		i++
+	}
+	return len(s)
+}
+
+// matchCSSBrace returns the position after the brace which closes the one at i
+func matchCSSBrace(s string, i int) (int, error) {
+	depth := 0
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '\\':
+			i += 2
+			continue
+		case c == '"' || c == '\'':
+			i = skipCSSString(s, i)
+			continue
+		case c == '/' && strings.HasPrefix(s[i:], "/*"):
+			i = skipCSSComment(s, i)
+			continue
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+		i++
+	}
+	return len(s), fmt.Errorf("unexpected end of CSS, missing '}'")
+}
+
+// skipCSSString returns the position after the string starting at i
+func skipCSSString(s string, i int) int {
+	q := s[i]
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case q:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// skipCSSComment returns the position after the comment starting at i
+func skipCSSComment(s string, i int) int {
+	end := strings.Index(s[i+2:], "*/")
+	if end < 0 {
+		return len(s)
+	}
+	return i + 2 + end + 2
+}
+
+// skipCSSSpace returns the position of the next byte after i which is not whitespace or in a comment
+func skipCSSSpace(s string, i int) int {
+	for i < len(s) {
+		switch {
+		case s[i] == ' ' || s[i] == '\t' || s[i] == '\r' || s[i] == '\n' || s[i] == '\f':
+			i++
+		case strings.HasPrefix(s[i:], "/*"):
+			i = skipCSSComment(s, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
@@ -0,0 +1,80 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vugu/html"
+	"github.com/vugu/html/atom"
+)
+
+func TestScopeCSS(t *testing.T) {
+
+	assert := assert.New(t)
+
+	tcases := []struct {
+		in, out string
+	}{
+		{`p { color: red; }`, `p[s] { color: red; }`},
+		{`.a .b, .c>.d{x:y}`, `.a .b[s], .c>.d[s]{x:y}`},
+		{`a:hover::after, li:before {}`, `a:hover[s]::after, li[s]:before {}`},
+		{`a:not(.x .y) {}`, `a:not(.x .y)[s] {}`},
+		{`input[type="a b"] ~ label {}`, `input[type="a b"] ~ label[s] {}`},
+		{`.a :deep(.b .c) {}`, `.a[s] .b .c {}`},
+		{`.a > :deep(b):hover {}`, `.a[s] > b:hover {}`},
+		{`:deep(.b) {}`, `[s] .b {}`},
+		{`/* p { } */ p { content: "}"; }`, `/* p { } */ p[s] { content: "}"; }`},
+		{`@import url("x.css"); p {}`, `@import url("x.css"); p[s] {}`},
+		{`@media (max-width: 10px) { p, a { x: y } }`, `@media (max-width: 10px) { p[s], a[s] { x: y } }`},
+		{`@keyframes spin { from { x: 0 } to { x: 1 } } p {}`, `@keyframes spin { from { x: 0 } to { x: 1 } } p[s] {}`},
+		{`.a\:b {}`, `.a\:b[s] {}`},
+	}
+
+	for _, tc := range tcases {
+		out, err := scopeCSS(tc.in, "s")
+		assert.NoError(err, "input %q", tc.in)
+		assert.Equal(tc.out, out, "input %q", tc.in)
+	}
+
+	for _, bad := range []string{`p {`, `p {} }`, `@media x { p {}`, `.a :deep(.b {}`, `p, {}`} {
+		_, err := scopeCSS(bad, "s")
+		assert.Error(err, "input %q", bad)
+	}
+}
+
+func TestScopeNodes(t *testing.T) {
+
+	assert := assert.New(t)
+
+	nlist, err := html.ParseFragment(strings.NewReader(`<div><p>a</p><pkg:Comp><span>slot</span></pkg:Comp><vg-template><b></b></vg-template><style scoped>p{}</style></div>`),
+		&html.Node{Type: html.ElementNode, DataAtom: atom.Div, Data: "div"})
+	assert.NoError(err)
+	n := nlist[0]
+
+	assert.True(hasScopedStyle(n))
+	scopeNodes(n, "s")
+
+	var buf strings.Builder
+	assert.NoError(html.Render(&buf, n))
+	assert.Equal(`<div s=""><p s="">a</p><pkg:comp><span s="">slot</span></pkg:comp><vg-template><b s=""></b></vg-template><style scoped="">p{}</style></div>`, buf.String())
+}
+
+func TestScopePkgID(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n"), 0644))
+	for _, sub := range []string{"a/ui", "b/ui"} {
+		assert.NoError(os.MkdirAll(filepath.Join(dir, sub), 0755))
+	}
+
+	// two packages named ui with a Button component each get different scope attributes
+	pa := &ParserGo{PackageName: "ui", StructType: "Button", OutDir: filepath.Join(dir, "a/ui")}
+	pb := &ParserGo{PackageName: "ui", StructType: "Button", OutDir: filepath.Join(dir, "b/ui")}
+	assert.Equal("example.com/app/a/ui", pa.scopePkgID("button.vugu"))
+	assert.NotEqual(scopeAttrName(pa.scopePkgID("button.vugu"), "Button"), scopeAttrName(pb.scopePkgID("button.vugu"), "Button"))
+}
@@ -0,0 +1,292 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vugu/html"
+)
+
+// Generic components: a component whose struct has type parameters, e.g.
+//
+//	type List[T any] struct { Items []T; Selected T }
+//
+// gets a Build method with the type parameters on its receiver.  Other components use it either with
+// the type arguments in the tag, <ui:List[Customer] :Items="c.Customers">, or with empty brackets,
+// <ui:List[] :Items="c.Customers">, to infer them from the first bound property.  The inference is done
+// by a VGInfer function which is emitted along with the Build method for each exported field whose
+// type uses all of the type parameters (VGInferListItems in the example).  For a component in the same
+// package the first bound property which has a VGInfer function is used, and it is an error if none has.
+//
+// Component events on a tag with type arguments use the same type arguments, so the events of a generic
+// component are declared with the same type parameters, e.g. `//vugugen:event Select[T any]`.
+
+// genericInfo describes the type parameters and fields of a generic component
+type genericInfo struct {
+	typeParams string   // the type parameter list as declared, without brackets, e.g. "K comparable, V any"
+	names      []string // the type parameter names, e.g. K, V
+	fields     []genericField
+}
+
+// genericField is an exported field of a generic component
+type genericField struct {
+	name     string
+	typeExpr string
+	usesAll  bool // true if the type uses all of the type parameters, so they can be inferred from it
+}
+
+// receiverTypeArgs returns the type arguments for the receiver of methods, e.g. "[K, V]"
+func (gi *genericInfo) receiverTypeArgs() string {
+	if gi == nil {
+		return ""
+	}
+	return "[" + strings.Join(gi.names, ", ") + "]"
+}
+
+// findTypeDeclSource returns the Go source of the declaration of the type typeName in src
+// (a Go file, or the contents of a .vugu Go block, without the package clause if addPackage is true),
+// or an empty string if it is not found or src does not parse
+func findTypeDeclSource(src string, typeName string, addPackage bool) string {
+	if addPackage {
+		src = "package p\n" + src
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return ""
+	}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok || ts.Name.Name != typeName {
+				continue
+			}
+			var buf bytes.Buffer
+			buf.WriteString("type ")
+			if err := printer.Fprint(&buf, fset, ts); err != nil {
+				return ""
+			}
+			return buf.String()
+		}
+	}
+	return ""
+}
+
+// findPkgTypeDecls finds the type declarations for typeNames in the non-generated .go files in dir,
+// the result maps type name to the Go source of its declaration
+func findPkgTypeDecls(dir string, typeNames []string, skipFiles map[string]bool) (map[string]string, error) {
+
+	fnames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fnames)
+
+	ret := make(map[string]string, len(typeNames))
+	for _, fname := range fnames {
+		if skipFiles[filepath.Base(fname)] || strings.HasSuffix(fname, "_test.go") {
+			continue
+		}
+		b, err := os.ReadFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		for _, typeName := range typeNames {
+			if _, ok := ret[typeName]; ok || !bytes.Contains(b, []byte(typeName)) {
+				continue
+			}
+			if decl := findTypeDeclSource(string(b), typeName, false); decl != "" {
+				ret[typeName] = decl
+			}
+		}
+	}
+	return ret, nil
+}
+
+// goScriptSource returns the contents of the <script type="application/x-go"> blocks in the tree
+func goScriptSource(n *html.Node) string {
+	var buf bytes.Buffer
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" {
+			if ty := attrWithKey(n, "type"); ty != nil && strings.Split(strings.TrimSpace(ty.Val), ";")[0] == "application/x-go" {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					buf.WriteString(c.Data)
+				}
+				buf.WriteString("\n")
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return buf.String()
+}
+
+// parseGenericInfo parses a type declaration (e.g. from findTypeDeclSource) and returns
+// its genericInfo, or nil if the type has no type parameters
+func parseGenericInfo(typeDecl string) (*genericInfo, error) {
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package p\n"+typeDecl, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse type declaration: %w", err)
+	}
+	if len(f.Decls) != 1 {
+		return nil, fmt.Errorf("expected one type declaration, found %d", len(f.Decls))
+	}
+	ts := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+	if ts.TypeParams == nil || len(ts.TypeParams.List) == 0 {
+		return nil, nil
+	}
+
+	gi := &genericInfo{}
+
+	var params []string
+	for _, field := range ts.TypeParams.List {
+		var cbuf bytes.Buffer
+		if err := printer.Fprint(&cbuf, fset, field.Type); err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		gi.names = append(gi.names, names...)
+		params = append(params, strings.Join(names, ", ")+" "+cbuf.String())
+	}
+	gi.typeParams = strings.Join(params, ", ")
+
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return gi, nil
+	}
+	for _, field := range st.Fields.List {
+		var tbuf bytes.Buffer
+		if err := printer.Fprint(&tbuf, fset, field.Type); err != nil {
+			return nil, err
+		}
+		used := make(map[string]bool)
+		ast.Inspect(field.Type, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+			return true
+		})
+		usesAll := true
+		for _, name := range gi.names {
+			usesAll = usesAll && used[name]
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			gi.fields = append(gi.fields, genericField{name: name.Name, typeExpr: tbuf.String(), usesAll: usesAll})
+		}
+	}
+
+	return gi, nil
+}
+
+// inferFuncName returns the name of the VGInfer function for a field of a generic component
+func inferFuncName(structType, field string) string {
+	return "VGInfer" + structType + field
+}
+
+// writeInferFuncs emits the VGInfer functions for a generic component, see the comment at the top of this file
+func (p *ParserGo) writeInferFuncs(state *parseGoState) {
+	gi := state.generic
+	for _, f := range gi.fields {
+		if !f.usesAll {
+			continue
+		}
+		fname := inferFuncName(p.StructType, f.name)
+		fmt.Fprintf(&state.goBufBottom, "// %s returns the cached %s (or a new one if it is not a *%s with the same type arguments)\n", fname, p.StructType, p.StructType)
+		fmt.Fprintf(&state.goBufBottom, "// with the type arguments inferred from a value for %s, for generated code only.\n", f.name)
+		fmt.Fprintf(&state.goBufBottom, "func %s[%s](cached any, v %s) (c *%s%s, isNew bool) {\n", fname, gi.typeParams, f.typeExpr, p.StructType, gi.receiverTypeArgs())
+		fmt.Fprintf(&state.goBufBottom, "    if c, ok := cached.(*%s%s); ok {\n", p.StructType, gi.receiverTypeArgs())
+		fmt.Fprintf(&state.goBufBottom, "        return c, false\n")
+		fmt.Fprintf(&state.goBufBottom, "    }\n")
+		fmt.Fprintf(&state.goBufBottom, "    return new(%s%s), true\n", p.StructType, gi.receiverTypeArgs())
+		fmt.Fprintf(&state.goBufBottom, "}\n\n")
+	}
+}
+
+// splitTypeArgs splits a component tag name like "ui:List[Customer]" into "ui:List" and "[Customer]"
+func splitTypeArgs(nodeName string) (name, typeArgs string, err error) {
+	i := strings.IndexByte(nodeName, '[')
+	if i < 0 {
+		return nodeName, "", nil
+	}
+	if !strings.HasSuffix(nodeName, "]") {
+		return "", "", fmt.Errorf("invalid component tag name %q, type arguments must be at the end in brackets", nodeName)
+	}
+	return nodeName[:i], nodeName[i:], nil
+}
+
+// firstBoundProp returns the name of the first (in the order they appear) bound field on a component tag,
+// i.e. :Name="expr", and its expression.  If ok is not nil only the fields it returns true for are considered.
+func firstBoundProp(n *html.Node, ok func(field string) bool) (name, expr string) {
+	for _, a := range n.Attr {
+		k := a.OrigKey
+		if !strings.HasPrefix(k, ":") {
+			continue
+		}
+		k = k[1:]
+		if hasUpperFirst(k) && (ok == nil || ok(k)) {
+			return k, a.Val
+		}
+	}
+	return "", ""
+}
+
+// canInferFrom returns true if the type arguments can be inferred from a value for the field,
+// i.e. it has a VGInfer function
+func (gi *genericInfo) canInferFrom(field string) bool {
+	for _, f := range gi.fields {
+		if f.name == field {
+			return f.usesAll
+		}
+	}
+	return false
+}
+
+// pkgGenericInfo returns the genericInfo for the component type typeName in the same package, or nil
+// if its declaration is not known (see ParserGo.PkgTypeDecls).  It is an error if the type is not generic.
+func (p *ParserGo) pkgGenericInfo(state *parseGoState, typeName string) (*genericInfo, error) {
+	var gi *genericInfo
+	if typeName == p.StructType {
+		if state.generic == nil {
+			return nil, nil
+		}
+		gi = state.generic
+	} else {
+		decl := p.PkgTypeDecls[typeName]
+		if decl == "" {
+			return nil, nil
+		}
+		var err error
+		gi, err = parseGenericInfo(decl)
+		if err != nil {
+			return nil, fmt.Errorf("type %s: %w", typeName, err)
+		}
+	}
+	if gi == nil {
+		return nil, fmt.Errorf("component %s has empty type arguments but is not generic", typeName)
+	}
+	return gi, nil
+}
@@ -139,6 +139,14 @@ type %s struct {}
 			c := strings.TrimSpace(c)
 			c = strings.TrimPrefix(c, "//vugugen:")
 
+			// type parameters of generic events may contain whitespace, e.g. `//vugugen:event Select[K comparable, V any]`,
+			// so they are taken out before splitting and put back on the event name
+			var typeParams string
+			if i, j := strings.Index(c, "["), strings.LastIndex(c, "]"); i >= 0 && j > i {
+				typeParams = c[i : j+1]
+				c = c[:i] + "[]" + c[j+1:]
+			}
+
 			cparts := strings.Fields(c) // split by whitespace
 
 			if len(cparts) == 0 {
@@ -161,6 +169,19 @@ type %s struct {}
 					return fmt.Errorf("error parsing %s vugugen event comment, event name must start with a capital letter: %q", fname, c)
 				}
 
+				// generic event, the type parameters go on each of the types (tp) and the type arguments are the names (ta)
+				tp, ta := "", ""
+				if strings.HasSuffix(eventName, "[]") && typeParams != "" {
+					eventName = strings.TrimSuffix(eventName, "[]")
+					gi, err := parseGenericInfo("type " + eventName + typeParams + " struct{}")
+					if err != nil || gi == nil {
+						return fmt.Errorf("error parsing %s vugugen event comment, invalid type parameters %q", fname, typeParams)
+					}
+					tp, ta = "["+gi.typeParams+"]", gi.receiverTypeArgs()
+				} else if typeParams != "" {
+					return fmt.Errorf("error parsing %s vugugen event comment, type parameters must directly follow the event name: %q", fname, c)
+				}
+
 				opts := args[1:]
 				// isInterface := false
 
@@ -181,37 +202,47 @@ type %s struct {}
 				// emit type if missing as a struct wrapper around a DOMEvent
 				if decl == nil {
 					fmt.Fprintf(fout, `// %sEvent is a component event.
-type %sEvent struct {
+type %sEvent%s struct {
 	vugu.DOMEvent
 }
 
-`, eventName, eventName)
+`, eventName, eventName, tp)
 				}
 
 				// check for NameHandler type, emit if missing
 				decl = findTypeDecl(&fset, pkg, eventName+"Handler")
 				if decl == nil {
 					fmt.Fprintf(fout, `// %sHandler is the interface for things that can handle %sEvent.
-type %sHandler interface {
-	%sHandle(event %sEvent)
+type %sHandler%s interface {
+	%sHandle(event %sEvent%s)
 }
 
-`, eventName, eventName, eventName, eventName, eventName)
+`, eventName, eventName, eventName, tp, eventName, eventName, ta)
 				}
 
 				// check for NameFunc type, emit if missing along with method and type check
 				decl = findTypeDecl(&fset, pkg, eventName+"Func")
 				if decl == nil {
 					fmt.Fprintf(fout, `// %sFunc implements %sHandler as a function.
-type %sFunc func(event %sEvent)
+type %sFunc%s func(event %sEvent%s)
 
 // %sHandle implements the %sHandler interface.
-func (f %sFunc) %sHandle(event %sEvent) { f(event) }
+func (f %sFunc%s) %sHandle(event %sEvent%s) { f(event) }
 
-// assert %sFunc implements %sHandler
+`, eventName, eventName, eventName, tp, eventName, ta, eventName, eventName, eventName, ta, eventName, eventName, ta)
+
+					// the type check needs type arguments for a generic event, so it goes in a generic func
+					if tp != "" {
+						fmt.Fprintf(fout, `// assert %sFunc implements %sHandler
+func _%s() { var _ %sHandler%s = %sFunc%s(nil) }
+
+`, eventName, eventName, tp, eventName, ta, eventName, ta)
+					} else {
+						fmt.Fprintf(fout, `// assert %sFunc implements %sHandler
 var _ %sHandler = %sFunc(nil)
 
-`, eventName, eventName, eventName, eventName, eventName, eventName, eventName, eventName, eventName, eventName, eventName, eventName, eventName)
+`, eventName, eventName, eventName, eventName)
+					}
 				}
 
 			default:
@@ -392,8 +423,15 @@ func findFileBuildMethodType(file *ast.File) string {
 		if !ok {
 			continue
 		}
-		// to an identifier
-		xident, ok := starExpr.X.(*ast.Ident)
+		// to an identifier, with type parameters for generic components
+		x := starExpr.X
+		switch ix := x.(type) {
+		case *ast.IndexExpr:
+			x = ix.X
+		case *ast.IndexListExpr:
+			x = ix.X
+		}
+		xident, ok := x.(*ast.Ident)
 		if !ok {
 			continue
 		}
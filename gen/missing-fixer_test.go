@@ -28,7 +28,7 @@ func TestMissingFixer(t *testing.T) {
 	vuguAbs, _ := filepath.Abs("..")
 
 	must(os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module missingfixertest\n\nreplace github.com/vugu/vugu => "+vuguAbs+"\n"), 0644))
-	must(os.WriteFile(filepath.Join(tmpDir, "events.go"), []byte("package main\n\n//vugugen:event Something\n//vugugen:event SomeOtherThing\n//vugugen:event SomeOtherThing\n"), 0644))
+	must(os.WriteFile(filepath.Join(tmpDir, "events.go"), []byte("package main\n\n//vugugen:event Something\n//vugugen:event SomeOtherThing\n//vugugen:event SomeOtherThing\n//vugugen:event Pick[K comparable, V any]\n"), 0644))
 	must(os.WriteFile(filepath.Join(tmpDir, "root.vugu"), []byte("<div>root</div>"), 0644))
 	must(os.WriteFile(filepath.Join(tmpDir, "root_gen.go"), []byte("package main\n\nimport \"github.com/vugu/vugu\"\n\nfunc (c *Root)Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {return nil}"), 0644))
 	// a second component that does include it's own struct definition
@@ -73,6 +73,13 @@ func TestMissingFixer(t *testing.T) {
 		"func (f SomethingFunc) SomethingHandle(",
 		"var _ SomethingHandler =",
 
+		"type PickEvent[K comparable, V any] struct",
+		"type PickHandler[K comparable, V any] interface",
+		"PickHandle(event PickEvent[K, V])",
+		"type PickFunc[K comparable, V any] func(event PickEvent[K, V])",
+		"func (f PickFunc[K, V]) PickHandle(",
+		"var _ PickHandler[K, V] = PickFunc[K, V](nil)",
+
 		// "type PartEvent struct", // should exist only in epart.go
 
 		// "type SomeOtherThingEvent interface",
@@ -0,0 +1,219 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vugu/html"
+)
+
+// Conditional chains: an element with vg-if may be followed by sibling elements with vg-else-if and
+// finally vg-else, which are output as a Go if/else if/else statement.  Only whitespace and comments
+// may appear between the elements of a chain (they are removed).
+//
+// vg-switch on an element (or vg-template) turns its children into the cases of a Go switch statement,
+// each child must have vg-case (a Go expression list, like a case clause) or vg-default.
+// An empty vg-switch is a switch with no tag, so each vg-case is a boolean condition.
+//
+// These are checked by checkCondTree before the code is generated and then emitted by emitCondBranch,
+// which is called from each visit method before it handles vg-for and vg-if.
+
+// checkCondTree validates the conditional chains and vg-switch elements in the tree and removes
+// the whitespace and comments between their elements, so they are output contiguously
+func checkCondTree(n *html.Node) error {
+
+	if isCondBranch(n) && n.Parent == nil {
+		return fmt.Errorf("%s is not supported on top level element <%s>", condBranchAttr(n), n.Data)
+	}
+
+	if _, ok := vgSwitchExpr(n); ok {
+		if err := checkSwitchChildren(n); err != nil {
+			return err
+		}
+	} else if err := checkChainChildren(n); err != nil {
+		return err
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if err := checkCondTree(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkChainChildren validates the vg-if/vg-else-if/vg-else chains among the children of n
+func checkChainChildren(n *html.Node) error {
+
+	var prev *html.Node      // the last element of an open chain
+	var between []*html.Node // whitespace and comments after prev
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+
+		if isIgnorableNode(c) {
+			if prev != nil {
+				between = append(between, c)
+			}
+			continue
+		}
+
+		if c.Type == html.ElementNode {
+			if attrWithKey(c, "vg-case") != nil || attrWithKey(c, "vg-default") != nil {
+				return fmt.Errorf("<%s> with vg-case or vg-default must be a direct child of an element with vg-switch", c.Data)
+			}
+		}
+
+		elseIf := attrWithKey(c, "vg-else-if")
+		els := attrWithKey(c, "vg-else")
+
+		if c.Type == html.ElementNode && (elseIf != nil || els != nil) {
+
+			if elseIf != nil && els != nil {
+				return fmt.Errorf("<%s> cannot have both vg-else-if and vg-else", c.Data)
+			}
+			if attrWithKey(c, "vg-if") != nil {
+				return fmt.Errorf("<%s> cannot have both vg-if and %s", c.Data, condBranchAttr(c))
+			}
+			if prev == nil {
+				return fmt.Errorf("%s on <%s> without a preceding element with vg-if or vg-else-if", condBranchAttr(c), c.Data)
+			}
+			if elseIf != nil && strings.TrimSpace(elseIf.Val) == "" {
+				return fmt.Errorf("vg-else-if on <%s> must have a condition", c.Data)
+			}
+			if els != nil && strings.TrimSpace(els.Val) != "" {
+				return fmt.Errorf("vg-else on <%s> does not take a value (found %q), use vg-else-if for a condition", c.Data, els.Val)
+			}
+			if v, _ := vgForExpr(prev); v.expr != "" && attrWithKey(prev, "vg-if") != nil {
+				return fmt.Errorf("<%s> with vg-if and vg-for cannot be followed by %s, wrap it in a vg-template", prev.Data, condBranchAttr(c))
+			}
+
+			for _, b := range between {
+				n.RemoveChild(b)
+			}
+			between = nil
+
+			prev = c
+			if els != nil {
+				prev = nil // chain is complete
+			}
+			continue
+		}
+
+		// anything else ends a chain, and an element with vg-if starts a new one
+		between = nil
+		prev = nil
+		if c.Type == html.ElementNode && attrWithKey(c, "vg-if") != nil {
+			prev = c
+		}
+	}
+
+	return nil
+}
+
+// checkSwitchChildren validates the children of an element with vg-switch
+func checkSwitchChildren(n *html.Node) error {
+
+	if strings.Contains(n.Data, ":") || n.Data == "vg-comp" {
+		return fmt.Errorf("vg-switch is not supported on component element <%s>", n.OrigData)
+	}
+
+	foundDefault := false
+
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		if isIgnorableNode(c) {
+			n.RemoveChild(c)
+			c = next
+			continue
+		}
+
+		if c.Type != html.ElementNode {
+			return fmt.Errorf("<%s> with vg-switch may only contain elements with vg-case or vg-default, found %q", n.Data, strings.TrimSpace(c.Data))
+		}
+
+		cs := attrWithKey(c, "vg-case")
+		def := attrWithKey(c, "vg-default")
+		switch {
+		case cs != nil && def != nil:
+			return fmt.Errorf("<%s> cannot have both vg-case and vg-default", c.Data)
+		case cs != nil:
+			if strings.TrimSpace(cs.Val) == "" {
+				return fmt.Errorf("vg-case on <%s> must have a value", c.Data)
+			}
+		case def != nil:
+			if foundDefault {
+				return fmt.Errorf("<%s> with vg-switch has more than one vg-default", n.Data)
+			}
+			foundDefault = true
+		default:
+			return fmt.Errorf("<%s> inside vg-switch must have vg-case or vg-default", c.Data)
+		}
+
+		if isCondBranch(c) {
+			return fmt.Errorf("<%s> with vg-case or vg-default cannot have %s", c.Data, condBranchAttr(c))
+		}
+
+		c = next
+	}
+
+	return nil
+}
+
+// emitCondBranch writes the start of the vg-else-if, vg-else, vg-case or vg-default branch for n, if any,
+// and returns the code which closes it.  A vg-else-if or vg-else continues the if statement which
+// the previous sibling left open (see condChainEnd), checkCondTree ensures nothing is output in between.
+func emitCondBranch(state *parseGoState, n *html.Node) (string, error) {
+
+	if a := attrWithKey(n, "vg-case"); a != nil {
+		fmt.Fprintf(&state.buildBuf, "case %s:\n", a.Val)
+		return "", nil
+	}
+	if attrWithKey(n, "vg-default") != nil {
+		fmt.Fprintf(&state.buildBuf, "default:\n")
+		return "", nil
+	}
+
+	if a := attrWithKey(n, "vg-else-if"); a != nil {
+		fmt.Fprintf(&state.buildBuf, "} else if %s {\n", a.Val)
+		return condChainEnd(n), nil
+	}
+	if attrWithKey(n, "vg-else") != nil {
+		fmt.Fprintf(&state.buildBuf, "} else {\n")
+		return "}\n", nil
+	}
+	return "", nil
+}
+
+// condChainEnd returns the code which closes the if statement started for the vg-if or vg-else-if on n.
+// It is empty if the next sibling continues the chain, in which case its emitCondBranch closes the block.
+func condChainEnd(n *html.Node) string {
+	if n.NextSibling != nil && isCondBranch(n.NextSibling) {
+		return ""
+	}
+	return "}\n"
+}
+
+func isCondBranch(n *html.Node) bool {
+	return condBranchAttr(n) != ""
+}
+
+// condBranchAttr returns "vg-else-if" or "vg-else" if n has one of them
+func condBranchAttr(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	if attrWithKey(n, "vg-else-if") != nil {
+		return "vg-else-if"
+	}
+	if attrWithKey(n, "vg-else") != nil {
+		return "vg-else"
+	}
+	return ""
+}
+
+// isIgnorableNode returns true for comments and whitespace-only text
+func isIgnorableNode(n *html.Node) bool {
+	return n.Type == html.CommentNode || (n.Type == html.TextNode && strings.TrimSpace(n.Data) == "")
+}
@@ -0,0 +1,117 @@
+package gen
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondErrors(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		in            string
+		expectedError string
+	}{
+		{
+			name:          "else without if",
+			in:            `<div><p>a</p><p vg-else>b</p></div>`,
+			expectedError: "vg-else on <p> without a preceding element with vg-if or vg-else-if",
+		},
+		{
+			name:          "else-if after text",
+			in:            `<div><p vg-if="true">a</p>text<p vg-else-if="false">b</p></div>`,
+			expectedError: "vg-else-if on <p> without a preceding element with vg-if or vg-else-if",
+		},
+		{
+			name:          "else after else",
+			in:            `<div><p vg-if="true">a</p><p vg-else>b</p><p vg-else>c</p></div>`,
+			expectedError: "vg-else on <p> without a preceding element with vg-if or vg-else-if",
+		},
+		{
+			name:          "else with value",
+			in:            `<div><p vg-if="true">a</p><p vg-else="x">b</p></div>`,
+			expectedError: `vg-else on <p> does not take a value (found "x"), use vg-else-if for a condition`,
+		},
+		{
+			name:          "else-if without condition",
+			in:            `<div><p vg-if="true">a</p><p vg-else-if>b</p></div>`,
+			expectedError: "vg-else-if on <p> must have a condition",
+		},
+		{
+			name:          "if and else on the same element",
+			in:            `<div><p vg-if="true">a</p><p vg-if="true" vg-else>b</p></div>`,
+			expectedError: "<p> cannot have both vg-if and vg-else",
+		},
+		{
+			name:          "if with for followed by else",
+			in:            `<div><p vg-for="c.Items" vg-if="true">a</p><p vg-else>b</p></div>`,
+			expectedError: "<p> with vg-if and vg-for cannot be followed by vg-else, wrap it in a vg-template",
+		},
+		{
+			name:          "top level else",
+			in:            `<div vg-else></div>`,
+			expectedError: "vg-else is not supported on top level element <div>",
+		},
+		{
+			name:          "case outside switch",
+			in:            `<div><p vg-case="1">a</p></div>`,
+			expectedError: "<p> with vg-case or vg-default must be a direct child of an element with vg-switch",
+		},
+		{
+			name:          "text in switch",
+			in:            `<div vg-switch="c.N"><p vg-case="1">a</p>text</div>`,
+			expectedError: `<div> with vg-switch may only contain elements with vg-case or vg-default, found "text"`,
+		},
+		{
+			name:          "element without case in switch",
+			in:            `<div vg-switch="c.N"><p>a</p></div>`,
+			expectedError: "<p> inside vg-switch must have vg-case or vg-default",
+		},
+		{
+			name:          "two defaults",
+			in:            `<div vg-switch="c.N"><p vg-default>a</p><p vg-default>b</p></div>`,
+			expectedError: "<div> with vg-switch has more than one vg-default",
+		},
+		{
+			name:          "switch on component",
+			in:            `<div><pkg:Comp vg-switch="c.N"></pkg:Comp></div>`,
+			expectedError: "vg-switch is not supported on component element <pkg:Comp>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ParserGo{PackageName: "main", StructType: "Root", OutDir: t.TempDir(), OutFile: "root_gen.go"}
+			err := p.Parse(strings.NewReader(tt.in), "root.vugu")
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestCondWhitespace(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	p := &ParserGo{PackageName: "main", StructType: "Root", OutDir: dir, OutFile: "root_gen.go", NoOptimizeStatic: true}
+	err := p.Parse(strings.NewReader(`<div>
+	<p vg-if="c.A">a</p>
+	<!-- comment -->
+	<p vg-else>b</p>
+	<ul vg-switch="c.N">
+		<li vg-case="1">one</li>
+		<li vg-default>other</li>
+	</ul>
+</div>`), "root.vugu")
+	assert.NoError(err)
+
+	b, err := os.ReadFile(dir + "/root_gen.go")
+	assert.NoError(err)
+	out := string(b)
+	assert.Contains(out, "} else {")
+	assert.NotContains(out, "comment")
+	assert.Regexp(`switch c\.N \{\n\s+case 1:`, out)
+}
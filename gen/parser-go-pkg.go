@@ -172,6 +172,19 @@ func (p *ParserGoPkg) Run() error {
 
 	missingFmap := make(map[string]string, len(vuguFileNames))
 
+	// find the component type declarations in the hand-written .go files, so generic components can be detected
+	genFileNames := map[string]bool{"0_missing_gen.go": true, mergeSingleName: true}
+	var compTypeNames []string
+	for _, fn := range vuguFileNames {
+		baseFileName := strings.TrimSuffix(fn, ".vugu")
+		genFileNames[baseFileName+goFnameAppend+".go"] = true
+		compTypeNames = append(compTypeNames, fnameToGoTypeName(baseFileName))
+	}
+	typeDecls, err := findPkgTypeDecls(p.pkgPath, compTypeNames, genFileNames)
+	if err != nil {
+		return err
+	}
+
 	// run ParserGo on each file to generate the .go files
 	for _, fn := range vuguFileNames {
 
@@ -193,6 +206,8 @@ func (p *ParserGoPkg) Run() error {
 		pg.OutDir = p.pkgPath
 		pg.OutFile = goFileName
 		pg.TinyGo = p.opts.TinyGo
+		pg.TypeDecl = typeDecls[compTypeName]
+		pg.PkgTypeDecls = typeDecls
 
 		// add to our list of names to check after
 		namesToCheck = append(namesToCheck, pg.StructType)
@@ -492,6 +507,35 @@ checkMore:
 
 }
 
+// goImportPath returns the import path of the package in dir from the module path in the nearest
+// go.mod in dir or above it, or an empty string if there is none
+func goImportPath(dir string) string {
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for sub := ""; ; {
+		b, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(b), "\n") {
+				f := strings.Fields(line)
+				if len(f) >= 2 && f[0] == "module" {
+					return strings.Trim(f[1], `"`) + sub
+				}
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		sub = "/" + filepath.Base(dir) + sub
+		dir = parent
+	}
+}
+
 // goPkgCheckNames parses a package dir and looks for names, returning a map of what was
 // found.  Names like "A.B" mean a method of name "B" with receiver of type "*A"
 func goPkgCheckNames(pkgPath string, names []string) (map[string]any, error) {
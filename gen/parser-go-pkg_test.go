@@ -156,6 +156,513 @@ func TestRun(t *testing.T) {
 			},
 			build: "default",
 		},
+		{
+			name:      "event-modifiers",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div><a href="#" @click.prevent.stop="c.N++">x</a><input @keydown.enter.ctrl="c.N++" @keydown.esc="c.N--"><div @scroll.passive.capture.once.self="c.N++"></div></div>`,
+				"root.go":   "package main\ntype Root struct { N int }\n",
+				"go.mod":    "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go":   "package main\nfunc main(){}",
+			},
+			out: map[string][]string{
+				"root_gen.go": {
+					`EventType:\s+"click",\s+Func:[^\n]+\n\s+PreventDefault:\s+true,\s+StopPropagation:\s+true,`,
+					`EventType:\s+"keydown",\s+Func:[^\n]+\n\s+Keys:\s+\[\]string\{"enter", "ctrl"\},`,
+					`EventType:\s+"keydown",\s+Func:[^\n]+\n\s+Keys:\s+\[\]string\{"esc"\},`,
+					`EventType:\s+"scroll",\s+Func:[^\n]+\n\s+Capture:\s+true,\s+Passive:\s+true,\s+Once:\s+true,\s+Self:\s+true,`,
+				},
+			},
+			build: "default",
+		},
+		{
+			name:      "vg-model",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div>
+<input id="name" vg-model.trim="c.Name">
+<input id="age" type="number" vg-model.lazy="c.Age">
+<input id="ok" type="checkbox" vg-model="c.OK">
+<input id="r1" type="radio" value="a" vg-model="c.Choice"><input id="r2" type="radio" value="b" vg-model="c.Choice">
+<input id="when" type="date" vg-model="c.When">
+<select id="sel" vg-model="c.Size"><option value="1">1</option><option value="2">2</option></select>
+<textarea id="notes" vg-model="c.Notes"></textarea>
+</div>`,
+				"root.go": "package main\nimport \"time\"\ntype Root struct { Name string; Age int; OK bool; Choice string; When time.Time; Size float64; Notes string; Errs []error }\nfunc (c *Root) ModelError(err error) { c.Errs = append(c.Errs, err) }\n",
+				"go.mod":  "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"fmt"
+
+	"github.com/vugu/vugu"
+)
+
+func fire(root *vugu.VGNode, id, eventType string, target map[string]any) {
+	root.Walk(func(n *vugu.VGNode) error {
+		for _, a := range n.Attr {
+			if a.Key == "id" && a.Val == id {
+				for _, h := range n.DOMEventHandlerSpecList {
+					if h.EventType == eventType {
+						h.Func(vugu.NewDOMEvent(nil, map[string]any{"target": target}))
+						return nil
+					}
+				}
+				panic(fmt.Errorf("no %s handler for %s", eventType, id))
+			}
+		}
+		return nil
+	})
+}
+
+func main() {
+	root := &Root{Choice: "b"}
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	out := buildEnv.RunBuild(root).Out.Out[0]
+
+	fire(out, "name", "input", map[string]any{"value": "  Joe "})
+	fire(out, "age", "change", map[string]any{"value": "42"})
+	fire(out, "ok", "change", map[string]any{"checked": true})
+	fire(out, "r1", "change", map[string]any{"checked": true, "value": "a"})
+	fire(out, "when", "input", map[string]any{"value": "2020-01-02"})
+	fire(out, "sel", "change", map[string]any{"value": "2"})
+	fire(out, "notes", "input", map[string]any{"value": "hi"})
+
+	if root.Name != "Joe" || root.Age != 42 || !root.OK || root.Choice != "a" ||
+		root.When.Format("2006-01-02") != "2020-01-02" || root.Size != 2 || root.Notes != "hi" {
+		panic(fmt.Errorf("unexpected values: %#v", root))
+	}
+
+	// a value which can't be assigned leaves the field as is and is passed to ModelError
+	fire(out, "age", "change", map[string]any{"value": "abc"})
+	if root.Age != 42 || len(root.Errs) != 1 {
+		panic(fmt.Errorf("unexpected result for a bad value: %d %v", root.Age, root.Errs))
+	}
+
+	// values are rendered back out as properties
+	out = buildEnv.RunBuild(root).Out.Out[0]
+	var props []string
+	out.Walk(func(n *vugu.VGNode) error {
+		for _, p := range n.Prop {
+			props = append(props, p.Key+"="+string(p.JSONVal))
+		}
+		return nil
+	})
+	if fmt.Sprint(props) != ` + "`" + `[value="Joe" value="42" checked=true checked=true checked=false value="2020-01-02" value="2" value="hi"]` + "`" + ` {
+		panic(fmt.Errorf("unexpected props: %v", props))
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"root_gen.go": {`vugu\.ModelSet\(&\(c\.Name\), "", event\.PropString\("target", "value"\), vugu\.ModelTrim\)`, `EventType:\s+"change"`},
+			},
+			build: "default",
+		},
+		{
+			name:      "vg-key",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<ul><li vg-for="_, item := range c.Items" vg-key="item.ID" vg-content="item.Name"></li></ul>`,
+				"root.go":   "package main\ntype Item struct { ID int; Name string }\ntype Root struct { Items []Item }\n",
+				"go.mod":    "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"fmt"
+
+	"github.com/vugu/vugu"
+)
+
+func main() {
+	root := &Root{Items: []Item{{ID: 7, Name: "a"}, {ID: 3, Name: "b"}}}
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	out := buildEnv.RunBuild(root).Out.Out[0]
+	var keys []string
+	for n := out.FirstChild; n != nil; n = n.NextSibling {
+		keys = append(keys, n.Key)
+	}
+	if fmt.Sprint(keys) != "[7 3]" {
+		panic(fmt.Errorf("unexpected keys: %v", keys))
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"root_gen.go": {`vgn\.SetKey\(item\.ID\)`},
+			},
+			build: "default",
+		},
+		{
+			name:      "vg-else-if",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div>
+	<p vg-if="c.N == 0">zero</p>
+	<!-- comments and whitespace are fine here -->
+	<main:Child vg-else-if="c.N == 1"></main:Child>
+	<vg-template vg-else-if="c.N == 2">two</vg-template>
+	<span vg-else vg-for="i := 0; i < c.N; i++" vg-content="i"></span>
+	<ul vg-switch="c.N % 3">
+		<li vg-case="0">fizz</li>
+		<li vg-case="1, 2" vg-if="c.N > 1">other</li>
+		<li vg-default>never</li>
+	</ul>
+	<vg-template vg-switch=""><b vg-case="c.N > 3">big</b><i vg-default>small</i></vg-template>
+</div>`,
+				"root.go":  "package main\ntype Root struct { N int }\n",
+				"child.go": "package main\nimport \"github.com/vugu/vugu\"\ntype Child struct {}\nfunc (c *Child) Build(vgin *vugu.BuildIn) *vugu.BuildOut { return &vugu.BuildOut{Out: []*vugu.VGNode{{Type: vugu.ElementNode, Data: \"em\"}}} }\n",
+				"go.mod":   "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vugu/vugu"
+)
+
+// render outputs the element names and text, flattening templates and components
+func render(buildEnv *vugu.BuildEnv, res *vugu.BuildResults, n *vugu.VGNode, sb *strings.Builder) {
+	switch {
+	case n.Component != nil:
+		for _, o := range res.ResultFor(n.Component).Out {
+			render(buildEnv, res, o, sb)
+		}
+		return
+	case n.Type == vugu.TextNode:
+		sb.WriteString(strings.TrimSpace(n.Data))
+		return
+	case n.Data != "":
+		sb.WriteString("<" + n.Data + ">")
+	}
+	if n.InnerHTML != nil {
+		sb.WriteString(*n.InnerHTML)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(buildEnv, res, c, sb)
+	}
+}
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	expected := []string{
+		"<div><p>zero<ul><li>fizz<i>small",
+		"<div><em><ul><i>small",
+		"<div>two<ul><li>other<i>small",
+		"<div><span>0<span>1<span>2<ul><li>fizz<i>small",
+		"<div><span>0<span>1<span>2<span>3<ul><li>other<b>big",
+	}
+	for i, exp := range expected {
+		res := buildEnv.RunBuild(&Root{N: i})
+		var sb strings.Builder
+		render(buildEnv, res, res.Out.Out[0], &sb)
+		if sb.String() != exp {
+			panic(fmt.Errorf("N=%d: expected %q, got %q", i, exp, sb.String()))
+		}
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"root_gen.go": {
+					`\} else if c\.N == 1 \{`,
+					`\} else \{\n\s+for i := 0`,
+					`switch c\.N % 3 \{`,
+					`case 1, 2:\n\s+if c\.N > 1 \{`,
+					`switch \{\n\s+case c\.N > 3:`,
+				},
+			},
+			build: "default",
+		},
+		{
+			name:      "vg-portal",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div>
+	<vg-portal target="#modals" vg-if="c.Open"><p>modal</p></vg-portal>
+	<vg-portal :target="c.Target"><span>tip</span></vg-portal>
+</div>`,
+				"root.go": "package main\ntype Root struct { Open bool; Target string }\n",
+				"go.mod":  "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"fmt"
+
+	"github.com/vugu/vugu"
+)
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	res := buildEnv.RunBuild(&Root{Open: true, Target: "#tips"})
+	var portals []string
+	for n := res.Out.Out[0].FirstChild; n != nil; n = n.NextSibling {
+		if n.IsPortal() {
+			if n.IsTemplate() {
+				panic("portal should not be a template")
+			}
+			portals = append(portals, n.Portal+" "+n.FirstChild.Data)
+		}
+	}
+	if fmt.Sprint(portals) != "[#modals p #tips span]" {
+		panic(fmt.Errorf("unexpected portals %q", portals))
+	}
+
+	// an empty :target is still a portal, not a template, which the renderer reports
+	res = buildEnv.RunBuild(&Root{})
+	blank := 0
+	for n := res.Out.Out[0].FirstChild; n != nil; n = n.NextSibling {
+		if n.IsBlankPortal() {
+			blank++
+		}
+	}
+	if blank != 1 {
+		panic(fmt.Errorf("expected one blank portal, got %d", blank))
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"root_gen.go": {
+					`vgn\.Portal = "#modals"`,
+					`vgn\.Portal = vugu\.PortalTarget\(c\.Target\)`,
+				},
+			},
+			build: "default",
+		},
+		{
+			name:      "vg-ref",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div><input vg-ref="c.Input"><p vg-for="i := range c.Items" vg-ref="c.Items[i].El">x</p></div>`,
+				"root.go":   "package main\nimport \"github.com/vugu/vugu/js\"\ntype Item struct { El js.Element }\ntype Root struct { Input js.Element; Items []Item }\n",
+				"go.mod":    "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"github.com/vugu/vugu"
+)
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	root := &Root{Items: make([]Item, 2)}
+	res := buildEnv.RunBuild(root)
+	div := res.Out.Out[0]
+	if div.FirstChild.Ref != &root.Input {
+		panic("wrong Ref for input")
+	}
+	if div.LastChild.Ref != &root.Items[1].El {
+		panic("wrong Ref for last p")
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"root_gen.go": {
+					`vgn\.Ref = &\(c\.Input\)`,
+					`vgn\.Ref = &\(c\.Items\[i\]\.El\)`,
+				},
+			},
+			build: "default",
+		},
+		{
+			name:      "vg-slot-let",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu":  `<div><main:Table :Rows="c.Names"><vg-slot name="Row" vg-let="row"><b vg-content="row + c.Suffix"></b></vg-slot></main:Table></div>`,
+				"root.go":    "package main\ntype Root struct { Names []string; Suffix string }\n",
+				"table.vugu": `<ul><li vg-for="_, r := range c.Rows"><vg-comp expr="c.Row(r)"></vg-comp></li></ul>`,
+				"table.go":   "package main\nimport \"github.com/vugu/vugu\"\ntype Table struct { Rows []string; Row func(row string) vugu.Builder }\n",
+				"go.mod":     "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/staticrender"
+)
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	var buf bytes.Buffer
+	err = staticrender.New(&buf).Render(buildEnv.RunBuild(&Root{Names: []string{"a", "b"}, Suffix: "!"}))
+	if err != nil {
+		panic(err)
+	}
+	exp := "<div><ul><li><b>a!</b></li><li><b>b!</b></li></ul></div>"
+	if buf.String() != exp {
+		panic(fmt.Errorf("expected %q, got %q", exp, buf.String()))
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"root_gen.go": {
+					`vgslotarg := vugu\.SlotArg\(vgcomp\.Row\)`,
+					`row := \*vgslotarg`,
+				},
+			},
+			build: "default",
+		},
+		{
+			name:      "generic-comp",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div><main:List[string] :Items="c.Names" @Select="c.Picked = event.Value()"></main:List[string]><main:List[] :Title="c.Picked" :Items="c.CountedNums()"></main:List[]></div>`,
+				"root.go":   "package main\ntype Root struct { Names []string; Nums []int; Picked string; numsCalls int }\nfunc (c *Root) CountedNums() []int { c.numsCalls++; return c.Nums }\n",
+				"list.vugu": `<ul><li vg-for="_, it := range c.Items" vg-content="fmt.Sprint(it)"></li></ul>`,
+				"list.go":   "package main\n//vugugen:event Select[T any]\ntype List[T any] struct { Title string; Items []T; Select SelectHandler[T] }\n",
+				"go.mod":    "module testcase\ngo 1.22\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/staticrender"
+)
+
+func (e SelectEvent[T]) Value() string { return "picked" }
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	var buf bytes.Buffer
+	root := &Root{Names: []string{"a", "b"}, Nums: []int{1, 2}}
+	err = staticrender.New(&buf).Render(buildEnv.RunBuild(root))
+	if err != nil {
+		panic(err)
+	}
+	exp := "<div><ul><li>a</li><li>b</li></ul><ul><li>1</li><li>2</li></ul></div>"
+	if buf.String() != exp {
+		panic(fmt.Errorf("expected %q, got %q", exp, buf.String()))
+	}
+	// the bound expression used to infer the type arguments is only evaluated once
+	if root.numsCalls != 1 {
+		panic(fmt.Errorf("expected CountedNums to be called once, got %d", root.numsCalls))
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"list_gen.go": {
+					`func \(c \*List\[T\]\) Build\(`,
+					`func VGInferListItems\[T any\]\(cached any, v \[\]T\) \(c \*List\[T\], isNew bool\)`,
+				},
+				"root_gen.go": {
+					`CachedComponent\(vgcompKey\)\.\(\*List\[string\]\)`,
+					`vgcomp\.Select = SelectFunc\[string\]\(func\(event SelectEvent\[string\]\)`,
+					`vginfer := \(c\.CountedNums\(\)\)`,
+					`vgcomp, vgcompNew := VGInferListItems\(vgin\.BuildEnv\.CachedComponent\(vgcompKey\), vginfer\)`,
+					`vgcomp\.Items = vginfer`,
+				},
+				"0_missing_gen.go": {
+					`type SelectFunc\[T any\] func\(event SelectEvent\[T\]\)`,
+				},
+			},
+			build: "default",
+		},
+		{
+			name:      "style-scoped",
+			opts:      ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div class="box"><p>Hi</p><span vg-content="c.Name"></span></div>
+<style scoped>
+.box p, .box > span::before { color: red; }
+@media (min-width: 600px) { .box { padding: 1em; } }
+.box :deep(a) { color: blue; }
+</style>`,
+				"root.go": "package main\ntype Root struct { Name string }\n",
+				"go.mod":  "module testcase\nreplace github.com/vugu/vugu => " + pwd + "\n",
+				"main.go": `package main
+
+import (
+	"strings"
+
+	"github.com/vugu/vugu"
+)
+
+func hasAttr(n *vugu.VGNode, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil {
+		panic(err)
+	}
+	out := buildEnv.RunBuild(&Root{Name: "a"}).Out
+	var scopeAttr string
+	for _, a := range out.Out[0].Attr {
+		if strings.HasPrefix(a.Key, "data-vg-") {
+			scopeAttr = a.Key
+		}
+	}
+	if scopeAttr == "" {
+		panic("missing scope attribute on root")
+	}
+	for n := out.Out[0].FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == vugu.ElementNode && !hasAttr(n, scopeAttr) {
+			panic("missing scope attribute on " + n.Data)
+		}
+	}
+	css := out.CSS[0]
+	if len(css.Attr) != 0 {
+		panic("scoped attribute should not be output")
+	}
+	if !strings.Contains(css.FirstChild.Data, ".box p["+scopeAttr+"], .box > span["+scopeAttr+"]::before") {
+		panic("unexpected CSS: " + css.FirstChild.Data)
+	}
+}
+`,
+			},
+			out: map[string][]string{
+				"root_gen.go": {
+					`Key: "data-vg-[0-9a-f]{8}"`,
+					`\.box\[data-vg-[0-9a-f]{8}\] \{ padding`,
+					`\.box\[data-vg-[0-9a-f]{8}\] a \{`,
+				},
+			},
+			build: "default",
+		},
 	}
 
 	for _, tc := range tcList {
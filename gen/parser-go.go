@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"go/token"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -33,6 +35,15 @@ type ParserGo struct {
 
 	NoOptimizeStatic bool // set to true to disable optimization of static blocks of HTML into vg-html expressions
 	TinyGo           bool // set to true to enable TinyGo compatability changes to the generated code
+
+	// TypeDecl is the Go source of the declaration of StructType, e.g. "type List[T any] struct { ... }".
+	// It is only used to find the type parameters of generic components; if empty the Go code in the
+	// .vugu file is searched for it.
+	TypeDecl string
+
+	// PkgTypeDecls has the Go source of the declarations of the other component types in the package, by type name.
+	// It is used to choose the bound field that the type arguments of a generic component are inferred from.
+	PkgTypeDecls map[string]string
 }
 
 func gofmt(pgm string) (string, error) {
@@ -136,6 +147,44 @@ func (p *ParserGo) Parse(r io.Reader, fname string) error {
 
 	}
 
+	typeDecl := p.TypeDecl
+	if typeDecl == "" {
+		for _, n := range state.docNodeList {
+			typeDecl = findTypeDeclSource(goScriptSource(n), p.StructType, true)
+			if typeDecl != "" {
+				break
+			}
+		}
+	}
+	if typeDecl != "" {
+		state.generic, err = parseGenericInfo(typeDecl)
+		if err != nil {
+			return fmt.Errorf("type %s: %w", p.StructType, err)
+		}
+	}
+
+	// check vg-else-if, vg-else and vg-switch and remove whitespace between their elements
+	for _, n := range state.docNodeList {
+		err = checkCondTree(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	// if there are scoped styles, add the scope attribute to our elements; this must happen
+	// before the compaction below so the attribute also ends up in the static HTML
+	for _, n := range state.docNodeList {
+		if hasScopedStyle(n) {
+			state.scopeAttr = scopeAttrName(p.scopePkgID(fname), p.StructType)
+			break
+		}
+	}
+	if state.scopeAttr != "" {
+		for _, n := range state.docNodeList {
+			scopeNodes(n, state.scopeAttr)
+		}
+	}
+
 	// run n through the optimizer and convert large chunks of static elements into
 	// vg-html attributes, this should provide a significiant performance boost for static HTML
 	if !p.NoOptimizeStatic {
@@ -241,7 +290,9 @@ type parseGoState struct {
 	goBufBottom bytes.Buffer // additional Go code that is put as the very last thing
 	// cssChunkList []codeChunk
 	// jsChunkList  []codeChunk
-	outIsSet bool // set to true when vgout.Out has been set for to the level node
+	outIsSet  bool         // set to true when vgout.Out has been set for to the level node
+	scopeAttr string       // attribute used for <style scoped>, empty if the component has none
+	generic   *genericInfo // type parameters of the component, nil if it is not generic
 }
 
 func (p *ParserGo) visitOverall(state *parseGoState) error {
@@ -260,7 +311,7 @@ func (p *ParserGo) visitOverall(state *parseGoState) error {
 	fmt.Fprintf(&state.goBuf, "\n")
 
 	// TODO: we use a prefix like "vg" as our namespace; should document that user code should not use that prefix to avoid conflicts
-	fmt.Fprintf(&state.buildBuf, "func (c *%s) Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {\n", p.StructType)
+	fmt.Fprintf(&state.buildBuf, "func (c *%s%s) Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {\n", p.StructType, state.generic.receiverTypeArgs())
 	fmt.Fprintf(&state.buildBuf, "    \n")
 	fmt.Fprintf(&state.buildBuf, "    vgout = &vugu.BuildOut{}\n")
 	fmt.Fprintf(&state.buildBuf, "    \n")
@@ -279,6 +330,10 @@ func (p *ParserGo) visitOverall(state *parseGoState) error {
 	fmt.Fprintf(&state.goBufBottom, "var _ log.Logger\n")
 	fmt.Fprintf(&state.goBufBottom, "\n")
 
+	if state.generic != nil {
+		p.writeInferFuncs(state)
+	}
+
 	// remove document node if present
 	if len(state.docNodeList) == 1 && state.docNodeList[0].Type == html.DocumentNode {
 		state.docNodeList = []*html.Node{state.docNodeList[0].FirstChild}
@@ -586,6 +641,13 @@ func (p *ParserGo) visitJS(state *parseGoState, n *html.Node) error {
 
 	// allow control stuff, why not
 
+	// vg-else-if, vg-else, vg-case and vg-default go outside of vg-for
+	branchEnd, err := emitCondBranch(state, n)
+	if err != nil {
+		return err
+	}
+	defer fmt.Fprint(&state.buildBuf, branchEnd)
+
 	// vg-for
 	if v, _ := vgForExpr(n); v.expr != "" {
 		if err := p.emitForExpr(state, n); err != nil {
@@ -598,7 +660,7 @@ func (p *ParserGo) visitJS(state *parseGoState, n *html.Node) error {
 	ife := vgIfExpr(n)
 	if ife != "" {
 		fmt.Fprintf(&state.buildBuf, "if %s {\n", ife)
-		defer fmt.Fprintf(&state.buildBuf, "}\n")
+		defer fmt.Fprint(&state.buildBuf, condChainEnd(n))
 	}
 
 	// but then for the actual output, we append to vgout.JS, instead of parentNode
@@ -664,6 +726,13 @@ func (p *ParserGo) visitCSS(state *parseGoState, n *html.Node) error {
 
 	// allow control stuff, why not
 
+	// vg-else-if, vg-else, vg-case and vg-default go outside of vg-for
+	branchEnd, err := emitCondBranch(state, n)
+	if err != nil {
+		return err
+	}
+	defer fmt.Fprint(&state.buildBuf, branchEnd)
+
 	// vg-for
 	if v, _ := vgForExpr(n); v.expr != "" {
 		if err := p.emitForExpr(state, n); err != nil {
@@ -676,18 +745,38 @@ func (p *ParserGo) visitCSS(state *parseGoState, n *html.Node) error {
 	ife := vgIfExpr(n)
 	if ife != "" {
 		fmt.Fprintf(&state.buildBuf, "if %s {\n", ife)
-		defer fmt.Fprintf(&state.buildBuf, "}\n")
+		defer fmt.Fprint(&state.buildBuf, condChainEnd(n))
+	}
+
+	// scoped styles have their selectors rewritten and the scoped attribute is not output
+	scoped := isScopedStyle(n)
+	attrs := n.Attr
+	if scoped {
+		attrs = nil
+		for _, a := range n.Attr {
+			if a.Key != "scoped" {
+				attrs = append(attrs, a)
+			}
+		}
 	}
 
 	// but then for the actual output, we append to vgout.CSS, instead of parentNode
-	fmt.Fprintf(&state.buildBuf, "vgn = &vugu.VGNode{Type:vugu.VGNodeType(%d),Data:%q,Attr:%#v}\n", n.Type, n.Data, staticVGAttr(n.Attr))
+	fmt.Fprintf(&state.buildBuf, "vgn = &vugu.VGNode{Type:vugu.VGNodeType(%d),Data:%q,Attr:%#v}\n", n.Type, n.Data, staticVGAttr(attrs))
 
 	// output any text children
 	if n.FirstChild != nil {
 		fmt.Fprintf(&state.buildBuf, "{\n")
 		for childN := n.FirstChild; childN != nil; childN = childN.NextSibling {
 			// NOTE: we already verified above that these are just text nodes
-			fmt.Fprintf(&state.buildBuf, "vgn.AppendChild(&vugu.VGNode{Type:vugu.VGNodeType(%d),Data:%q,Attr:%#v})\n", childN.Type, childN.Data, staticVGAttr(childN.Attr))
+			data := childN.Data
+			if scoped {
+				var err error
+				data, err = scopeCSS(data, state.scopeAttr)
+				if err != nil {
+					return fmt.Errorf("error in scoped style: %w", err)
+				}
+			}
+			fmt.Fprintf(&state.buildBuf, "vgn.AppendChild(&vugu.VGNode{Type:vugu.VGNodeType(%d),Data:%q,Attr:%#v})\n", childN.Type, data, staticVGAttr(childN.Attr))
 		}
 		fmt.Fprintf(&state.buildBuf, "}\n")
 	}
@@ -728,6 +817,13 @@ func (p *ParserGo) visitTopNode(state *parseGoState, n *html.Node) error {
 
 // visitNodeElementAndCtrl handles an element that supports vg-if, vg-for etc
 func (p *ParserGo) visitNodeElementAndCtrl(state *parseGoState, n *html.Node) error {
+	// vg-else-if, vg-else, vg-case and vg-default go outside of vg-for
+	branchEnd, err := emitCondBranch(state, n)
+	if err != nil {
+		return err
+	}
+	defer fmt.Fprint(&state.buildBuf, branchEnd)
+
 	// vg-for
 	if v, _ := vgForExpr(n); v.expr != "" {
 		if err := p.emitForExpr(state, n); err != nil {
@@ -740,10 +836,10 @@ func (p *ParserGo) visitNodeElementAndCtrl(state *parseGoState, n *html.Node) er
 	ife := vgIfExpr(n)
 	if ife != "" {
 		fmt.Fprintf(&state.buildBuf, "if %s {\n", ife)
-		defer fmt.Fprintf(&state.buildBuf, "}\n")
+		defer fmt.Fprint(&state.buildBuf, condChainEnd(n))
 	}
 
-	err := p.visitNodeJustElement(state, n)
+	err = p.visitNodeJustElement(state, n)
 	if err != nil {
 		return err
 	}
@@ -768,12 +864,22 @@ func (p *ParserGo) visitNodeJustElement(state *parseGoState, n *html.Node) error
 	// 	state.outIsSet = true
 	// }
 
+	// vg-key, lets the renderer move the DOM node with the data
+	if keyExpr := vgKeyExpr(n); keyExpr != "" {
+		fmt.Fprintf(&state.buildBuf, "vgn.SetKey(%s)\n", keyExpr)
+	}
+
 	// dynamic attrs
 	writeDynamicAttributes(state, n)
 
 	// vg-js-*
 	writeJSCallbackAttributes(state, n)
 
+	// vg-ref, the renderer assigns the element to the js.Element this points to
+	if refExpr := vgRefExpr(n); refExpr != "" {
+		fmt.Fprintf(&state.buildBuf, "vgn.Ref = &(%s)\n", refExpr)
+	}
+
 	// js properties
 	propExprMap, propExprMapKeys := propVGAttrExpr(n)
 	for _, k := range propExprMapKeys {
@@ -787,14 +893,44 @@ func (p *ParserGo) visitNodeJustElement(state *parseGoState, n *html.Node) error
 		fmt.Fprintf(&state.buildBuf, "vgn.SetInnerHTML(%s)\n", htmlExpr)
 	}
 
+	// vg-model
+	err := p.emitModel(state, n)
+	if err != nil {
+		return err
+	}
+
 	// DOM events
 	eventMap, eventKeys := vgDOMEventExprs(n)
 	for _, k := range eventKeys {
 		expr := eventMap[k]
+		eventType, mods, err := parseDOMEventKey(k)
+		if err != nil {
+			return err
+		}
 		fmt.Fprintf(&state.buildBuf, "vgn.DOMEventHandlerSpecList = append(vgn.DOMEventHandlerSpecList, vugu.DOMEventHandlerSpec{\n")
-		fmt.Fprintf(&state.buildBuf, "EventType: %q,\n", k)
+		fmt.Fprintf(&state.buildBuf, "EventType: %q,\n", eventType)
 		fmt.Fprintf(&state.buildBuf, "Func: func(event vugu.DOMEvent) { %s },\n", expr)
-		fmt.Fprintf(&state.buildBuf, "// TODO: implement capture, etc. mostly need to decide syntax\n")
+		if mods.capture {
+			fmt.Fprintf(&state.buildBuf, "Capture: true,\n")
+		}
+		if mods.passive {
+			fmt.Fprintf(&state.buildBuf, "Passive: true,\n")
+		}
+		if mods.prevent {
+			fmt.Fprintf(&state.buildBuf, "PreventDefault: true,\n")
+		}
+		if mods.stop {
+			fmt.Fprintf(&state.buildBuf, "StopPropagation: true,\n")
+		}
+		if mods.once {
+			fmt.Fprintf(&state.buildBuf, "Once: true,\n")
+		}
+		if mods.self {
+			fmt.Fprintf(&state.buildBuf, "Self: true,\n")
+		}
+		if len(mods.keys) > 0 {
+			fmt.Fprintf(&state.buildBuf, "Keys: %#v,\n", mods.keys)
+		}
 		fmt.Fprintf(&state.buildBuf, "})\n")
 	}
 
@@ -803,6 +939,12 @@ func (p *ParserGo) visitNodeJustElement(state *parseGoState, n *html.Node) error
 		fmt.Fprintf(&state.buildBuf, "{\n")
 		fmt.Fprintf(&state.buildBuf, "vgparent := vgn; _ = vgparent\n") // vgparent set for this block to vgn
 
+		// vg-switch, the children are the cases
+		sw, isSwitch := vgSwitchExpr(n)
+		if isSwitch {
+			fmt.Fprintf(&state.buildBuf, "switch %s {\n", sw)
+		}
+
 		// iterate over children
 		for childN := n.FirstChild; childN != nil; childN = childN.NextSibling {
 
@@ -812,6 +954,10 @@ func (p *ParserGo) visitNodeJustElement(state *parseGoState, n *html.Node) error
 			}
 		}
 
+		if isSwitch {
+			fmt.Fprintf(&state.buildBuf, "}\n")
+		}
+
 		fmt.Fprintf(&state.buildBuf, "}\n")
 
 	}
@@ -836,6 +982,8 @@ func (p *ParserGo) visitDefaultByType(state *parseGoState, n *html.Node) error {
 			err = p.visitVGCompTag(state, n)
 		} else if n.Data == "vg-template" {
 			err = p.visitVGTemplateTag(state, n)
+		} else if n.Data == "vg-portal" {
+			err = p.visitVGPortalTag(state, n)
 		} else {
 			err = p.visitNodeElementAndCtrl(state, n)
 		}
@@ -868,11 +1016,18 @@ func (p *ParserGo) visitNodeComment(state *parseGoState, n *html.Node) error {
 func (p *ParserGo) visitVGCompTag(state *parseGoState, n *html.Node) error {
 	// vg-for not allowed here
 
+	// vg-else-if, vg-else, vg-case and vg-default are supported
+	branchEnd, err := emitCondBranch(state, n)
+	if err != nil {
+		return err
+	}
+	defer fmt.Fprint(&state.buildBuf, branchEnd)
+
 	// vg-if is supported
 	ife := vgIfExpr(n)
 	if ife != "" {
 		fmt.Fprintf(&state.buildBuf, "if %s {\n", ife)
-		defer fmt.Fprintf(&state.buildBuf, "}\n")
+		defer fmt.Fprint(&state.buildBuf, condChainEnd(n))
 	}
 
 	// for now, nothing else supported
@@ -898,6 +1053,13 @@ func (p *ParserGo) visitVGCompTag(state *parseGoState, n *html.Node) error {
 
 // visitVGTemplateTag handles vg-template
 func (p *ParserGo) visitVGTemplateTag(state *parseGoState, n *html.Node) error {
+	// vg-else-if, vg-else, vg-case and vg-default go outside of vg-for
+	branchEnd, err := emitCondBranch(state, n)
+	if err != nil {
+		return err
+	}
+	defer fmt.Fprint(&state.buildBuf, branchEnd)
+
 	// vg-for
 	if v, _ := vgForExpr(n); v.expr != "" {
 		if err := p.emitForExpr(state, n); err != nil {
@@ -910,7 +1072,7 @@ func (p *ParserGo) visitVGTemplateTag(state *parseGoState, n *html.Node) error {
 	ife := vgIfExpr(n)
 	if ife != "" {
 		fmt.Fprintf(&state.buildBuf, "if %s {\n", ife)
-		defer fmt.Fprintf(&state.buildBuf, "}\n")
+		defer fmt.Fprint(&state.buildBuf, condChainEnd(n))
 	}
 
 	// output a node with type Element but empty data
@@ -923,6 +1085,12 @@ func (p *ParserGo) visitVGTemplateTag(state *parseGoState, n *html.Node) error {
 		fmt.Fprintf(&state.buildBuf, "{\n")
 		fmt.Fprintf(&state.buildBuf, "vgparent := vgn; _ = vgparent\n") // vgparent set for this block to vgn
 
+		// vg-switch, the children are the cases
+		sw, isSwitch := vgSwitchExpr(n)
+		if isSwitch {
+			fmt.Fprintf(&state.buildBuf, "switch %s {\n", sw)
+		}
+
 		// iterate over children
 		for childN := n.FirstChild; childN != nil; childN = childN.NextSibling {
 
@@ -932,6 +1100,10 @@ func (p *ParserGo) visitVGTemplateTag(state *parseGoState, n *html.Node) error {
 			}
 		}
 
+		if isSwitch {
+			fmt.Fprintf(&state.buildBuf, "}\n")
+		}
+
 		fmt.Fprintf(&state.buildBuf, "}\n")
 
 	}
@@ -939,10 +1111,76 @@ func (p *ParserGo) visitVGTemplateTag(state *parseGoState, n *html.Node) error {
 	return nil
 }
 
+// visitVGPortalTag handles vg-portal, the children are rendered inside the element matching
+// the target selector instead of in place (see vugu.VGNode.IsPortal)
+func (p *ParserGo) visitVGPortalTag(state *parseGoState, n *html.Node) error {
+	// vg-else-if, vg-else, vg-case and vg-default go outside of vg-for
+	branchEnd, err := emitCondBranch(state, n)
+	if err != nil {
+		return err
+	}
+	defer fmt.Fprint(&state.buildBuf, branchEnd)
+
+	target := vgPortalTargetExpr(n)
+	if target == "" || target == `""` {
+		return fmt.Errorf("vg-portal must have a `target` attribute with a CSS selector or a `:target` attribute with a Go expression")
+	}
+
+	// vg-for
+	if v, _ := vgForExpr(n); v.expr != "" {
+		if err := p.emitForExpr(state, n); err != nil {
+			return err
+		}
+		defer fmt.Fprintf(&state.buildBuf, "}\n")
+	}
+
+	// vg-if
+	ife := vgIfExpr(n)
+	if ife != "" {
+		fmt.Fprintf(&state.buildBuf, "if %s {\n", ife)
+		defer fmt.Fprint(&state.buildBuf, condChainEnd(n))
+	}
+
+	// output a node with type Element, empty data and the target
+	fmt.Fprintf(&state.buildBuf, "vgn = &vugu.VGNode{Type:vugu.VGNodeType(%d)} // <vg-portal>\n", vugu.ElementNode)
+	if attrWithKey(n, ":target") != nil {
+		// an empty Portal would make it a template and output the children in place, the renderer reports it instead
+		fmt.Fprintf(&state.buildBuf, "vgn.Portal = vugu.PortalTarget(%s)\n", target)
+	} else {
+		fmt.Fprintf(&state.buildBuf, "vgn.Portal = %s\n", target)
+	}
+	fmt.Fprintf(&state.buildBuf, "vgparent.AppendChild(vgn)\n")
+
+	// and then the children, same as vg-template
+	if n.FirstChild != nil {
+
+		fmt.Fprintf(&state.buildBuf, "{\n")
+		fmt.Fprintf(&state.buildBuf, "vgparent := vgn; _ = vgparent\n") // vgparent set for this block to vgn
+
+		for childN := n.FirstChild; childN != nil; childN = childN.NextSibling {
+			err := p.visitDefaultByType(state, childN)
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(&state.buildBuf, "}\n")
+	}
+
+	return nil
+}
+
 // visitNodeComponentElement handles an element that is a call to a component
 func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node) error {
 	// components are just different so we handle all of our own vg-for vg-if and everything else
 
+	// vg-else-if, vg-else, vg-case and vg-default go outside of vg-for
+	branchEnd, err := emitCondBranch(state, n)
+	if err != nil {
+		return err
+	}
+	defer fmt.Fprint(&state.buildBuf, branchEnd)
+
 	// vg-for
 	if v, _ := vgForExpr(n); v.expr != "" {
 		if err := p.emitForExpr(state, n); err != nil {
@@ -955,10 +1193,18 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 	ife := vgIfExpr(n)
 	if ife != "" {
 		fmt.Fprintf(&state.buildBuf, "if %s {\n", ife)
-		defer fmt.Fprintf(&state.buildBuf, "}\n")
+		defer fmt.Fprint(&state.buildBuf, condChainEnd(n))
 	}
 
 	nodeName := n.OrigData // use original case of element
+	if vgRefExpr(n) != "" {
+		return fmt.Errorf("vg-ref is not supported on component element <%s>, only on regular elements", nodeName)
+	}
+	// type arguments for generic components, <ui:List[Customer]> or <ui:List[]> to infer them
+	nodeName, typeArgs, err := splitTypeArgs(nodeName)
+	if err != nil {
+		return err
+	}
 	nodeNameParts := strings.Split(nodeName, ":")
 	if len(nodeNameParts) != 2 {
 		return fmt.Errorf("invalid component tag name %q must contain exactly one colon", nodeName)
@@ -972,6 +1218,32 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 		pkgPrefix = ""
 	}
 
+	// with empty brackets the type arguments are inferred from the first bound field
+	inferField, inferExpr := "", ""
+	if typeArgs == "[]" {
+		inferField, inferExpr = firstBoundProp(n, nil)
+		if inferField == "" {
+			return fmt.Errorf("component tag <%s> has empty type arguments but no bound field (e.g. :Items=\"...\") to infer them from", n.OrigData)
+		}
+		// for a component in this package only the fields with a VGInfer function can be used
+		if pkgPrefix == "" {
+			gi, err := p.pkgGenericInfo(state, nodeNameParts[1])
+			if err != nil {
+				return err
+			}
+			if gi != nil {
+				inferField, inferExpr = firstBoundProp(n, gi.canInferFrom)
+				if inferField == "" {
+					return fmt.Errorf("component tag <%s> has empty type arguments but none of its bound fields has a type which uses all of the type parameters of %s, give the type arguments explicitly", n.OrigData, nodeNameParts[1])
+				}
+			}
+		}
+		if eventMap, _ := vgEventExprs(n); len(eventMap) > 0 {
+			return fmt.Errorf("component tag <%s> with inferred type arguments cannot have component events, give the type arguments explicitly", n.OrigData)
+		}
+		typeArgs = ""
+	}
+
 	compKeyID := compHashCounted(p.StructType + "." + n.OrigData)
 
 	fmt.Fprintf(&state.buildBuf, "{\n")
@@ -984,10 +1256,18 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 		fmt.Fprintf(&state.buildBuf, "vgcompKey := vugu.MakeCompKey(0x%X^vgin.CurrentPositionHash(), vgiterkey)\n", compKeyID)
 	}
 	fmt.Fprintf(&state.buildBuf, "// ask BuildEnv for prior instance of this specific component\n")
-	fmt.Fprintf(&state.buildBuf, "vgcomp, _ := vgin.BuildEnv.CachedComponent(vgcompKey).(*%s)\n", typeExpr)
-	fmt.Fprintf(&state.buildBuf, "if vgcomp == nil {\n")
-	fmt.Fprintf(&state.buildBuf, "// create new one if needed\n")
-	fmt.Fprintf(&state.buildBuf, "vgcomp = new(%s)\n", typeExpr)
+	if inferField != "" {
+		// the VGInfer func only returns the cached instance if it has the same type arguments
+		// the bound expression is evaluated once here and vginfer is assigned to the field below
+		fmt.Fprintf(&state.buildBuf, "vginfer := (%s)\n", inferExpr)
+		fmt.Fprintf(&state.buildBuf, "vgcomp, vgcompNew := %s%s(vgin.BuildEnv.CachedComponent(vgcompKey), vginfer)\n", pkgPrefix, inferFuncName(nodeNameParts[1], inferField))
+		fmt.Fprintf(&state.buildBuf, "if vgcompNew {\n")
+	} else {
+		fmt.Fprintf(&state.buildBuf, "vgcomp, _ := vgin.BuildEnv.CachedComponent(vgcompKey).(*%s%s)\n", typeExpr, typeArgs)
+		fmt.Fprintf(&state.buildBuf, "if vgcomp == nil {\n")
+		fmt.Fprintf(&state.buildBuf, "// create new one if needed\n")
+		fmt.Fprintf(&state.buildBuf, "vgcomp = new(%s%s)\n", typeExpr, typeArgs)
+	}
 	fmt.Fprintf(&state.buildBuf, "vgin.BuildEnv.WireComponent(vgcomp)\n")
 	fmt.Fprintf(&state.buildBuf, "}\n")
 	fmt.Fprintf(&state.buildBuf, "vgin.BuildEnv.UseComponent(vgcompKey, vgcomp) // ensure we can use this in the cache next time around\n")
@@ -1012,6 +1292,9 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 		// }
 
 		valExpr := dynExprMap[k]
+		if k == inferField {
+			valExpr = "vginfer"
+		}
 
 		// if starts with upper case, it's a field name
 		if hasUpperFirst(k) {
@@ -1067,7 +1350,8 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 		expr := eventMap[k]
 		// fmt.Fprintf(&state.buildBuf, "vgcomp.%s = func(event %s%sEvent){%s}\n", k, pkgPrefix, k, expr)
 		// switched to using interfaces
-		fmt.Fprintf(&state.buildBuf, "vgcomp.%s = %s%sFunc(func(event %s%sEvent){%s})\n", k, pkgPrefix, k, pkgPrefix, k, expr)
+		// events of a generic component take the same type arguments as the component
+		fmt.Fprintf(&state.buildBuf, "vgcomp.%s = %s%sFunc%s(func(event %s%sEvent%s){%s})\n", k, pkgPrefix, k, typeArgs, pkgPrefix, k, typeArgs, expr)
 	}
 
 	// NOTE: vugugen:slot might come in really handy, have to work out the types involved - update: as it stands, this won't be needed.
@@ -1119,6 +1403,7 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 		// NOTE:
 		// <vg-slot name="X"> will assign to vgcomp.X
 		// <vg-slot name='X[Y]'> will assume X is of type map[string]Builder and create the map and then assign with X[Y] =
+		// <vg-slot name="X" vg-let="v"> will assume X is of type func(T) Builder and assign a func where v is the T passed to it
 
 		// find any names with map expressions and clear the maps
 		sort.Strings(foundTagSlotNames)
@@ -1162,7 +1447,22 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 				return fmt.Errorf("found vg-slot tag without a 'name' attribute, the name is required")
 			}
 
-			fmt.Fprintf(&state.buildBuf, "vgcomp.%s = vugu.NewBuilderFunc(func(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {\n", slotName)
+			// vg-let makes it a scoped slot, the type of the variable comes from the field (see vugu.ScopedSlot)
+			letName := strings.TrimSpace(vgLetName(childN))
+			if letName != "" {
+				if strings.Contains(slotName, "[") {
+					return fmt.Errorf("vg-let is not supported on vg-slot with map name %q", slotName)
+				}
+				if !token.IsIdentifier(letName) {
+					return fmt.Errorf("vg-let on vg-slot %q must be a Go identifier, found %q", slotName, letName)
+				}
+				fmt.Fprintf(&state.buildBuf, "{\n")
+				fmt.Fprintf(&state.buildBuf, "vgslotarg := vugu.SlotArg(vgcomp.%s)\n", slotName)
+				fmt.Fprintf(&state.buildBuf, "vgcomp.%s = vugu.ScopedSlot(vgslotarg, func(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {\n", slotName)
+				fmt.Fprintf(&state.buildBuf, "%s := *vgslotarg; _ = %s\n", letName, letName)
+			} else {
+				fmt.Fprintf(&state.buildBuf, "vgcomp.%s = vugu.NewBuilderFunc(func(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {\n", slotName)
+			}
 			fmt.Fprintf(&state.buildBuf, "vgn := &vugu.VGNode{Type:vugu.VGNodeType(%d)}\n", vugu.ElementNode)
 			fmt.Fprintf(&state.buildBuf, "vgout = &vugu.BuildOut{}\n")
 			fmt.Fprintf(&state.buildBuf, "vgout.Out = append(vgout.Out, vgn)\n")
@@ -1179,6 +1479,9 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 
 			fmt.Fprintf(&state.buildBuf, "return\n")
 			fmt.Fprintf(&state.buildBuf, "})\n")
+			if letName != "" {
+				fmt.Fprintf(&state.buildBuf, "}\n")
+			}
 
 		}
 
@@ -1240,6 +1543,9 @@ func (p *ParserGo) visitNodeComponentElement(state *parseGoState, n *html.Node)
 
 	fmt.Fprintf(&state.buildBuf, "vgout.Components = append(vgout.Components, vgcomp)\n")
 	fmt.Fprintf(&state.buildBuf, "vgn = &vugu.VGNode{Component:vgcomp}\n")
+	if keyExpr != "" {
+		fmt.Fprintf(&state.buildBuf, "vgn.SetKey(%s)\n", keyExpr)
+	}
 	fmt.Fprintf(&state.buildBuf, "vgparent.AppendChild(vgn)\n")
 
 	return nil
@@ -1296,6 +1602,95 @@ func (p *ParserGo) emitForExpr(state *parseGoState, n *html.Node) error {
 	return nil
 }
 
+// emitModel outputs the property and event handler for vg-model, if present
+func (p *ParserGo) emitModel(state *parseGoState, n *html.Node) error {
+
+	m, err := vgModelExpr(n)
+	if err != nil {
+		return err
+	}
+	if m.expr == "" {
+		return nil
+	}
+
+	var inputType string
+	if a := attrWithKey(n, "type"); a != nil {
+		inputType = strings.ToLower(a.Val)
+	}
+
+	switch n.Data {
+	case "input", "select", "textarea":
+	default:
+		return fmt.Errorf("vg-model is not supported on <%s>, only input, select and textarea", n.Data)
+	}
+
+	eventType := "input"
+	if m.lazy || n.Data == "select" || inputType == "checkbox" || inputType == "radio" {
+		eventType = "change"
+	}
+	// handlers are matched by event type and modifiers, so only a plain @input/@change conflicts
+	if eventMap, _ := vgDOMEventExprs(n); eventMap[eventType] != "" {
+		return fmt.Errorf("vg-model cannot be used together with @%s on the same element", eventType)
+	}
+
+	var flags []string
+	if m.lazy {
+		flags = append(flags, "vugu.ModelLazy")
+	}
+	if m.trim {
+		flags = append(flags, "vugu.ModelTrim")
+	}
+	if m.numbr {
+		flags = append(flags, "vugu.ModelNumber")
+	}
+	flagsExpr := "vugu.ModelFlag(0)"
+	if len(flags) > 0 {
+		flagsExpr = strings.Join(flags, "|")
+	}
+
+	var propKey, propExpr, setExpr string
+	switch inputType {
+	case "checkbox":
+		propKey = "checked"
+		propExpr = fmt.Sprintf("vugu.ModelChecked(&(%s))", m.expr)
+		setExpr = fmt.Sprintf("if err := vugu.ModelSetChecked(&(%s), event.PropBool(\"target\", \"checked\")); err != nil { vugu.HandleModelError(c, err) }", m.expr)
+	case "radio":
+		// the value for a radio comes from its own value attribute
+		valExpr := ""
+		if a := attrWithKey(n, "value"); a != nil {
+			valExpr = strconv.Quote(a.Val)
+		} else if a := attrWithKey(n, ":value"); a != nil {
+			valExpr = fmt.Sprintf("fmt.Sprint(%s)", a.Val)
+		} else {
+			return fmt.Errorf("vg-model on a radio input requires a value attribute")
+		}
+		propKey = "checked"
+		propExpr = fmt.Sprintf("vugu.ModelValue(&(%s), %q) == %s", m.expr, inputType, valExpr)
+		setExpr = fmt.Sprintf("if event.PropBool(\"target\", \"checked\") { if err := vugu.ModelSet(&(%s), %q, %s, %s); err != nil { vugu.HandleModelError(c, err) } }", m.expr, inputType, valExpr, flagsExpr)
+	default:
+		propKey = "value"
+		propExpr = fmt.Sprintf("vugu.ModelValue(&(%s), %q)", m.expr, inputType)
+		setExpr = fmt.Sprintf("if err := vugu.ModelSet(&(%s), %q, event.PropString(\"target\", \"value\"), %s); err != nil { vugu.HandleModelError(c, err) }", m.expr, inputType, flagsExpr)
+	}
+
+	fmt.Fprintf(&state.buildBuf, "{b, err := vjson.Marshal(%s); if err != nil { panic(err) }; vgn.Prop = append(vgn.Prop, vugu.VGProperty{Key:%q,JSONVal:vjson.RawMessage(b)})}\n", propExpr, propKey)
+	fmt.Fprintf(&state.buildBuf, "vgn.DOMEventHandlerSpecList = append(vgn.DOMEventHandlerSpecList, vugu.DOMEventHandlerSpec{\n")
+	fmt.Fprintf(&state.buildBuf, "EventType: %q,\n", eventType)
+	fmt.Fprintf(&state.buildBuf, "Func: func(event vugu.DOMEvent) { %s },\n", setExpr)
+	fmt.Fprintf(&state.buildBuf, "})\n")
+
+	return nil
+}
+
+// scopePkgID returns the import path of the package for scopeAttrName, or if it is not in a
+// module the package name and the path of the .vugu file
+func (p *ParserGo) scopePkgID(fname string) string {
+	if ip := goImportPath(p.OutDir); ip != "" {
+		return ip
+	}
+	return p.PackageName + ":" + filepath.ToSlash(filepath.Join(p.OutDir, fname))
+}
+
 func hasUpperFirst(s string) bool {
 	for _, c := range s {
 		return unicode.IsUpper(c)
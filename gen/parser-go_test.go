@@ -1,6 +1,7 @@
 package gen
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -149,3 +150,39 @@ _ = value
 		})
 	}
 }
+
+func TestInferTypeArgs(t *testing.T) {
+	tests := []struct {
+		name          string
+		in            string
+		expectedError string
+	}{
+		{
+			name:          "no field uses all type parameters",
+			in:            `<div><main:Table[] :Title="c.Title" :Rows="c.Rows"></main:Table[]></div>`,
+			expectedError: "component tag <main:Table[]> has empty type arguments but none of its bound fields has a type which uses all of the type parameters of Table, give the type arguments explicitly",
+		},
+		{
+			name:          "not generic",
+			in:            `<div><main:Plain[] :Title="c.Title"></main:Plain[]></div>`,
+			expectedError: "component Plain has empty type arguments but is not generic",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := &ParserGo{
+				PackageName: "main",
+				StructType:  "Root",
+				OutDir:      t.TempDir(),
+				OutFile:     "root_gen.go",
+				PkgTypeDecls: map[string]string{
+					"Table": "type Table[K comparable, V any] struct { Title string; Rows map[string]V }",
+					"Plain": "type Plain struct { Title string }",
+				},
+			}
+			err := pg.Parse(strings.NewReader(tt.in), "root.vugu")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
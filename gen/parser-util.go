@@ -8,7 +8,9 @@ import (
 	"go/token"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	// "github.com/vugu/vugu/internal/htmlx"
 
@@ -64,6 +66,16 @@ func vgSlotName(n *html.Node) string {
 	return ""
 }
 
+// vgLetName returns the variable name from vg-let on a vg-slot, which makes it a scoped slot
+func vgLetName(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key == "vg-let" {
+			return a.Val
+		}
+	}
+	return ""
+}
+
 func vgVarExpr(n *html.Node) string {
 	for _, a := range n.Attr {
 		if a.Key == "vg-var" {
@@ -82,6 +94,16 @@ func vgIfExpr(n *html.Node) string {
 	return ""
 }
 
+// vgSwitchExpr returns the vg-switch expression, ok is true if the attribute is present (the expression may be empty)
+func vgSwitchExpr(n *html.Node) (expr string, ok bool) {
+	for _, a := range n.Attr {
+		if a.Key == "vg-switch" {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
 func vgKeyExpr(n *html.Node) string {
 	for _, a := range n.Attr {
 		if a.Key == "vg-key" {
@@ -91,6 +113,29 @@ func vgKeyExpr(n *html.Node) string {
 	return ""
 }
 
+// vgPortalTargetExpr returns a Go expression for the target selector of a vg-portal, from either
+// target (a literal selector) or :target (a Go expression)
+func vgPortalTargetExpr(n *html.Node) string {
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "target":
+			return strconv.Quote(a.Val)
+		case ":target":
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func vgRefExpr(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key == "vg-ref" {
+			return a.Val
+		}
+	}
+	return ""
+}
+
 func vgCompExpr(n *html.Node) string {
 	for _, a := range n.Attr {
 		if a.Key == "expr" {
@@ -132,6 +177,40 @@ func vgForExpr(n *html.Node) (vgForAttr, error) {
 	return vgForAttr{}, nil
 }
 
+type vgModelAttr struct {
+	expr  string
+	lazy  bool
+	trim  bool
+	numbr bool
+}
+
+// vgModelExpr extracts vg-model and its modifiers, e.g. vg-model.lazy.trim="c.Name"
+func vgModelExpr(n *html.Node) (vgModelAttr, error) {
+	for _, a := range n.Attr {
+		if a.Key == "vg-model" || strings.HasPrefix(a.Key, "vg-model.") {
+			v := vgModelAttr{expr: strings.TrimSpace(a.Val)}
+			opts := strings.Split(a.Key, ".")
+			for _, opt := range opts[1:] {
+				switch opt {
+				case "lazy":
+					v.lazy = true
+				case "trim":
+					v.trim = true
+				case "number":
+					v.numbr = true
+				default:
+					return vgModelAttr{}, fmt.Errorf("vg-model option %q unknown", opt)
+				}
+			}
+			if v.expr == "" {
+				return vgModelAttr{}, fmt.Errorf("vg-model must not be empty")
+			}
+			return v, nil
+		}
+	}
+	return vgModelAttr{}, nil
+}
+
 func vgHTMLExpr(n *html.Node) string {
 	for _, a := range n.Attr {
 		// vg-html and vg-content are the same thing,
@@ -236,6 +315,78 @@ func vgEventExprs(n *html.Node) (ret map[string]string, retKeys []string) {
 	return
 }
 
+type vgEventModifiers struct {
+	capture bool
+	passive bool
+	prevent bool
+	stop    bool
+	once    bool
+	self    bool
+	keys    []string
+}
+
+// parseDOMEventKey splits an event attribute key (without the "@") into the event type
+// and its modifiers, e.g. "keydown.enter.prevent" gives "keydown" with prevent and the key "enter".
+// Anything that is not one of the known modifiers must be a key name (see vgEventKeyNames),
+// a single character such as "a" or "1", or a function key "f1" to "f12", otherwise
+// an error is returned so that a misspelled modifier does not silently become a key filter.
+func parseDOMEventKey(k string) (eventType string, mods vgEventModifiers, err error) {
+	parts := strings.Split(k, ".")
+	eventType = parts[0]
+	for _, part := range parts[1:] {
+		switch part {
+		case "capture":
+			mods.capture = true
+		case "passive":
+			mods.passive = true
+		case "prevent":
+			mods.prevent = true
+		case "stop":
+			mods.stop = true
+		case "once":
+			mods.once = true
+		case "self":
+			mods.self = true
+		case "":
+		default:
+			key := strings.ToLower(part)
+			if !isEventKeyName(key) {
+				return "", vgEventModifiers{}, fmt.Errorf("unknown event modifier or key %q in @%s", part, k)
+			}
+			mods.keys = append(mods.keys, key)
+		}
+	}
+	return
+}
+
+// vgEventKeyNames are the key and mouse button names accepted as event modifiers.  The aliases,
+// modifier keys and mouse buttons are matched specially by the renderer, the others are
+// KeyboardEvent.key values written in lower case with dashes, e.g. "PageDown" is "page-down".
+var vgEventKeyNames = map[string]bool{
+	// aliases
+	"enter": true, "tab": true, "delete": true, "esc": true, "space": true,
+	"up": true, "down": true, "left": true, "right": true,
+	// modifier keys
+	"ctrl": true, "alt": true, "shift": true, "meta": true,
+	// mouse buttons (left and right are above)
+	"middle": true,
+	// other KeyboardEvent.key values
+	"escape": true, "backspace": true, "insert": true, "home": true, "end": true,
+	"page-up": true, "page-down": true,
+	"arrow-up": true, "arrow-down": true, "arrow-left": true, "arrow-right": true,
+	"caps-lock": true, "context-menu": true,
+}
+
+func isEventKeyName(key string) bool {
+	if vgEventKeyNames[key] || utf8.RuneCountInString(key) == 1 {
+		return true
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(key, "f")); err == nil && strings.HasPrefix(key, "f") && n >= 1 && n <= 12 {
+		return true
+	}
+	return false
+}
+
 // var vgDOMParseExprRE = regexp.MustCompile(`^([a-zA-Z0-9_.]+)\((.*)\)$`)
 
 // func vgDOMParseExpr(expr string) (receiver string, methodName string, argList string) {
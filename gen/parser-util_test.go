@@ -67,3 +67,35 @@ func TestVgForExpr(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDOMEventKey(t *testing.T) {
+	tests := []struct {
+		key          string
+		expectedType string
+		expectedMods vgEventModifiers
+	}{
+		{key: "click", expectedType: "click"},
+		{key: "click.prevent.stop", expectedType: "click", expectedMods: vgEventModifiers{prevent: true, stop: true}},
+		{key: "scroll.passive.capture.once", expectedType: "scroll", expectedMods: vgEventModifiers{passive: true, capture: true, once: true}},
+		{key: "click.self.right", expectedType: "click", expectedMods: vgEventModifiers{self: true, keys: []string{"right"}}},
+		{key: "keydown.enter.ctrl", expectedType: "keydown", expectedMods: vgEventModifiers{keys: []string{"enter", "ctrl"}}},
+		{key: "keyup.Page-Down", expectedType: "keyup", expectedMods: vgEventModifiers{keys: []string{"page-down"}}},
+		{key: "keydown.a.f5", expectedType: "keydown", expectedMods: vgEventModifiers{keys: []string{"a", "f5"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			eventType, mods, err := parseDOMEventKey(test.key)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedType, eventType)
+			assert.Equal(t, test.expectedMods, mods)
+		})
+	}
+
+	// a misspelled modifier is an error rather than a key filter
+	for _, key := range []string{"click.prevnt", "keydown.entr", "keydown.f13"} {
+		_, _, err := parseDOMEventKey(key)
+		assert.Error(t, err, key)
+	}
+	_, _, err := parseDOMEventKey("submit.prevnt")
+	assert.EqualError(t, err, `unknown event modifier or key "prevnt" in @submit.prevnt`)
+}
@@ -7,9 +7,21 @@ Your code will compile targeting either wasm or non-wasm environments.  In wasm,
 is exactly the same, the calls and types are delegated directly to "syscall/js".  The compiler
 will optimize away virtually (if not literally) all of the overhead associated with this aliasing.
 When run outside of wasm, appropriate functionality indicates that the environment is not
-availble: All js.Value instances are undefined.  Global(), Value.Get() always return undefined.
-Value.Call(), FuncOf() and other such functions will panic.  Value.Truthy() will always return false.
-For example, Global().Truthy() can be used to determine if the js environment is functional.
+availble: Global() returns undefined, and Value.Get(), Value.Call() and other such methods will panic
+when called on it.  Value.Truthy() of undefined is false, so for example Global().Truthy() can be used
+to determine if the js environment is functional.
+
+For testing, a JS environment written in Go can be provided outside of wasm: a Value holds a Go value
+(see ValueOf) and Go types which implement Object stand in for JavaScript objects.  SetGlobal sets what
+Global() returns, e.g. an Object for the browser window which implements the functions the code under test calls.
+vugutest.Window does this for the functions that domrender uses.
+
+Until SetGlobal is called the placeholder behavior above is unchanged: ValueOf and Null return undefined,
+Equal always returns true, FuncOf panics and the methods of Element do nothing.  Once a JS environment is
+installed they behave as in wasm against it: ValueOf returns a Value holding x (an Object can be passed to ValueOf
+before then, to create the Value for SetGlobal), Null().IsNull() is true, Equal compares the values held (objects
+are equal only if they are the same one), the Func returned by FuncOf can be called with Value.Invoke and
+Element calls the methods of the element.  Setting the global back to Undefined() restores the placeholders.
 
 Rationale: Since syscall/js is only available when the GOOS is "js", this means programs which run server-side
 cannot access that package and will fail to compile.  Since Vugu components are inherently closely
@@ -0,0 +1,97 @@
+package js
+
+// Element is a handle to a DOM element, it is what vg-ref assigns to a component field, e.g.:
+//
+//	<input vg-ref="c.NameInput">
+//
+//	type Root struct { NameInput js.Element }
+//
+//	func (c *Root) HandleEdit(event vugu.DOMEvent) { c.NameInput.Focus() }
+//
+// The zero value is not set and its methods do nothing.  Outside of wasm the methods do nothing either,
+// so components using them can still be built and run server-side, unless a JS environment is installed
+// with SetGlobal (e.g. by vugutest) in which case they are called on the element it provides.
+type Element struct {
+	v   Value
+	set bool
+}
+
+// ElementOf returns an Element for the js.Value of a DOM element.
+func ElementOf(v Value) Element {
+	return Element{v: v, set: true}
+}
+
+// IsSet returns true if the Element refers to an element, i.e. it has been assigned and the element
+// has not been removed since.
+func (e Element) IsSet() bool {
+	return e.set
+}
+
+// JSValue returns the underlying js.Value for the element, which is undefined if it is not set.
+func (e Element) JSValue() Value {
+	return e.v
+}
+
+// Rect is the size and position of an element relative to the viewport, as returned by GetBoundingClientRect.
+type Rect struct {
+	X, Y, Width, Height      float64
+	Top, Right, Bottom, Left float64
+}
+
+// Focus gives the element keyboard focus.
+func (e Element) Focus() {
+	if e.v.Truthy() {
+		e.v.Call("focus")
+	}
+}
+
+// Blur removes keyboard focus from the element.
+func (e Element) Blur() {
+	if e.v.Truthy() {
+		e.v.Call("blur")
+	}
+}
+
+// ScrollIntoView scrolls the element's ancestors so it is visible.
+func (e Element) ScrollIntoView() {
+	if e.v.Truthy() {
+		e.v.Call("scrollIntoView")
+	}
+}
+
+// GetBoundingClientRect returns the size and position of the element relative to the viewport.
+func (e Element) GetBoundingClientRect() Rect {
+	if !e.v.Truthy() {
+		return Rect{}
+	}
+	r := e.v.Call("getBoundingClientRect")
+	return Rect{
+		X:      r.Get("x").Float(),
+		Y:      r.Get("y").Float(),
+		Width:  r.Get("width").Float(),
+		Height: r.Get("height").Float(),
+		Top:    r.Get("top").Float(),
+		Right:  r.Get("right").Float(),
+		Bottom: r.Get("bottom").Float(),
+		Left:   r.Get("left").Float(),
+	}
+}
+
+// Value returns the value property of the element (for input, select and textarea elements).
+func (e Element) Value() string {
+	if !e.v.Truthy() {
+		return ""
+	}
+	v := e.v.Get("value")
+	if v.Type() != TypeString {
+		return ""
+	}
+	return v.String()
+}
+
+// SetValue sets the value property of the element (for input, select and textarea elements).
+func (e Element) SetValue(s string) {
+	if e.v.Truthy() {
+		e.v.Set("value", s)
+	}
+}
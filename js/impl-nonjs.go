@@ -4,10 +4,51 @@ package js
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync/atomic"
 )
 
 var errNotImpl = errors.New("js not implemented")
 
+// Outside of wasm a Value holds a Go value which stands in for the JavaScript one (see ValueOf),
+// this is enough for Go code like domrender to be run against a JS environment written in Go,
+// e.g. in tests.  Such an environment implements Object and is installed with SetGlobal.
+// Until then ValueOf, Null, Equal and FuncOf keep their placeholder behavior (see hasGlobal).
+
+// Object is implemented by Go types which stand in for JavaScript objects outside of wasm.
+// Value.Get, Value.Set and Value.Call on a Value holding an Object are passed to it.
+//
+// This is only available outside of wasm.
+type Object interface {
+	Get(p string) Value
+	Set(p string, x any)
+	Call(m string, args ...any) Value
+}
+
+// jsNull is held by the Value for null, a nil Go value is undefined
+type jsNull struct{}
+
+var global atomic.Pointer[Value]
+
+// SetGlobal sets the value returned by Global, usually a Value holding an Object which implements the
+// functions that the code under test calls.  Setting it to Undefined() restores the default.
+//
+// This is only available outside of wasm.
+func SetGlobal(v Value) {
+	global.Store(&v)
+}
+
+// hasGlobal returns true if a JS environment has been installed with SetGlobal, otherwise the
+// functions which create values behave as they always have outside of wasm: ValueOf and Null
+// return undefined, all values are Equal and FuncOf panics.
+func hasGlobal() bool {
+	g := global.Load()
+	return g != nil && g.v != nil
+}
+
 // ######################
 // # syscall/js func.go #
 // ######################
@@ -38,9 +79,15 @@ type Func struct {
 //
 // Func.Release must be called to free up resources when the function will not be invoked any more.
 //
+// Outside of wasm this panics unless a JS environment is installed with SetGlobal,
+// in which case the returned Func can be invoked from Go with Value.Invoke.
+//
 // This is a syscall/js placeholder.
 func FuncOf(fn func(this Value, args []Value) any) Func {
-	panic(errNotImpl)
+	if !hasGlobal() {
+		panic(errNotImpl)
+	}
+	return Func{Value: Value{v: fn}}
 }
 
 // Release frees up resources allocated for the function.
@@ -60,6 +107,7 @@ func (c Func) Release() {}
 // This is a syscall/js placeholder.
 type Value struct {
 	_ [0]func() // uncomparable; to make == not compile
+	v any
 }
 
 // Error wraps a JavaScript error.
@@ -78,9 +126,38 @@ func (e Error) Error() string {
 
 // Equal reports whether v and w are equal according to JavaScript's === operator.
 //
+// Outside of wasm this is always true unless a JS environment is installed with SetGlobal.
+//
 // This is a syscall/js placeholder.
 func (v Value) Equal(w Value) bool {
-	return true
+	if !hasGlobal() {
+		return true
+	}
+	a, b := reflect.ValueOf(v.v), reflect.ValueOf(w.v)
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Func:
+		// these are objects, which are equal if they are the same one
+		return a.Pointer() == b.Pointer() && (a.Kind() != reflect.Slice || a.Len() == b.Len())
+	}
+	if a.Comparable() {
+		return a.Equal(b)
+	}
+	return false
+}
+
+// valueError returns what methods which are not supported for v panic with,
+// for undefined (and so for all values unless a JS environment is provided) this is errNotImpl
+func (v Value) valueError(method string) error {
+	if v.v == nil {
+		return errNotImpl
+	}
+	return &ValueError{Method: method, Type: v.Type()}
 }
 
 // Undefined returns the JavaScript value "undefined".
@@ -94,34 +171,46 @@ func Undefined() Value {
 //
 // This is a syscall/js placeholder.
 func (v Value) IsUndefined() bool {
-	return true
+	return v.v == nil
 }
 
 // Null returns the JavaScript value "null".
 //
+// Outside of wasm this is undefined unless a JS environment is installed with SetGlobal.
+//
 // This is a syscall/js placeholder.
 func Null() Value {
-	return Value{}
+	if !hasGlobal() {
+		return Value{}
+	}
+	return Value{v: jsNull{}}
 }
 
-// Null returns the JavaScript value "null".
+// IsNull reports whether v is the JavaScript value "null".
 //
 // This is a syscall/js placeholder.
 func (v Value) IsNull() bool {
-	return false
+	_, ok := v.v.(jsNull)
+	return ok
 }
 
 // IsNaN reports whether v is the JavaScript value "NaN".
 //
 // This is a syscall/js placeholder.
 func (v Value) IsNaN() bool {
-	return false
+	f, ok := v.v.(float64)
+	return ok && math.IsNaN(f)
 }
 
 // Global returns the JavaScript global object, usually "window" or "global".
 //
+// Outside of wasm this is undefined unless set with SetGlobal.
+//
 // This is a syscall/js placeholder.
 func Global() Value {
+	if g := global.Load(); g != nil {
+		return *g
+	}
 	return Value{}
 }
 
@@ -140,9 +229,57 @@ func Global() Value {
 //
 // Panics if x is not one of the expected types.
 //
+// Outside of wasm this returns undefined unless a JS environment is installed with SetGlobal,
+// or x is an Object.  Otherwise the Value holds x (numbers as a float64) and, in addition to the
+// above, a []byte stands in for a Uint8Array and an Object for any other JavaScript object.
+//
 // This is a syscall/js placeholder.
 func ValueOf(x any) Value {
-	return Value{}
+	switch x := x.(type) {
+	case Value:
+		return x
+	case Func:
+		return x.Value
+	case Object:
+		// needed to create the value passed to SetGlobal
+		return Value{v: x}
+	}
+	if !hasGlobal() {
+		return Value{}
+	}
+	switch x := x.(type) {
+	case nil:
+		return Null()
+	case bool, string, []byte, []any, map[string]any:
+		return Value{v: x}
+	case int:
+		return Value{v: float64(x)}
+	case int8:
+		return Value{v: float64(x)}
+	case int16:
+		return Value{v: float64(x)}
+	case int32:
+		return Value{v: float64(x)}
+	case int64:
+		return Value{v: float64(x)}
+	case uint:
+		return Value{v: float64(x)}
+	case uint8:
+		return Value{v: float64(x)}
+	case uint16:
+		return Value{v: float64(x)}
+	case uint32:
+		return Value{v: float64(x)}
+	case uint64:
+		return Value{v: float64(x)}
+	case uintptr:
+		return Value{v: float64(x)}
+	case float32:
+		return Value{v: float64(x)}
+	case float64:
+		return Value{v: x}
+	}
+	panic("ValueOf: invalid value")
 }
 
 // Type represents the JavaScript type of a Value.
@@ -164,7 +301,25 @@ const (
 
 // This is a syscall/js placeholder.
 func (t Type) String() string {
-	return "undefined"
+	switch t {
+	case TypeUndefined:
+		return "undefined"
+	case TypeNull:
+		return "null"
+	case TypeBoolean:
+		return "boolean"
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeSymbol:
+		return "symbol"
+	case TypeObject:
+		return "object"
+	case TypeFunction:
+		return "function"
+	}
+	panic("bad type")
 }
 
 // Type returns the JavaScript type of the value v. It is similar to JavaScript's typeof operator,
@@ -172,7 +327,21 @@ func (t Type) String() string {
 //
 // This is a syscall/js placeholder.
 func (v Value) Type() Type {
-	return TypeUndefined
+	switch v.v.(type) {
+	case nil:
+		return TypeUndefined
+	case jsNull:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case float64:
+		return TypeNumber
+	case string:
+		return TypeString
+	case func(this Value, args []Value) any:
+		return TypeFunction
+	}
+	return TypeObject
 }
 
 // Get returns the JavaScript property p of value v.
@@ -180,7 +349,21 @@ func (v Value) Type() Type {
 //
 // This is a syscall/js placeholder.
 func (v Value) Get(p string) Value {
-	panic(errNotImpl)
+	switch o := v.v.(type) {
+	case Object:
+		return o.Get(p)
+	case map[string]any:
+		if x, ok := o[p]; ok {
+			return ValueOf(x)
+		}
+		return Undefined()
+	case []byte, []any:
+		if p == "length" {
+			return ValueOf(reflect.ValueOf(o).Len())
+		}
+		return Undefined()
+	}
+	panic(v.valueError("Value.Get"))
 }
 
 // Set sets the JavaScript property p of value v to ValueOf(x).
@@ -188,7 +371,15 @@ func (v Value) Get(p string) Value {
 //
 // This is a syscall/js placeholder.
 func (v Value) Set(p string, x any) {
-	panic(errNotImpl)
+	switch o := v.v.(type) {
+	case Object:
+		o.Set(p, x)
+		return
+	case map[string]any:
+		o[p] = x
+		return
+	}
+	panic(v.valueError("Value.Set"))
 }
 
 // Delete deletes the JavaScript property p of value v.
@@ -196,7 +387,15 @@ func (v Value) Set(p string, x any) {
 //
 // This is a syscall/js placeholder.
 func (v Value) Delete(p string) {
-	panic(errNotImpl)
+	switch o := v.v.(type) {
+	case Object:
+		o.Set(p, Undefined())
+		return
+	case map[string]any:
+		delete(o, p)
+		return
+	}
+	panic(v.valueError("Value.Delete"))
 }
 
 // Index returns JavaScript index i of value v.
@@ -204,7 +403,15 @@ func (v Value) Delete(p string) {
 //
 // This is a syscall/js placeholder.
 func (v Value) Index(i int) Value {
-	panic(errNotImpl)
+	switch o := v.v.(type) {
+	case []byte:
+		return ValueOf(o[i])
+	case []any:
+		return ValueOf(o[i])
+	case Object:
+		return o.Get(strconv.Itoa(i))
+	}
+	panic(v.valueError("Value.Index"))
 }
 
 // SetIndex sets the JavaScript index i of value v to ValueOf(x).
@@ -212,7 +419,18 @@ func (v Value) Index(i int) Value {
 //
 // This is a syscall/js placeholder.
 func (v Value) SetIndex(i int, x any) {
-	panic(errNotImpl)
+	switch o := v.v.(type) {
+	case []byte:
+		o[i] = byte(ValueOf(x).Int())
+		return
+	case []any:
+		o[i] = x
+		return
+	case Object:
+		o.Set(strconv.Itoa(i), x)
+		return
+	}
+	panic(v.valueError("Value.SetIndex"))
 }
 
 // Length returns the JavaScript property "length" of v.
@@ -220,7 +438,7 @@ func (v Value) SetIndex(i int, x any) {
 //
 // This is a syscall/js placeholder.
 func (v Value) Length() int {
-	panic(errNotImpl)
+	return v.Get("length").Int()
 }
 
 // Call does a JavaScript call to the method m of value v with the given arguments.
@@ -229,7 +447,10 @@ func (v Value) Length() int {
 //
 // This is a syscall/js placeholder.
 func (v Value) Call(m string, args ...any) Value {
-	panic(errNotImpl)
+	if o, ok := v.v.(Object); ok {
+		return o.Call(m, args...)
+	}
+	return v.Get(m).Invoke(args...)
 }
 
 // Invoke does a JavaScript call of the value v with the given arguments.
@@ -238,7 +459,15 @@ func (v Value) Call(m string, args ...any) Value {
 //
 // This is a syscall/js placeholder.
 func (v Value) Invoke(args ...any) Value {
-	panic(errNotImpl)
+	fn, ok := v.v.(func(this Value, args []Value) any)
+	if !ok {
+		panic(v.valueError("Value.Invoke"))
+	}
+	vargs := make([]Value, len(args))
+	for i, a := range args {
+		vargs[i] = ValueOf(a)
+	}
+	return ValueOf(fn(Undefined(), vargs))
 }
 
 // New uses JavaScript's "new" operator with value v as constructor and the given arguments.
@@ -255,7 +484,11 @@ func (v Value) New(args ...any) Value {
 //
 // This is a syscall/js placeholder.
 func (v Value) Float() float64 {
-	panic(errNotImpl)
+	f, ok := v.v.(float64)
+	if !ok {
+		panic(v.valueError("Value.Float"))
+	}
+	return f
 }
 
 // Int returns the value v truncated to an int.
@@ -263,7 +496,11 @@ func (v Value) Float() float64 {
 //
 // This is a syscall/js placeholder.
 func (v Value) Int() int {
-	panic(errNotImpl)
+	f, ok := v.v.(float64)
+	if !ok {
+		panic(v.valueError("Value.Int"))
+	}
+	return int(f)
 }
 
 // Bool returns the value v as a bool.
@@ -271,7 +508,11 @@ func (v Value) Int() int {
 //
 // This is a syscall/js placeholder.
 func (v Value) Bool() bool {
-	panic(errNotImpl)
+	b, ok := v.v.(bool)
+	if !ok {
+		panic(v.valueError("Value.Bool"))
+	}
+	return b
 }
 
 // Truthy returns the JavaScript "truthiness" of the value v. In JavaScript,
@@ -280,7 +521,17 @@ func (v Value) Bool() bool {
 //
 // This is a syscall/js placeholder.
 func (v Value) Truthy() bool {
-	return false
+	switch x := v.v.(type) {
+	case nil, jsNull:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0 && !math.IsNaN(x)
+	case string:
+		return x != ""
+	}
+	return true
 }
 
 // String returns the value v as a string.
@@ -290,7 +541,13 @@ func (v Value) Truthy() bool {
 //
 // This is a syscall/js placeholder.
 func (v Value) String() string {
-	return "<undefined>"
+	switch x := v.v.(type) {
+	case string:
+		return x
+	case bool, float64:
+		return fmt.Sprintf("<%v: %v>", v.Type(), x)
+	}
+	return "<" + v.Type().String() + ">"
 }
 
 // InstanceOf reports whether v is an instance of type t according to JavaScript's instanceof operator.
@@ -312,7 +569,7 @@ type ValueError struct {
 
 // This is a syscall/js placeholder.
 func (e *ValueError) Error() string {
-	return errNotImpl.Error()
+	return "syscall/js: call of " + e.Method + " on " + e.Type.String()
 }
 
 // CopyBytesToGo copies bytes from src to dst.
@@ -321,7 +578,11 @@ func (e *ValueError) Error() string {
 //
 // This is a syscall/js placeholder.
 func CopyBytesToGo(dst []byte, src Value) int {
-	panic(errNotImpl)
+	b, ok := src.v.([]byte)
+	if !ok {
+		panic("syscall/js: CopyBytesToGo: expected src to be an Uint8Array or Uint8ClampedArray")
+	}
+	return copy(dst, b)
 }
 
 // CopyBytesToJS copies bytes from src to dst.
@@ -330,5 +591,9 @@ func CopyBytesToGo(dst []byte, src Value) int {
 //
 // This is a syscall/js placeholder.
 func CopyBytesToJS(dst Value, src []byte) int {
-	panic(errNotImpl)
+	b, ok := dst.v.([]byte)
+	if !ok {
+		panic("syscall/js: CopyBytesToJS: expected dst to be an Uint8Array or Uint8ClampedArray")
+	}
+	return copy(b, src)
 }
@@ -1,5 +1,7 @@
 package vugu
 
+import "context"
+
 // // UnlockRenderer is something that releases a lock and requests a re-render.
 // type UnlockRenderer interface {
 // 	UnlockRender()
@@ -9,12 +11,29 @@ package vugu
 type InitCtx interface {
 	EventEnv() EventEnv
 
+	// Context returns a context.Context which is cancelled when the component is destroyed,
+	// before its Destroy callback is called.  Pass it to anything started in Init, such as
+	// requests or tickers, so they stop along with the component.
+	Context() context.Context
+
+	// Go runs fn in a new goroutine with the context from Context.  It is a convenience for starting background
+	// work in Init, the results should be applied to the component with Update.
+	Go(fn func(ctx context.Context))
+
+	// Update acquires the EventEnv write lock and, if Context is not yet cancelled, calls fn and then
+	// UnlockRender.  If Context has been cancelled fn is not called, the lock is released without
+	// rendering and false is returned.  Since BuildEnv.RunBuild does not itself take the lock, the component
+	// can still be destroyed while fn runs unless builds are done with the EventEnv read lock held (as
+	// vugutest.Harness.Render does).  Update must not be called while already holding the lock, e.g. from Init.
+	Update(fn func()) bool
+
 	// TODO: decide if we want to do something like this for convenience
 	// Lock() UnlockRenderer
 }
 
 type initCtx struct {
 	eventEnv EventEnv
+	ctx      context.Context
 }
 
 // EventEnv implements InitCtx
@@ -22,6 +41,37 @@ func (c *initCtx) EventEnv() EventEnv {
 	return c.eventEnv
 }
 
+// Context implements InitCtx
+func (c *initCtx) Context() context.Context {
+	return c.ctx
+}
+
+// Go implements InitCtx
+func (c *initCtx) Go(fn func(ctx context.Context)) {
+	go fn(c.ctx)
+}
+
+// Update implements InitCtx
+func (c *initCtx) Update(fn func()) bool {
+	// the context is checked after acquiring the lock, which only excludes a concurrent destroy
+	// if the build that does it runs with the read lock held
+	if c.eventEnv == nil {
+		if c.ctx.Err() != nil {
+			return false
+		}
+		fn()
+		return true
+	}
+	c.eventEnv.Lock()
+	if c.ctx.Err() != nil {
+		c.eventEnv.UnlockOnly()
+		return false
+	}
+	defer c.eventEnv.UnlockRender() // deferred so a panic in fn does not leave the lock held
+	fn()
+	return true
+}
+
 type initer0 interface {
 	Init()
 }
@@ -29,12 +79,17 @@ type initer1 interface {
 	Init(ctx InitCtx)
 }
 
-func invokeInit(c any, eventEnv EventEnv) {
+// invokeInit calls Init on c if implemented, the returned function (nil if there is nothing to cancel)
+// must be passed to invokeDestroy
+func invokeInit(c any, eventEnv EventEnv) context.CancelFunc {
 	if i, ok := c.(initer0); ok {
 		i.Init()
 	} else if i, ok := c.(initer1); ok {
-		i.Init(&initCtx{eventEnv: eventEnv})
+		ctx, cancel := context.WithCancel(context.Background())
+		i.Init(&initCtx{eventEnv: eventEnv, ctx: ctx})
+		return cancel
 	}
+	return nil
 }
 
 // ComputeCtx is the context passed to a Compute callback.
@@ -87,7 +142,11 @@ type destroyer1 interface {
 	Destroy(ctx DestroyCtx)
 }
 
-func invokeDestroy(c any, eventEnv EventEnv) {
+// invokeDestroy cancels the context given to Init (cancel is from invokeInit) and then calls Destroy on c if implemented
+func invokeDestroy(c any, eventEnv EventEnv, cancel context.CancelFunc) {
+	if cancel != nil {
+		cancel()
+	}
 	if i, ok := c.(destroyer0); ok {
 		i.Destroy()
 	} else if i, ok := c.(destroyer1); ok {
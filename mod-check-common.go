@@ -9,6 +9,14 @@ package vugu
 // of it.  But for types that use lots of memory and would otherwise take too much time to traverse, a
 // counter or other value can be used to indicate that some changed is occured, with the rest of the
 // application using mutator methods to increment this value upon change.
+// Components can opt into being memoized by BuildEnv, so they are not built again while they report no
+// modification and the BuildOut from the prior pass is used instead, by tagging the fields their output
+// depends on with `vugu:"cparam"` (for fields set by the parent) or `vugu:"modcheck"` (for other state).
+// BuildEnv then calls ModTracker.ModCheckAll on a pointer to each of them.  A component using a ChangeCounter
+// tags it, e.g. an embedded vugu.ChangeCounter with the tag `vugu:"modcheck"`.  Untagged fields are not checked,
+// including slots and component events assigned by the parent, and a component which implements ModChecker without
+// tagged fields is built every pass as before.  Unexported fields are not checked even if tagged, keep state which
+// the output depends on in exported fields or call BuildEnv.Rebuild when it changes.
 type ModChecker interface {
 	ModCheck(mt *ModTracker, oldData any) (isModified bool, newData any)
 }
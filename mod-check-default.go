@@ -80,7 +80,7 @@ func (mt *ModTracker) dump() []byte {
 // Arrays and slices of supported types are supported, their length is compared as well
 // as a pointer to each member.
 // As a special case []byte is treated like a string.
-// Maps are not supported at this time.
+// Maps are not supported at this time, use Map instead (and Slice for large slices), which implement ModChecker.
 // Other weird and wonderful things like channels and funcs are not supported.
 // Passing an unsupported type will result in a panic.
 func (mt *ModTracker) ModCheckAll(values ...any) (ret bool) {
@@ -0,0 +1,234 @@
+package vugu
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModelFlag is a set of options for the vg-model helpers, corresponding
+// to the modifiers on the vg-model attribute (e.g. vg-model.trim="c.Name").
+type ModelFlag int
+
+const (
+	// ModelLazy syncs on the change event instead of input.
+	// This only affects which event the generated code listens for.
+	ModelLazy ModelFlag = 1 << iota
+	// ModelTrim trims leading and trailing whitespace from the value before it is assigned.
+	ModelTrim
+	// ModelNumber only assigns values which parse as a number.  For numeric fields
+	// this is implied, for string fields only numeric text is assigned and for
+	// interface{} fields numeric text is stored as a float64 and any other text
+	// as the string.
+	ModelNumber
+)
+
+// Time layouts used for time.Time fields, by input type.
+var modelTimeLayouts = map[string]string{
+	"date":           "2006-01-02",
+	"datetime-local": "2006-01-02T15:04",
+	"time":           "15:04",
+	"month":          "2006-01",
+}
+
+func modelTimeLayout(inputType string) string {
+	if l, ok := modelTimeLayouts[inputType]; ok {
+		return l
+	}
+	return time.RFC3339
+}
+
+// ModelValue returns the string to assign to the value property of an input, select or textarea
+// element bound with vg-model.  The ptr must be a pointer to a string, bool, int, uint or float
+// type (or a type derived from one of these), a time.Time or an interface{}.
+// The inputType is the type attribute of the element and is used to format time.Time values.
+// For any other type the empty string is returned, ModelSet reports the error when a value is assigned.
+func ModelValue(ptr any, inputType string) string {
+	switch v := ptr.(type) {
+	case *string:
+		return *v
+	case *time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(modelTimeLayout(inputType))
+	case *any:
+		if *v == nil {
+			return ""
+		}
+		return fmt.Sprint(*v)
+	}
+
+	rv, err := modelElem(ptr)
+	if err != nil {
+		return ""
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits())
+	}
+
+	return ""
+}
+
+// ModelSet converts val and assigns it to what ptr points to (see ModelValue for supported types).
+// If the value cannot be converted (e.g. "abc" for an int field) the field is left unchanged
+// and an error is returned.  An empty value assigns the zero value.  An unsupported type
+// is also returned as an error.
+func ModelSet(ptr any, inputType string, val string, flags ModelFlag) error {
+
+	if flags&ModelTrim != 0 {
+		val = strings.TrimSpace(val)
+	}
+
+	switch v := ptr.(type) {
+	case *string:
+		if flags&ModelNumber != 0 && val != "" {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err != nil {
+				return fmt.Errorf("vg-model: %q is not a number", val)
+			}
+		}
+		*v = val
+		return nil
+	case *time.Time:
+		if val == "" {
+			*v = time.Time{}
+			return nil
+		}
+		t, err := time.ParseInLocation(modelTimeLayout(inputType), val, time.Local)
+		if err != nil {
+			return fmt.Errorf("vg-model: %w", err)
+		}
+		*v = t
+		return nil
+	case *any:
+		if flags&ModelNumber != 0 {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				*v = f
+				return nil
+			}
+		}
+		*v = val
+		return nil
+	}
+
+	rv, err := modelElem(ptr)
+	if err != nil {
+		return err
+	}
+	nval := strings.TrimSpace(val)
+	switch rv.Kind() {
+	case reflect.String:
+		if flags&ModelNumber != 0 && val != "" {
+			if _, err := strconv.ParseFloat(nval, 64); err != nil {
+				return fmt.Errorf("vg-model: %q is not a number", val)
+			}
+		}
+		rv.SetString(val)
+		return nil
+	case reflect.Bool:
+		if nval == "" {
+			rv.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(nval)
+		if err != nil {
+			return fmt.Errorf("vg-model: %w", err)
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if nval == "" {
+			rv.SetInt(0)
+			return nil
+		}
+		i, err := strconv.ParseInt(nval, 10, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("vg-model: %w", err)
+		}
+		rv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if nval == "" {
+			rv.SetUint(0)
+			return nil
+		}
+		u, err := strconv.ParseUint(nval, 10, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("vg-model: %w", err)
+		}
+		rv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if nval == "" {
+			rv.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(nval, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("vg-model: %w", err)
+		}
+		rv.SetFloat(f)
+		return nil
+	}
+
+	return fmt.Errorf("vg-model: unsupported type %T", ptr)
+}
+
+// ModelErrorHandler can be implemented by a component to be told when the value of an element
+// it binds with vg-model could not be assigned (see ModelSet), e.g. to show a validation message.
+type ModelErrorHandler interface {
+	ModelError(err error)
+}
+
+// HandleModelError is called by the generated code when ModelSet returns an error.  The error is
+// passed to c if it implements ModelErrorHandler and is logged otherwise.
+func HandleModelError(c any, err error) {
+	if h, ok := c.(ModelErrorHandler); ok {
+		h.ModelError(err)
+		return
+	}
+	log.Print(err)
+}
+
+// ModelChecked returns the checked state for a checkbox bound with vg-model, ptr must point to a bool.
+// For any other type it returns false, ModelSetChecked reports the error.
+func ModelChecked(ptr any) bool {
+	rv, err := modelElem(ptr)
+	if err != nil || rv.Kind() != reflect.Bool {
+		return false
+	}
+	return rv.Bool()
+}
+
+// ModelSetChecked assigns the checked state of a checkbox bound with vg-model, ptr must point to a bool.
+func ModelSetChecked(ptr any, checked bool) error {
+	rv, err := modelElem(ptr)
+	if err != nil {
+		return err
+	}
+	if rv.Kind() != reflect.Bool {
+		return fmt.Errorf("vg-model: checkbox requires a bool, got %T", ptr)
+	}
+	rv.SetBool(checked)
+	return nil
+}
+
+func modelElem(ptr any) (reflect.Value, error) {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return reflect.Value{}, fmt.Errorf("vg-model: expected a non-nil pointer, got %T", ptr)
+	}
+	return rv.Elem(), nil
+}
@@ -0,0 +1,84 @@
+package vugu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type modelTestStatus string
+
+type modelTestErrors []error
+
+func (e *modelTestErrors) ModelError(err error) { *e = append(*e, err) }
+
+func TestModelSet(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var s string
+	assert.NoError(ModelSet(&s, "text", "  hello ", ModelTrim))
+	assert.Equal("hello", s)
+	assert.Equal("hello", ModelValue(&s, "text"))
+	assert.Error(ModelSet(&s, "text", "abc", ModelNumber))
+	assert.Equal("hello", s)
+
+	var st modelTestStatus
+	assert.NoError(ModelSet(&st, "", "active", 0))
+	assert.Equal(modelTestStatus("active"), st)
+
+	var i int8
+	assert.NoError(ModelSet(&i, "number", " 12 ", 0))
+	assert.Equal(int8(12), i)
+	assert.Error(ModelSet(&i, "number", "1000", 0))
+	assert.Equal(int8(12), i)
+	assert.Equal("12", ModelValue(&i, "number"))
+	assert.NoError(ModelSet(&i, "number", "", 0))
+	assert.Equal(int8(0), i)
+
+	var u uint
+	assert.NoError(ModelSet(&u, "", "7", 0))
+	assert.Equal(uint(7), u)
+	assert.Error(ModelSet(&u, "", "-7", 0))
+
+	var f float64
+	assert.NoError(ModelSet(&f, "range", "1.5", 0))
+	assert.Equal(1.5, f)
+	assert.Equal("1.5", ModelValue(&f, "range"))
+
+	var b bool
+	assert.NoError(ModelSet(&b, "", "true", 0))
+	assert.True(b)
+	ModelSetChecked(&b, false)
+	assert.False(ModelChecked(&b))
+
+	var tm time.Time
+	assert.NoError(ModelSet(&tm, "date", "2021-03-04", 0))
+	assert.Equal("2021-03-04", ModelValue(&tm, "date"))
+	assert.NoError(ModelSet(&tm, "datetime-local", "2021-03-04T05:06", 0))
+	assert.Equal("2021-03-04T05:06", ModelValue(&tm, "datetime-local"))
+	assert.Error(ModelSet(&tm, "date", "nope", 0))
+	assert.NoError(ModelSet(&tm, "date", "", 0))
+	assert.True(tm.IsZero())
+	assert.Equal("", ModelValue(&tm, "date"))
+
+	var a any
+	assert.NoError(ModelSet(&a, "", "3", ModelNumber))
+	assert.Equal(float64(3), a)
+	assert.NoError(ModelSet(&a, "", "x", ModelNumber))
+	assert.Equal("x", a)
+
+	var h modelTestErrors
+	HandleModelError(&h, ModelSet(&i, "number", "abc", 0))
+	assert.Len(h, 1)
+
+	// unsupported types are reported by the setters and do not panic
+	var sl []string
+	assert.Equal("", ModelValue(&sl, ""))
+	assert.EqualError(ModelSet(&sl, "", "x", 0), "vg-model: unsupported type *[]string")
+	assert.Nil(sl)
+	assert.False(ModelChecked(&s))
+	assert.Error(ModelSetChecked(&s, true))
+	assert.Error(ModelSet(nil, "", "x", 0))
+}
@@ -0,0 +1,273 @@
+package vugu
+
+import "slices"
+
+// ChangeKind is the kind of modification described by a SliceChange or MapChange.
+type ChangeKind int
+
+const (
+	// ChangeInsert means elements were added.
+	ChangeInsert ChangeKind = iota + 1
+	// ChangeRemove means elements were removed.
+	ChangeRemove
+	// ChangeUpdate means elements were replaced with new values.
+	ChangeUpdate
+	// ChangeReset means the contents were replaced entirely (or cleared), it has no index or key.
+	ChangeReset
+)
+
+// String returns the name of the ChangeKind.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeInsert:
+		return "insert"
+	case ChangeRemove:
+		return "remove"
+	case ChangeUpdate:
+		return "update"
+	case ChangeReset:
+		return "reset"
+	}
+	return "unknown"
+}
+
+// maxChangeLog is the number of changes kept for ChangesSince
+const maxChangeLog = 64
+
+// changeLog records a version number and the most recent changes of an observable collection
+type changeLog[C any] struct {
+	version uint64
+	changes []C // the last len(changes) changes, the last one is for version
+	subs    map[int]func(C)
+	nextSub int
+}
+
+func (l *changeLog[C]) add(c C) {
+	l.version++
+	if len(l.changes) == maxChangeLog {
+		copy(l.changes, l.changes[1:])
+		l.changes = l.changes[:maxChangeLog-1]
+	}
+	l.changes = append(l.changes, c)
+	for _, fn := range l.subs {
+		fn(c)
+	}
+}
+
+func (l *changeLog[C]) since(version uint64) ([]C, bool) {
+	if version > l.version || l.version-version > uint64(len(l.changes)) {
+		return nil, false
+	}
+	ret := l.changes[len(l.changes)-int(l.version-version):]
+	return append([]C(nil), ret...), true
+}
+
+func (l *changeLog[C]) subscribe(fn func(C)) (unsubscribe func()) {
+	if l.subs == nil {
+		l.subs = make(map[int]func(C))
+	}
+	id := l.nextSub
+	l.nextSub++
+	l.subs[id] = fn
+	return func() { delete(l.subs, id) }
+}
+
+func (l *changeLog[C]) modCheck(oldData any) (isModified bool, newData any) {
+	oldv, ok := oldData.(uint64)
+	return !ok || oldv != l.version, l.version
+}
+
+// SliceChange describes a modification of the elements [Index, Index+Count) of a Slice.
+// For ChangeRemove the range refers to the positions before removal.
+type SliceChange struct {
+	Kind  ChangeKind
+	Index int
+	Count int
+}
+
+// Slice is a slice of T which records each modification made through its methods.  It implements ModChecker
+// in constant time using a version number (unlike a plain slice, which ModTracker compares element by element),
+// and the changes themselves can be read with ChangesSince or received with OnChange.
+// Like other component state it is not safe for concurrent use, modify it with the EventEnv lock held.
+// The zero value is an empty Slice ready to use.
+type Slice[T any] struct {
+	items []T
+	log   changeLog[SliceChange]
+}
+
+// NewSlice returns a Slice with the items given.
+func NewSlice[T any](items ...T) *Slice[T] {
+	return &Slice[T]{items: items}
+}
+
+// Len returns the number of elements.
+func (s *Slice[T]) Len() int { return len(s.items) }
+
+// At returns the element at index i.
+func (s *Slice[T]) At(i int) T { return s.items[i] }
+
+// Items returns the elements, e.g. for use with vg-for.  The returned slice must not be modified.
+func (s *Slice[T]) Items() []T { return s.items }
+
+// Set replaces the element at index i.
+func (s *Slice[T]) Set(i int, v T) {
+	s.items[i] = v
+	s.log.add(SliceChange{Kind: ChangeUpdate, Index: i, Count: 1})
+}
+
+// Append adds elements to the end.
+func (s *Slice[T]) Append(v ...T) {
+	if len(v) == 0 {
+		return
+	}
+	i := len(s.items)
+	s.items = append(s.items, v...)
+	s.log.add(SliceChange{Kind: ChangeInsert, Index: i, Count: len(v)})
+}
+
+// Insert adds elements at index i, moving the element at i and those after it up.
+func (s *Slice[T]) Insert(i int, v ...T) {
+	if len(v) == 0 {
+		return
+	}
+	s.items = slices.Insert(s.items, i, v...)
+	s.log.add(SliceChange{Kind: ChangeInsert, Index: i, Count: len(v)})
+}
+
+// Remove removes count elements starting at index i.
+func (s *Slice[T]) Remove(i, count int) {
+	if count <= 0 {
+		return
+	}
+	s.items = slices.Delete(s.items, i, i+count)
+	s.log.add(SliceChange{Kind: ChangeRemove, Index: i, Count: count})
+}
+
+// Replace replaces all of the elements with items.
+func (s *Slice[T]) Replace(items []T) {
+	s.items = items
+	s.log.add(SliceChange{Kind: ChangeReset})
+}
+
+// Clear removes all elements.
+func (s *Slice[T]) Clear() {
+	s.Replace(nil)
+}
+
+// Version returns a number which is incremented on each modification.
+func (s *Slice[T]) Version() uint64 { return s.log.version }
+
+// ChangesSince returns the changes made after version (from an earlier call to Version), oldest first.
+// Only the most recent changes are kept; if some were discarded (or version is not valid)
+// false is returned and the caller should treat the Slice as entirely changed.
+func (s *Slice[T]) ChangesSince(version uint64) ([]SliceChange, bool) {
+	return s.log.since(version)
+}
+
+// OnChange calls fn after each modification, until the returned function is called.
+func (s *Slice[T]) OnChange(fn func(SliceChange)) (unsubscribe func()) {
+	return s.log.subscribe(fn)
+}
+
+// ModCheck implements the ModChecker interface.
+func (s *Slice[T]) ModCheck(mt *ModTracker, oldData any) (isModified bool, newData any) {
+	return s.log.modCheck(oldData)
+}
+
+// MapChange describes a modification of one key of a Map, Key is the zero value for ChangeReset.
+type MapChange[K comparable] struct {
+	Kind ChangeKind
+	Key  K
+}
+
+// Map is a map from K to V which records each modification made through its methods, in the same way as Slice.
+// Like a Go map its iteration order is not specified.  The zero value is an empty Map ready to use.
+type Map[K comparable, V any] struct {
+	m   map[K]V
+	log changeLog[MapChange[K]]
+}
+
+// NewMap returns a Map with the contents of m, which is used directly and must not be modified after.
+func NewMap[K comparable, V any](m map[K]V) *Map[K, V] {
+	return &Map[K, V]{m: m}
+}
+
+// Len returns the number of entries.
+func (m *Map[K, V]) Len() int { return len(m.m) }
+
+// Get returns the value for key and whether it is present.
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	v, ok := m.m[key]
+	return v, ok
+}
+
+// Keys returns the keys in an unspecified order.
+func (m *Map[K, V]) Keys() []K {
+	ret := make([]K, 0, len(m.m))
+	for k := range m.m {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+// Range calls fn for each entry in an unspecified order, until fn returns false.
+// The Map must not be modified by fn.
+func (m *Map[K, V]) Range(fn func(key K, value V) bool) {
+	for k, v := range m.m {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// Set sets the value for key, recorded as an insert if the key is new and an update otherwise.
+func (m *Map[K, V]) Set(key K, value V) {
+	if m.m == nil {
+		m.m = make(map[K]V)
+	}
+	_, exists := m.m[key]
+	m.m[key] = value
+	kind := ChangeInsert
+	if exists {
+		kind = ChangeUpdate
+	}
+	m.log.add(MapChange[K]{Kind: kind, Key: key})
+}
+
+// Delete removes key, if it is present.
+func (m *Map[K, V]) Delete(key K) {
+	if _, ok := m.m[key]; !ok {
+		return
+	}
+	delete(m.m, key)
+	m.log.add(MapChange[K]{Kind: ChangeRemove, Key: key})
+}
+
+// Replace replaces all entries with the contents of newMap, which is used directly and must not be modified after.
+func (m *Map[K, V]) Replace(newMap map[K]V) {
+	m.m = newMap
+	m.log.add(MapChange[K]{Kind: ChangeReset})
+}
+
+// Clear removes all entries.
+func (m *Map[K, V]) Clear() {
+	m.Replace(nil)
+}
+
+// Version returns a number which is incremented on each modification.
+func (m *Map[K, V]) Version() uint64 { return m.log.version }
+
+// ChangesSince returns the changes made after version, see Slice.ChangesSince.
+func (m *Map[K, V]) ChangesSince(version uint64) ([]MapChange[K], bool) {
+	return m.log.since(version)
+}
+
+// OnChange calls fn after each modification, until the returned function is called.
+func (m *Map[K, V]) OnChange(fn func(MapChange[K])) (unsubscribe func()) {
+	return m.log.subscribe(fn)
+}
+
+// ModCheck implements the ModChecker interface.
+func (m *Map[K, V]) ModCheck(mt *ModTracker, oldData any) (isModified bool, newData any) {
+	return m.log.modCheck(oldData)
+}
@@ -0,0 +1,102 @@
+package vugu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlice(t *testing.T) {
+
+	assert := assert.New(t)
+
+	s := NewSlice("a", "b")
+	var seen []SliceChange
+	unsub := s.OnChange(func(c SliceChange) { seen = append(seen, c) })
+
+	v := s.Version()
+	s.Append("c", "d")
+	s.Insert(1, "x")
+	s.Set(0, "A")
+	s.Remove(2, 2)
+	assert.Equal([]string{"A", "x", "d"}, s.Items())
+
+	changes, ok := s.ChangesSince(v)
+	assert.True(ok)
+	exp := []SliceChange{
+		{Kind: ChangeInsert, Index: 2, Count: 2},
+		{Kind: ChangeInsert, Index: 1, Count: 1},
+		{Kind: ChangeUpdate, Index: 0, Count: 1},
+		{Kind: ChangeRemove, Index: 2, Count: 2},
+	}
+	assert.Equal(exp, changes)
+	assert.Equal(exp, seen)
+
+	unsub()
+	s.Clear()
+	assert.Len(seen, 4)
+	assert.Equal(0, s.Len())
+
+	// older changes are discarded
+	for i := 0; i < maxChangeLog+1; i++ {
+		s.Append("z")
+	}
+	_, ok = s.ChangesSince(v)
+	assert.False(ok)
+	changes, ok = s.ChangesSince(s.Version() - 1)
+	assert.True(ok)
+	assert.Equal([]SliceChange{{Kind: ChangeInsert, Index: maxChangeLog, Count: 1}}, changes)
+}
+
+func TestMap(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var m Map[string, int]
+	m.Set("a", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+	m.Delete("a")
+	m.Delete("missing")
+
+	v, ok := m.Get("b")
+	assert.True(ok)
+	assert.Equal(3, v)
+	assert.Equal([]string{"b"}, m.Keys())
+
+	changes, ok := m.ChangesSince(0)
+	assert.True(ok)
+	assert.Equal([]MapChange[string]{
+		{Kind: ChangeInsert, Key: "a"},
+		{Kind: ChangeUpdate, Key: "a"},
+		{Kind: ChangeInsert, Key: "b"},
+		{Kind: ChangeRemove, Key: "a"},
+	}, changes)
+}
+
+func TestObservableModCheck(t *testing.T) {
+
+	assert := assert.New(t)
+
+	type comp struct {
+		Items Slice[int]       `vugu:"data"`
+		ByID  Map[string, int] `vugu:"data"`
+	}
+	c := &comp{}
+
+	mt := NewModTracker()
+	assert.True(mt.ModCheckAll(c))
+
+	mt.TrackNext()
+	assert.False(mt.ModCheckAll(c))
+
+	mt.TrackNext()
+	c.Items.Append(1)
+	assert.True(mt.ModCheckAll(&c.Items))
+	assert.False(mt.ModCheckAll(&c.ByID))
+
+	mt.TrackNext()
+	c.ByID.Set("x", 1)
+	assert.False(mt.ModCheckAll(&c.Items))
+	assert.True(mt.ModCheckAll(c))
+}
@@ -0,0 +1,60 @@
+package vugu
+
+// Provide makes val available to the components below the current one in the tree,
+// they retrieve it with Inject using the same type T.  It should be called from Build:
+//
+//	func (c *Root) Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {
+//		vugu.Provide[*api.Client](vgin, c.Client)
+//		...
+//
+// Values are looked up by type, so use an interface type or a type specific to the value
+// (e.g. `type Theme string`) rather than a general one like string or int.
+// A value provided again for the same type further down the tree overrides it for that subtree.
+// Values provided by a memoized component (see ModChecker) continue to be provided to its subtree
+// when its Build is skipped.
+//
+// Unlike SetWireFunc, which applies to every component when it is created, provided values
+// depend on the position in the tree and are looked up during each build.
+func Provide[T any](vgin *BuildIn, val T) {
+	vgin.provide(providerKey[T](), val)
+}
+
+// Inject returns the value of type T provided by the nearest component above the current one, see Provide.
+// If no value has been provided ok is false and the zero value of T is returned.
+func Inject[T any](vgin *BuildIn) (val T, ok bool) {
+	v, ok := vgin.lookup(providerKey[T]())
+	if !ok {
+		return val, false
+	}
+	// a nil interface value is provided as nil, which does not assert to T
+	val, _ = v.(T)
+	return val, true
+}
+
+// providerKey returns a distinct comparable value for each type, a nil *T compares
+// equal to other nil *Ts but not to nil pointers of other types
+func providerKey[T any]() any {
+	return (*T)(nil)
+}
+
+type providedVal struct {
+	key any
+	val any
+}
+
+func (bi *BuildIn) provide(key, val any) {
+	bi.provided = append(bi.provided, providedVal{key: key, val: val})
+	if e := bi.BuildEnv; e != nil && e.building != nil {
+		k := makeBuildCacheKey(e.building)
+		e.provides[k] = append(e.provides[k], providedVal{key: key, val: val})
+	}
+}
+
+func (bi *BuildIn) lookup(key any) (any, bool) {
+	for i := len(bi.provided) - 1; i >= 0; i-- {
+		if bi.provided[i].key == key {
+			return bi.provided[i].val, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,106 @@
+package vugu
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTheme string
+
+type testService interface{ Name() string }
+
+type testServiceImpl struct{}
+
+func (testServiceImpl) Name() string { return "svc" }
+
+// provideComp provides its values (if set) and records what it can inject
+type provideComp struct {
+	theme    testTheme
+	service  testService
+	children []Builder
+
+	gotTheme   testTheme
+	gotService testService
+	gotOK      bool
+}
+
+func (c *provideComp) Build(in *BuildIn) (out *BuildOut) {
+	c.gotTheme, c.gotOK = Inject[testTheme](in)
+	c.gotService, _ = Inject[testService](in)
+	if c.theme != "" {
+		Provide(in, c.theme)
+	}
+	if c.service != nil {
+		Provide[testService](in, c.service)
+	}
+	return &BuildOut{Out: []*VGNode{{Type: ElementNode, Data: "div"}}, Components: c.children}
+}
+
+func TestProvideInject(t *testing.T) {
+
+	assert := assert.New(t)
+
+	leaf1 := &provideComp{}
+	leaf2 := &provideComp{}
+	leaf3 := &provideComp{}
+	mid := &provideComp{theme: "light", children: []Builder{leaf2}}
+	root := &provideComp{theme: "dark", service: testServiceImpl{}, children: []Builder{leaf1, mid, leaf3}}
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+	be.RunBuild(root)
+
+	assert.False(root.gotOK)
+	assert.Nil(root.gotService)
+
+	assert.Equal(testTheme("dark"), leaf1.gotTheme)
+	assert.Equal(testTheme("dark"), mid.gotTheme)
+	assert.Equal(testTheme("light"), leaf2.gotTheme) // overridden in mid's subtree
+	assert.Equal(testTheme("dark"), leaf3.gotTheme)  // but not for mid's siblings
+	assert.Equal("svc", leaf2.gotService.Name())
+
+	// a different type is a different value, even with the same underlying type
+	_, ok := Inject[string](&BuildIn{provided: []providedVal{{key: providerKey[testTheme](), val: testTheme("x")}}})
+	assert.False(ok)
+
+	// a nil interface value can be provided, and overrides one from further up
+	in := &BuildIn{}
+	Provide[error](in, errors.New("outer"))
+	Provide[error](in, nil)
+	v, ok := Inject[error](in)
+	assert.True(ok)
+	assert.Nil(v)
+
+	// the values are provided again on the next pass
+	leaf1.gotTheme, leaf2.gotTheme = "", ""
+	be.RunBuild(root)
+	assert.Equal(testTheme("dark"), leaf1.gotTheme)
+	assert.Equal(testTheme("light"), leaf2.gotTheme)
+}
+
+// modProvideComp is memoized, so its Build is skipped when not modified
+type modProvideComp struct {
+	ChangeCounter `vugu:"modcheck"`
+	provideComp
+}
+
+func TestProvideMemoized(t *testing.T) {
+
+	assert := assert.New(t)
+
+	leaf := &provideComp{}
+	root := &modProvideComp{provideComp: provideComp{theme: "dark", children: []Builder{leaf}}}
+
+	be, err := NewBuildEnv()
+	assert.NoError(err)
+	be.RunBuild(root)
+	assert.Equal(testTheme("dark"), leaf.gotTheme)
+
+	// root is not modified and reuses its BuildOut, the values are still provided
+	leaf.gotTheme = ""
+	res := be.RunBuild(root)
+	assert.True(res.Reused(root))
+	assert.Equal(testTheme("dark"), leaf.gotTheme)
+}
@@ -0,0 +1,247 @@
+package vugu
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ResourceOpts are options for a ResourceCache.
+type ResourceOpts struct {
+	// MaxAge is how long a result (or error) is used before it is fetched again.  It is checked when
+	// Resource.Load is called, i.e. during a build.  Zero means results are kept until Invalidate is called.
+	MaxAge time.Duration
+
+	// StaleWhileRevalidate keeps the expired result available from Resource.Data while it is fetched again,
+	// instead of clearing it.  Loading still returns true during the fetch.
+	StaleWhileRevalidate bool
+}
+
+// ResourceCache fetches values of type T by key K and caches the results.  It is shared by all Resources loading
+// the same kind of data (usually it is a package level variable), so identical requests from different components
+// are only made once and results are reused according to ResourceOpts.  The fetch function is called in its own
+// goroutine, its context is cancelled when no Resource is waiting for the result any more.
+type ResourceCache[K comparable, T any] struct {
+	fetch func(ctx context.Context, key K) (T, error)
+	opts  ResourceOpts
+
+	mu      sync.Mutex
+	entries map[K]*resourceEntry[T]
+}
+
+// resourceEntry is one fetch (in flight or done) for a key
+type resourceEntry[T any] struct {
+	done    chan struct{} // closed when the fetch is done
+	cancel  context.CancelFunc
+	waiters int // Resources using this entry, the fetch is cancelled if it drops to zero before done
+
+	// set before done is closed
+	val T
+	err error
+	at  time.Time
+}
+
+func (e *resourceEntry[T]) isDone() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewResourceCache returns a new ResourceCache which uses fetch to get the value for a key.
+func NewResourceCache[K comparable, T any](fetch func(ctx context.Context, key K) (T, error), opts ResourceOpts) *ResourceCache[K, T] {
+	return &ResourceCache[K, T]{
+		fetch:   fetch,
+		opts:    opts,
+		entries: make(map[K]*resourceEntry[T]),
+	}
+}
+
+// Invalidate removes the result for key, the next Resource.Load for it will fetch it again.
+func (c *ResourceCache[K, T]) Invalidate(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// InvalidateAll removes all results, see Invalidate.
+func (c *ResourceCache[K, T]) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.entries {
+		delete(c.entries, k)
+	}
+}
+
+// current returns true if e is still the entry to use for key, must be called with c.mu held
+func (c *ResourceCache[K, T]) current(key K, e *resourceEntry[T]) bool {
+	if c.entries[key] != e {
+		return false
+	}
+	return c.opts.MaxAge <= 0 || !e.isDone() || time.Since(e.at) < c.opts.MaxAge
+}
+
+// acquire returns the entry for key, starting a fetch if there is no current one
+func (c *ResourceCache[K, T]) acquire(key K) *resourceEntry[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entries[key]
+	if e == nil || !c.current(key, e) {
+		ctx, cancel := context.WithCancel(context.Background())
+		e = &resourceEntry[T]{done: make(chan struct{}), cancel: cancel}
+		c.entries[key] = e
+		go func() {
+			val, err := c.fetch(ctx, key)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			e.val, e.err, e.at = val, err, time.Now()
+			close(e.done)
+			cancel()
+		}()
+	}
+	e.waiters++
+	return e
+}
+
+// release is called when a Resource no longer uses e
+func (c *ResourceCache[K, T]) release(key K, e *resourceEntry[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.waiters--
+	if e.waiters > 0 || e.isDone() {
+		return
+	}
+	// nobody is waiting for it, stop the request
+	e.cancel()
+	if c.entries[key] == e {
+		delete(c.entries, key)
+	}
+}
+
+// Resource is the state of asynchronously loaded data for a component, declared as a field:
+//
+//	type UserPage struct {
+//		UserID string
+//		User   vugu.Resource[string, *User]
+//	}
+//
+//	var userCache = vugu.NewResourceCache(fetchUser, vugu.ResourceOpts{MaxAge: time.Minute})
+//
+//	func (c *UserPage) Compute(ctx vugu.ComputeCtx) { c.User.Load(ctx.EventEnv(), userCache, c.UserID) }
+//	func (c *UserPage) Destroy() { c.User.Close() }
+//
+// Templates then use c.User.Loading(), c.User.Data() and c.User.Err().  When the key changes the request
+// for the old key is cancelled (unless another Resource is also waiting for it) and when a result
+// arrives a re-render is requested through the EventEnv.  The zero value is ready to use.
+type Resource[K comparable, T any] struct {
+	mu      sync.Mutex
+	cache   *ResourceCache[K, T]
+	key     K
+	entry   *resourceEntry[T]
+	stop    chan struct{} // closed when entry is released
+	loading bool
+	data    T
+	err     error
+}
+
+// Load makes sure the Resource has (or is loading) the data for key from cache, it is meant to be called on
+// every build, usually from Compute.  It only does something if the key changed or the cached result
+// expired or was invalidated.  If the result is already in the cache it is available immediately.
+func (r *Resource[K, T]) Load(env EventEnv, cache *ResourceCache[K, T], key K) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sameKey := r.entry != nil && r.cache == cache && r.key == key
+	if sameKey {
+		cache.mu.Lock()
+		cur := cache.current(key, r.entry)
+		cache.mu.Unlock()
+		if cur {
+			return
+		}
+	}
+
+	keepStale := sameKey && cache.opts.StaleWhileRevalidate && r.err == nil && !r.loading
+	r.releaseLocked()
+
+	e := cache.acquire(key)
+	r.cache, r.key, r.entry = cache, key, e
+	if !keepStale {
+		var zero T
+		r.data, r.err = zero, nil
+	}
+
+	if e.isDone() {
+		r.data, r.err, r.loading = e.val, e.err, false
+		return
+	}
+
+	r.loading = true
+	stop := make(chan struct{})
+	r.stop = stop
+	go func() {
+		select {
+		case <-e.done:
+		case <-stop:
+			return
+		}
+		r.mu.Lock()
+		if r.entry != e {
+			r.mu.Unlock()
+			return
+		}
+		r.data, r.err, r.loading = e.val, e.err, false
+		r.mu.Unlock()
+		if env != nil {
+			env.Lock()
+			env.UnlockRender()
+		}
+	}()
+}
+
+// Close releases the Resource's request, cancelling it if no other Resource is waiting for it.
+// It should be called when the component is destroyed.  Data and Err are kept.
+func (r *Resource[K, T]) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releaseLocked()
+	r.loading = false
+}
+
+func (r *Resource[K, T]) releaseLocked() {
+	if r.entry == nil {
+		return
+	}
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	r.cache.release(r.key, r.entry)
+	r.entry = nil
+}
+
+// Loading returns true while the data is being fetched.
+func (r *Resource[K, T]) Loading() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loading
+}
+
+// Data returns the loaded data, or the zero value if it is still loading or there was an error
+// (see ResourceOpts.StaleWhileRevalidate for the exception).
+func (r *Resource[K, T]) Data() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.data
+}
+
+// Err returns the error from fetching the data, if any.
+func (r *Resource[K, T]) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
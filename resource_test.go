@@ -0,0 +1,140 @@
+package vugu
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResource(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var rwmu sync.RWMutex
+	renderCH := make(chan bool, 8)
+	env := NewEventEnvImpl(&rwmu, renderCH)
+
+	var fetches int32
+	release := make(chan struct{})
+	cancelled := make(chan string, 8)
+	cache := NewResourceCache(func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		select {
+		case <-release:
+			return "data:" + key, nil
+		case <-ctx.Done():
+			cancelled <- key
+			return "", ctx.Err()
+		}
+	}, ResourceOpts{})
+
+	// two resources with the same key share one request
+	var r1, r2 Resource[string, string]
+	r1.Load(env, cache, "a")
+	r2.Load(env, cache, "a")
+	assert.True(r1.Loading())
+	assert.True(r2.Loading())
+	assert.Equal("", r1.Data())
+
+	close(release)
+	<-renderCH
+	<-renderCH
+	assert.False(r1.Loading())
+	assert.Equal("data:a", r1.Data())
+	assert.Equal("data:a", r2.Data())
+	assert.NoError(r2.Err())
+	assert.EqualValues(1, atomic.LoadInt32(&fetches))
+
+	// loading again is a no-op and a new resource gets the cached result immediately
+	r1.Load(env, cache, "a")
+	var r3 Resource[string, string]
+	r3.Load(env, cache, "a")
+	assert.False(r3.Loading())
+	assert.Equal("data:a", r3.Data())
+	assert.EqualValues(1, atomic.LoadInt32(&fetches))
+
+	// invalidate fetches again
+	cache.Invalidate("a")
+	r3.Load(env, cache, "a")
+	<-renderCH
+	assert.Equal("data:a", r3.Data())
+	assert.EqualValues(2, atomic.LoadInt32(&fetches))
+}
+
+func TestResourceCancel(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var rwmu sync.RWMutex
+	renderCH := make(chan bool, 8)
+	env := NewEventEnvImpl(&rwmu, renderCH)
+
+	cancelled := make(chan string, 8)
+	cache := NewResourceCache(func(ctx context.Context, key string) (int, error) {
+		if key == "slow" {
+			<-ctx.Done()
+			cancelled <- key
+			return 0, ctx.Err()
+		}
+		return len(key), nil
+	}, ResourceOpts{})
+
+	// changing the key cancels the old request
+	var r Resource[string, int]
+	r.Load(env, cache, "slow")
+	assert.True(r.Loading())
+	r.Load(env, cache, "fast")
+	assert.Equal("slow", <-cancelled)
+	<-renderCH
+	assert.False(r.Loading())
+	assert.Equal(4, r.Data())
+
+	// but not while another resource still waits for it
+	var r2, r3 Resource[string, int]
+	r2.Load(env, cache, "slow")
+	r3.Load(env, cache, "slow")
+	r2.Close()
+	select {
+	case <-cancelled:
+		t.Fatal("request cancelled while still in use")
+	case <-time.After(10 * time.Millisecond):
+	}
+	r3.Close()
+	assert.Equal("slow", <-cancelled)
+}
+
+func TestResourceStale(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var rwmu sync.RWMutex
+	renderCH := make(chan bool, 8)
+	env := NewEventEnvImpl(&rwmu, renderCH)
+
+	var n int32
+	release := make(chan struct{}, 8)
+	cache := NewResourceCache(func(ctx context.Context, key string) (int32, error) {
+		<-release
+		return atomic.AddInt32(&n, 1), nil
+	}, ResourceOpts{MaxAge: time.Millisecond, StaleWhileRevalidate: true})
+
+	var r Resource[string, int32]
+	r.Load(env, cache, "k")
+	release <- struct{}{}
+	<-renderCH
+	assert.EqualValues(1, r.Data())
+
+	// once expired it is fetched again, with the old data still available
+	time.Sleep(5 * time.Millisecond)
+	r.Load(env, cache, "k")
+	assert.True(r.Loading())
+	assert.EqualValues(1, r.Data())
+	release <- struct{}{}
+	<-renderCH
+	assert.False(r.Loading())
+	assert.EqualValues(2, r.Data())
+}
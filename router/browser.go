@@ -0,0 +1,70 @@
+package router
+
+import (
+	"errors"
+
+	js "github.com/vugu/vugu/js"
+)
+
+// ErrNoBrowser is returned by methods which need the browser's window when it is not available.
+var ErrNoBrowser = errors.New("router: browser window not available")
+
+type popStateListener struct {
+	f      js.Func
+	active bool
+}
+
+func browserAvailable() bool {
+	return js.Global().Truthy() && js.Global().Get("window").Truthy()
+}
+
+// Pull reads the current browser location and processes it (see Process).
+// Call it once at startup after the routes have been added.
+func (r *Router) Pull() error {
+	if !browserAvailable() {
+		return ErrNoBrowser
+	}
+	loc := js.Global().Get("window").Get("location")
+	p, q := r.pathFromBrowser(loc.Get("pathname").String(), loc.Get("search").String(), loc.Get("hash").String())
+	r.Process(p, q)
+	return nil
+}
+
+// ListenForPopState registers a listener for the browser's popstate event (back/forward navigation),
+// which locks the EventEnv, processes the new location and requests a re-render.
+func (r *Router) ListenForPopState() error {
+	if !browserAvailable() {
+		return ErrNoBrowser
+	}
+	if r.popState.active {
+		return nil
+	}
+	r.popState.f = js.FuncOf(func(this js.Value, args []js.Value) any {
+		r.eventEnv.Lock()
+		defer r.eventEnv.UnlockRender()
+		_ = r.Pull()
+		return nil
+	})
+	js.Global().Get("window").Call("addEventListener", "popstate", r.popState.f)
+	r.popState.active = true
+	return nil
+}
+
+// UnlistenForPopState removes the listener added by ListenForPopState.
+func (r *Router) UnlistenForPopState() {
+	if !r.popState.active {
+		return
+	}
+	js.Global().Get("window").Call("removeEventListener", "popstate", r.popState.f)
+	r.popState.f.Release()
+	r.popState.active = false
+}
+
+func (r *Router) pushHistory(u string, replace bool) {
+	h := js.Global().Get("window").Get("history")
+	if replace {
+		h.Call("replaceState", nil, "", u)
+	} else {
+		h.Call("pushState", nil, "", u)
+	}
+}
@@ -0,0 +1,31 @@
+package router
+
+import "net/url"
+
+// NavigatorOpt is an option for Navigator.Navigate.
+type NavigatorOpt int
+
+// NavReplace replaces the current history entry instead of pushing a new one.
+const NavReplace NavigatorOpt = 1
+
+// Navigator is implemented by things that can navigate to a path, such as Router.
+type Navigator interface {
+	Navigate(path string, query url.Values, opts ...NavigatorOpt)
+}
+
+// NavigatorSetter is implemented by components which want a Navigator.
+// Router.Wire calls NavigatorSet on each component that implements it.
+type NavigatorSetter interface {
+	NavigatorSet(v Navigator)
+}
+
+// NavigatorRef can be embedded in a component to have it wired with a Navigator.
+// Then call c.Navigate(...) from an event handler.
+type NavigatorRef struct {
+	Navigator
+}
+
+// NavigatorSet implements NavigatorSetter.
+func (nr *NavigatorRef) NavigatorSet(v Navigator) {
+	nr.Navigator = v
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pattern is a parsed route pattern, each segment is either a literal,
+// a parameter (":name") or a wildcard ("*name", last segment only).
+type pattern struct {
+	raw  string
+	segs []string
+}
+
+func parsePattern(p string) (pattern, error) {
+
+	if !strings.HasPrefix(p, "/") {
+		return pattern{}, fmt.Errorf("router: pattern %q must start with a slash", p)
+	}
+
+	ret := pattern{raw: p}
+	if p == "/" {
+		return ret, nil
+	}
+
+	names := make(map[string]bool)
+	ret.segs = strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i, seg := range ret.segs {
+		if seg == "" {
+			return pattern{}, fmt.Errorf("router: pattern %q has an empty segment", p)
+		}
+		if seg[0] != ':' && seg[0] != '*' {
+			continue
+		}
+		if seg[0] == '*' && i != len(ret.segs)-1 {
+			return pattern{}, fmt.Errorf("router: pattern %q has a wildcard which is not the last segment", p)
+		}
+		name := seg[1:]
+		if name == "" {
+			return pattern{}, fmt.Errorf("router: pattern %q has a parameter with no name", p)
+		}
+		if names[name] {
+			return pattern{}, fmt.Errorf("router: pattern %q has duplicate parameter %q", p, name)
+		}
+		names[name] = true
+	}
+
+	return ret, nil
+}
+
+// isStatic returns true if the pattern has no parameters or wildcards.
+func (pt pattern) isStatic() bool {
+	for _, seg := range pt.segs {
+		if seg[0] == ':' || seg[0] == '*' {
+			return false
+		}
+	}
+	return true
+}
+
+// match checks the (clean) path against the pattern and returns the parameters if it matches.
+func (pt pattern) match(p string) (map[string]string, bool) {
+
+	params := make(map[string]string)
+
+	var psegs []string
+	if p != "/" {
+		psegs = strings.Split(strings.TrimPrefix(p, "/"), "/")
+	}
+
+	for i, seg := range pt.segs {
+		if seg[0] == '*' {
+			params[seg[1:]] = strings.Join(psegs[i:], "/")
+			return params, true
+		}
+		if i >= len(psegs) {
+			return nil, false
+		}
+		if seg[0] == ':' {
+			params[seg[1:]] = psegs[i]
+			continue
+		}
+		if seg != psegs[i] {
+			return nil, false
+		}
+	}
+
+	if len(psegs) != len(pt.segs) {
+		return nil, false
+	}
+
+	return params, true
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternMatch(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		path    string
+		ok      bool
+		params  map[string]string
+	}{
+		{"/", "/", true, map[string]string{}},
+		{"/", "/a", false, nil},
+		{"/a", "/", false, nil},
+		{"/a/b", "/a/b", true, map[string]string{}},
+		{"/a/b", "/a/c", false, nil},
+		{"/a/b", "/a/b/c", false, nil},
+		{"/users/:id", "/users/10", true, map[string]string{"id": "10"}},
+		{"/users/:id", "/users", false, nil},
+		{"/users/:id/posts/:post", "/users/10/posts/x", true, map[string]string{"id": "10", "post": "x"}},
+		{"/files/*path", "/files/a/b/c.txt", true, map[string]string{"path": "a/b/c.txt"}},
+		{"/files/*path", "/files", true, map[string]string{"path": ""}},
+		{"/files/*path", "/other/a", false, nil},
+	}
+
+	for _, tc := range tests {
+		pt, err := parsePattern(tc.pattern)
+		assert.NoError(t, err, tc.pattern)
+		params, ok := pt.match(tc.path)
+		assert.Equal(t, tc.ok, ok, "%s %s", tc.pattern, tc.path)
+		assert.Equal(t, tc.params, params, "%s %s", tc.pattern, tc.path)
+	}
+}
+
+func TestParsePatternError(t *testing.T) {
+	for _, p := range []string{"", "a", "/a//b", "/*rest/a", "/:", "/:a/:a"} {
+		_, err := parsePattern(p)
+		assert.Error(t, err, p)
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Package router provides client-side routing for Vugu programs.
+
+A Router maps URL paths to handlers.  Paths are matched against patterns
+which may contain named parameters (":name", matching exactly one path segment)
+and a trailing wildcard ("*name", matching the rest of the path), e.g.
+"/users/:id" or "/files/*path".  Routes are tried in the order they were added
+and the first match wins.
+
+In the browser the router uses the History API: Navigate pushes (or replaces)
+a history entry and ListenForPopState picks up back/forward navigation.
+Outside the browser (e.g. for static rendering and tests) Navigate simply
+processes the path.
+
+The usual setup is to have a route select the component to display in
+the body of your root component with ComponentFunc, and to wire
+the router into your components with BuildEnv.SetWireFunc so anything
+which embeds NavigatorRef can navigate:
+
+	r := router.New(renderer.EventEnv())
+	buildEnv.SetWireFunc(r.Wire)
+	r.MustAddRoute("/", router.ComponentFunc(func(rm *router.RouteMatch) vugu.Builder { return &Home{} }))
+	r.MustAddRoute("/users/:id", router.ComponentFunc(func(rm *router.RouteMatch) vugu.Builder {
+		return &User{ID: rm.Param("id")}
+	}))
+	r.SetNotFound(router.ComponentFunc(func(rm *router.RouteMatch) vugu.Builder { return &NotFound{} }))
+	err := r.ListenForPopState()
+	...
+	err = r.Pull()
+
+And in root.vugu:
+
+	<div><vg-comp expr="c.Router.Component()"/></div>
+
+Since the generated code calls BuildEnv.WireComponent on each component it creates,
+nested components are wired automatically.
+*/
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/vugu/vugu"
+)
+
+// RouteHandler is implemented by things that can handle a route match.
+type RouteHandler interface {
+	RouteHandle(rm *RouteMatch)
+}
+
+// RouteHandlerFunc implements RouteHandler as a function.
+type RouteHandlerFunc func(rm *RouteMatch)
+
+// RouteHandle implements RouteHandler.
+func (f RouteHandlerFunc) RouteHandle(rm *RouteMatch) { f(rm) }
+
+// ComponentFunc is a RouteHandler which selects the component returned by the function
+// as the router's current component (see Router.Component).  The component is wired
+// with Router.Wire before it is made current.
+type ComponentFunc func(rm *RouteMatch) vugu.Builder
+
+// RouteHandle implements RouteHandler.
+func (f ComponentFunc) RouteHandle(rm *RouteMatch) {
+	c := f(rm)
+	if rm.router != nil {
+		rm.router.Wire(c)
+		rm.router.component = c
+	}
+}
+
+// RouteMatch describes a path that was matched against a route.
+type RouteMatch struct {
+	Path     string            // the path that was matched (without any prefix or query)
+	Pattern  string            // the pattern of the route that matched, empty if not found
+	Params   map[string]string // parameters extracted from the path
+	Query    url.Values        // parsed query string
+	NotFound bool              // true if no route matched and the not found handler is being called
+
+	router *Router
+}
+
+// Param returns the value of the named parameter, or an empty string if not present.
+func (rm *RouteMatch) Param(name string) string {
+	return rm.Params[name]
+}
+
+type route struct {
+	pattern pattern
+	handler RouteHandler
+}
+
+// Router maps paths to handlers and keeps the browser location in sync.
+// Create it with New.
+type Router struct {
+	eventEnv vugu.EventEnv
+
+	routes   []route
+	notFound RouteHandler
+
+	useFragment bool
+	pathPrefix  string
+
+	component vugu.Builder
+	lastMatch *RouteMatch
+
+	popState popStateListener
+}
+
+// New returns a new Router.  The EventEnv is used to lock around route processing
+// caused by browser navigation (back/forward) and request a re-render afterward.
+func New(eventEnv vugu.EventEnv) *Router {
+	return &Router{
+		eventEnv: eventEnv,
+	}
+}
+
+// SetUseFragment sets whether the path is kept in the fragment (the part after "#")
+// instead of in the URL path.  This is useful when the server cannot be configured
+// to serve the application for every path.
+func (r *Router) SetUseFragment(v bool) {
+	r.useFragment = v
+}
+
+// SetPathPrefix sets a prefix which is removed from the browser path before matching
+// and added back when navigating, e.g. "/app".  Not used in fragment mode.
+func (r *Router) SetPathPrefix(pfx string) {
+	r.pathPrefix = strings.TrimSuffix(pfx, "/")
+}
+
+// AddRoute adds a route with the specified pattern.
+func (r *Router) AddRoute(p string, h RouteHandler) error {
+	pt, err := parsePattern(p)
+	if err != nil {
+		return err
+	}
+	r.routes = append(r.routes, route{pattern: pt, handler: h})
+	return nil
+}
+
+// MustAddRoute is like AddRoute but panics on error.
+func (r *Router) MustAddRoute(p string, h RouteHandler) {
+	err := r.AddRoute(p, h)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetNotFound sets the handler which is called when no route matches.
+func (r *Router) SetNotFound(h RouteHandler) {
+	r.notFound = h
+}
+
+// Component returns the component selected by the last ComponentFunc route that matched.
+func (r *Router) Component() vugu.Builder {
+	return r.component
+}
+
+// LastMatch returns the RouteMatch from the most recently processed path, or nil.
+func (r *Router) LastMatch() *RouteMatch {
+	return r.lastMatch
+}
+
+// StaticPaths returns the patterns of all routes which do not contain parameters or wildcards,
+// i.e. the ones which can be rendered ahead of time without further information.
+func (r *Router) StaticPaths() []string {
+	var ret []string
+	for _, rt := range r.routes {
+		if rt.pattern.isStatic() {
+			ret = append(ret, rt.pattern.raw)
+		}
+	}
+	return ret
+}
+
+// Wire sets the router as the Navigator on components which implement NavigatorSetter.
+// It is intended to be passed to BuildEnv.SetWireFunc.
+func (r *Router) Wire(c vugu.Builder) {
+	if ns, ok := c.(NavigatorSetter); ok {
+		ns.NavigatorSet(r)
+	}
+}
+
+// Navigate implements Navigator.  In the browser a history entry is pushed (or replaced
+// with NavReplace), then the path is processed.  Navigate does not lock the EventEnv,
+// it is expected to be called from an event handler or with the lock already held.
+func (r *Router) Navigate(p string, query url.Values, opts ...NavigatorOpt) {
+
+	replace := false
+	for _, o := range opts {
+		if o == NavReplace {
+			replace = true
+		}
+	}
+
+	p = cleanPath(p)
+
+	if browserAvailable() {
+		r.pushHistory(r.browserURL(p, query), replace)
+	}
+
+	r.Process(p, query)
+}
+
+// Process matches the path against the routes and calls the handler for the first one that matches
+// (or the not found handler).  It does not modify the browser location.  It returns false
+// if no route matched.
+func (r *Router) Process(p string, query url.Values) bool {
+
+	p = cleanPath(p)
+	if query == nil {
+		query = url.Values{}
+	}
+
+	for _, rt := range r.routes {
+		params, ok := rt.pattern.match(p)
+		if !ok {
+			continue
+		}
+		rm := &RouteMatch{Path: p, Pattern: rt.pattern.raw, Params: params, Query: query, router: r}
+		r.lastMatch = rm
+		rt.handler.RouteHandle(rm)
+		return true
+	}
+
+	rm := &RouteMatch{Path: p, Params: map[string]string{}, Query: query, NotFound: true, router: r}
+	r.lastMatch = rm
+	r.component = nil
+	if r.notFound != nil {
+		r.notFound.RouteHandle(rm)
+	}
+	return false
+}
+
+// ProcessURL is like Process but takes a URL string (path plus optional query, e.g. "/a?b=c").
+// The path prefix and fragment mode are not taken into account.
+func (r *Router) ProcessURL(u string) (bool, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return false, fmt.Errorf("router: unable to parse URL %q: %w", u, err)
+	}
+	return r.Process(pu.Path, pu.Query()), nil
+}
+
+// browserURL returns the URL to put in the browser history for the path and query.
+func (r *Router) browserURL(p string, query url.Values) string {
+	var qs string
+	if len(query) > 0 {
+		qs = "?" + query.Encode()
+	}
+	if r.useFragment {
+		return "#" + p + qs
+	}
+	return r.pathPrefix + p + qs
+}
+
+// pathFromBrowser converts the browser location parts to the path and query to be matched.
+func (r *Router) pathFromBrowser(pathname, search, hash string) (string, url.Values) {
+	if r.useFragment {
+		frag := strings.TrimPrefix(hash, "#")
+		fp, fq, _ := strings.Cut(frag, "?")
+		q, _ := url.ParseQuery(fq)
+		return cleanPath(fp), q
+	}
+	q, _ := url.ParseQuery(strings.TrimPrefix(search, "?"))
+	if r.pathPrefix != "" && (pathname == r.pathPrefix || strings.HasPrefix(pathname, r.pathPrefix+"/")) {
+		pathname = strings.TrimPrefix(pathname, r.pathPrefix)
+	}
+	return cleanPath(pathname), q
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
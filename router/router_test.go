@@ -0,0 +1,118 @@
+package router
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/staticrender"
+)
+
+type testPage struct {
+	NavigatorRef
+	name string
+}
+
+func (c *testPage) Build(in *vugu.BuildIn) *vugu.BuildOut {
+	n := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+	n.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: c.name})
+	return &vugu.BuildOut{Out: []*vugu.VGNode{n}}
+}
+
+func newTestRouter() *Router {
+	r := New(nil)
+	r.MustAddRoute("/", ComponentFunc(func(rm *RouteMatch) vugu.Builder { return &testPage{name: "home"} }))
+	r.MustAddRoute("/about", ComponentFunc(func(rm *RouteMatch) vugu.Builder { return &testPage{name: "about"} }))
+	r.MustAddRoute("/users/:id", ComponentFunc(func(rm *RouteMatch) vugu.Builder {
+		return &testPage{name: "user " + rm.Param("id") + " " + rm.Query.Get("tab")}
+	}))
+	r.SetNotFound(ComponentFunc(func(rm *RouteMatch) vugu.Builder { return &testPage{name: "not found"} }))
+	return r
+}
+
+func TestRouterNavigate(t *testing.T) {
+
+	assert := assert.New(t)
+
+	r := newTestRouter()
+
+	r.Navigate("/users/5", url.Values{"tab": {"posts"}})
+	assert.Equal("user 5 posts", r.Component().(*testPage).name)
+	assert.Equal("/users/:id", r.LastMatch().Pattern)
+
+	// component is wired with the router
+	assert.Equal(r, r.Component().(*testPage).Navigator)
+
+	// navigating from a component
+	r.Component().(*testPage).Navigate("/about", nil)
+	assert.Equal("about", r.Component().(*testPage).name)
+
+	r.Navigate("/nope", nil, NavReplace)
+	assert.Equal("not found", r.Component().(*testPage).name)
+	assert.True(r.LastMatch().NotFound)
+
+	assert.Equal([]string{"/", "/about"}, r.StaticPaths())
+}
+
+func TestRouterBrowserPath(t *testing.T) {
+
+	assert := assert.New(t)
+
+	r := New(nil)
+	r.SetPathPrefix("/app/")
+	p, q := r.pathFromBrowser("/app/users/1", "?a=b", "")
+	assert.Equal("/users/1", p)
+	assert.Equal("b", q.Get("a"))
+	assert.Equal("/app/users/1?a=b", r.browserURL(p, q))
+
+	r.SetUseFragment(true)
+	p, q = r.pathFromBrowser("/index.html", "", "#/users/2?x=y")
+	assert.Equal("/users/2", p)
+	assert.Equal("y", q.Get("x"))
+	assert.Equal("#/users/2?x=y", r.browserURL(p, q))
+}
+
+type testRoot struct {
+	r *Router
+}
+
+func (c *testRoot) Build(in *vugu.BuildIn) *vugu.BuildOut {
+	n := &vugu.VGNode{Type: vugu.ElementNode, Data: "main"}
+	var comps []vugu.Builder
+	if comp := c.r.Component(); comp != nil {
+		n.AppendChild(&vugu.VGNode{Component: comp})
+		comps = append(comps, comp)
+	}
+	return &vugu.BuildOut{Out: []*vugu.VGNode{n}, Components: comps}
+}
+
+func TestRouterRenderStatic(t *testing.T) {
+
+	assert := assert.New(t)
+
+	r := newTestRouter()
+	buildEnv, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+	buildEnv.SetWireFunc(r.Wire)
+
+	outDir := t.TempDir()
+	err = r.RenderStatic(buildEnv, &testRoot{r: r}, staticrender.New(nil), outDir, append(r.StaticPaths(), "/users/7")...)
+	assert.NoError(err)
+
+	for fpath, want := range map[string]string{
+		"index.html":         "<main><div>home</div></main>",
+		"about/index.html":   "<main><div>about</div></main>",
+		"users/7/index.html": "<main><div>user 7 </div></main>",
+	} {
+		b, err := os.ReadFile(filepath.Join(outDir, fpath))
+		assert.NoError(err)
+		assert.Contains(string(b), want, fpath)
+	}
+
+	err = r.RenderStatic(buildEnv, &testRoot{r: r}, staticrender.New(nil), outDir, "/nope")
+	assert.Error(err)
+}
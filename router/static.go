@@ -0,0 +1,58 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/staticrender"
+)
+
+// RenderStatic pre-renders each path to an index.html file under outDir, e.g. "/" becomes
+// outDir/index.html and "/about" becomes outDir/about/index.html.  If no paths are given
+// then StaticPaths is used.  For each path the router processes it, the root is built with
+// buildEnv and the result rendered with renderer (its writer is replaced for each file).
+// Pass a renderer with hydration markers enabled if the output will be hydrated.
+func (r *Router) RenderStatic(buildEnv *vugu.BuildEnv, root vugu.Builder, renderer *staticrender.StaticRenderer, outDir string, paths ...string) error {
+
+	if len(paths) == 0 {
+		paths = r.StaticPaths()
+	}
+
+	for _, p := range paths {
+
+		ok, err := r.ProcessURL(p)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("router: no route matched %q", p)
+		}
+
+		fpath := filepath.Join(outDir, filepath.FromSlash(strings.TrimPrefix(cleanPath(r.lastMatch.Path), "/")), "index.html")
+		err = os.MkdirAll(filepath.Dir(fpath), 0755)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Create(fpath)
+		if err != nil {
+			return err
+		}
+
+		renderer.SetWriter(f)
+		err = renderer.Render(buildEnv.RunBuild(root))
+		cerr := f.Close()
+		if err != nil {
+			return fmt.Errorf("router: error rendering %q: %w", p, err)
+		}
+		// the output may be incomplete if the close failed
+		if cerr != nil {
+			return fmt.Errorf("router: error writing %q: %w", fpath, cerr)
+		}
+	}
+
+	return nil
+}
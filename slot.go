@@ -0,0 +1,30 @@
+package vugu
+
+// ScopedSlot returns the function for a scoped slot, a component field of type func(T) Builder which
+// the component calls with the data for each place it outputs the slot, e.g. a table with a field
+//
+//	Row func(row Person) vugu.Builder
+//
+// outputs each row with `<vg-comp expr="c.Row(p)"></vg-comp>`, and the caller provides the markup with
+//
+//	<main:Table :Rows="c.People">
+//	    <vg-slot name="Row" vg-let="row"><td vg-content="row.Name"></td></vg-slot>
+//	</main:Table>
+//
+// This is what the generated code for a vg-slot with vg-let uses: each Builder returned stores its value
+// in *arg before calling build (so build should copy it out first thing), and arg comes from SlotArg
+// so the type of the value is inferred from the field.
+func ScopedSlot[T any](arg *T, build func(vgin *BuildIn) (vgout *BuildOut)) func(T) Builder {
+	return func(v T) Builder {
+		return NewBuilderFunc(func(vgin *BuildIn) (vgout *BuildOut) {
+			*arg = v
+			return build(vgin)
+		})
+	}
+}
+
+// SlotArg returns a new *T for the parameter of a scoped slot field, see ScopedSlot.
+// The slot is only used for its type.
+func SlotArg[T any](slot func(T) Builder) *T {
+	return new(T)
+}
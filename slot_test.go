@@ -0,0 +1,25 @@
+package vugu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopedSlot(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var row func(name string) Builder
+	arg := SlotArg(row)
+	row = ScopedSlot(arg, func(vgin *BuildIn) (vgout *BuildOut) {
+		name := *arg
+		return &BuildOut{Out: []*VGNode{{Type: TextNode, Data: name}}}
+	})
+
+	// each Builder has its own value, regardless of the order they are built in
+	a, b := row("a"), row("b")
+	assert.Equal("b", b.Build(&BuildIn{}).Out[0].Data)
+	assert.Equal("a", a.Build(&BuildIn{}).Out[0].Data)
+	assert.Equal("b", b.Build(&BuildIn{}).Out[0].Data)
+}
@@ -0,0 +1,199 @@
+package staticrender
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vugu/html"
+	"github.com/vugu/html/atom"
+)
+
+// PortalAttr is the attribute on the element inside a portal's target which holds the portal's children,
+// the value is the positionID of the portal (the same as domrender.JSRenderer uses).
+const PortalAttr = "data-vg-portal"
+
+// portalComment is the content of the comment output in place of a portal
+const portalComment = "vg-portal"
+
+// staticPortal is a portal whose children are appended to its target after the rest of the output is rendered
+type staticPortal struct {
+	selector string
+	node     *html.Node
+}
+
+// newPortalNode returns the element which holds the children of a portal inside its target
+func newPortalNode(portalID string) *html.Node {
+	return &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+		Attr: []html.Attribute{
+			{Key: PortalAttr, Val: portalID},
+			{Key: "style", Val: "display: contents"},
+		},
+	}
+}
+
+// placePortals appends the children of each portal to the first element in root which matches its selector.
+// Portals are placed in the order they were found, so a portal may target an element inside an earlier one.
+func placePortals(root *html.Node, portals []*staticPortal) error {
+	for _, p := range portals {
+		sel, err := parseSimpleSelector(p.selector)
+		if err != nil {
+			return err
+		}
+		target := findNode(root, sel.match)
+		if target == nil {
+			return fmt.Errorf("portal target %q not found in output", p.selector)
+		}
+		target.AppendChild(p.node)
+	}
+	return nil
+}
+
+func findNode(n *html.Node, match func(n *html.Node) bool) *html.Node {
+	if match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if ret := findNode(c, match); ret != nil {
+			return ret
+		}
+	}
+	return nil
+}
+
+// simpleSelector is a single compound CSS selector, which is all that is supported for portal targets
+// during static rendering, e.g. "#modals" or "div.overlay[data-layer=top]"
+type simpleSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+}
+
+type attrSelector struct {
+	key    string
+	val    string
+	hasVal bool
+}
+
+func (s *simpleSelector) match(n *html.Node) bool {
+
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if s.tag != "" && s.tag != "*" && !strings.EqualFold(s.tag, n.Data) {
+		return false
+	}
+
+	attr := func(key string) (string, bool) {
+		for _, a := range n.Attr {
+			if a.Namespace == "" && a.Key == key {
+				return a.Val, true
+			}
+		}
+		return "", false
+	}
+
+	if s.id != "" {
+		if v, _ := attr("id"); v != s.id {
+			return false
+		}
+	}
+	if len(s.classes) > 0 {
+		v, _ := attr("class")
+		have := strings.Fields(v)
+	classLoop:
+		for _, c := range s.classes {
+			for _, h := range have {
+				if h == c {
+					continue classLoop
+				}
+			}
+			return false
+		}
+	}
+	for _, as := range s.attrs {
+		v, ok := attr(as.key)
+		if !ok || (as.hasVal && v != as.val) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSimpleSelector parses a selector like "tag#id.class[attr=val]"
+func parseSimpleSelector(sel string) (*simpleSelector, error) {
+
+	unsupported := func() (*simpleSelector, error) {
+		return nil, fmt.Errorf("portal target %q is not supported when rendering statically, use a simple selector like %q", sel, "#id")
+	}
+
+	s := strings.TrimSpace(sel)
+	if s == "" {
+		return unsupported()
+	}
+
+	isNameChar := func(c byte) bool {
+		return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+	}
+	readName := func(s string) (string, string) {
+		i := 0
+		for i < len(s) && isNameChar(s[i]) {
+			i++
+		}
+		return s[:i], s[i:]
+	}
+
+	ret := &simpleSelector{}
+
+	if s[0] == '*' {
+		ret.tag, s = "*", s[1:]
+	} else {
+		ret.tag, s = readName(s)
+	}
+
+	for len(s) > 0 {
+		var name string
+		switch s[0] {
+		case '#':
+			name, s = readName(s[1:])
+			if name == "" {
+				return unsupported()
+			}
+			ret.id = name
+		case '.':
+			name, s = readName(s[1:])
+			if name == "" {
+				return unsupported()
+			}
+			ret.classes = append(ret.classes, name)
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return unsupported()
+			}
+			inner := s[1:end]
+			s = s[end+1:]
+			var as attrSelector
+			if k, v, ok := strings.Cut(inner, "="); ok {
+				as.key, as.hasVal = strings.TrimSpace(k), true
+				as.val = strings.TrimSpace(v)
+				if len(as.val) >= 2 && (as.val[0] == '"' || as.val[0] == '\'') && as.val[len(as.val)-1] == as.val[0] {
+					as.val = as.val[1 : len(as.val)-1]
+				}
+			} else {
+				as.key = strings.TrimSpace(inner)
+			}
+			if k, rest := readName(as.key); k == "" || rest != "" {
+				return unsupported()
+			}
+			ret.attrs = append(ret.attrs, as)
+		default:
+			return unsupported()
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,42 @@
+package staticrender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vugu/html"
+)
+
+func TestParseSimpleSelector(t *testing.T) {
+
+	assert := assert.New(t)
+
+	n := &html.Node{Type: html.ElementNode, Data: "div", Attr: []html.Attribute{
+		{Key: "id", Val: "modals"},
+		{Key: "class", Val: "overlay top"},
+		{Key: "data-layer", Val: "1"},
+	}}
+
+	for sel, match := range map[string]bool{
+		"#modals":                     true,
+		"div#modals.top":              true,
+		"*.overlay.top":               true,
+		`[data-layer="1"]`:            true,
+		"div[data-layer]":             true,
+		"span#modals":                 false,
+		"#other":                      false,
+		".overlay.bottom":             false,
+		"[data-layer=2]":              false,
+		"DIV.overlay[data-layer='1']": true,
+	} {
+		s, err := parseSimpleSelector(sel)
+		if assert.NoError(err, sel) {
+			assert.Equal(match, s.match(n), sel)
+		}
+	}
+
+	for _, sel := range []string{"", "body #modals", "div > p", "#a, #b", "a:hover", "[data-x"} {
+		_, err := parseSimpleSelector(sel)
+		assert.Error(err, sel)
+	}
+}
@@ -3,6 +3,7 @@ package staticrender
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -27,18 +28,42 @@ func New(w io.Writer) *StaticRenderer {
 // StaticRenderer provides rendering as static HTML to an io.Writer.
 type StaticRenderer struct {
 	w io.Writer
+
+	hydrationMarkers bool
+
+	portals []*staticPortal // portals found during the render in progress
 }
 
+// PositionAttr is the attribute used to carry position markers when SetHydrationMarkers is enabled.
+// The value is the same positionID that domrender.JSRenderer uses for the corresponding element,
+// which allows a hydrating renderer to verify that the existing DOM matches what it is about to adopt.
+const PositionAttr = "data-vgpos"
+
 // SetWriter assigns the Writer to be used for subsequent calls to Render.
 // A Writer must be assigned before rendering (either with this method or in New).
 func (r *StaticRenderer) SetWriter(w io.Writer) {
 	r.w = w
 }
 
+// SetHydrationMarkers enables or disables the output of position markers (see PositionAttr)
+// on each element inside the mount point.  Enable this when the output will be picked up
+// by a domrender.JSRenderer with hydration enabled.
+func (r *StaticRenderer) SetHydrationMarkers(v bool) {
+	r.hydrationMarkers = v
+}
+
 // Render will perform a static render of the given BuildResults and write it to the writer assigned.
 func (r *StaticRenderer) Render(buildResults *vugu.BuildResults) error {
 
-	n, err := r.renderOne(buildResults, buildResults.Out)
+	r.portals = nil
+	defer func() { r.portals = nil }()
+
+	n, err := r.renderOne(buildResults, buildResults.Out, "0")
+	if err != nil {
+		return err
+	}
+
+	err = placePortals(n, r.portals)
 	if err != nil {
 		return err
 	}
@@ -52,7 +77,10 @@ func (r *StaticRenderer) Render(buildResults *vugu.BuildResults) error {
 
 }
 
-func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*html.Node, error) {
+// renderOne renders the output of a single component.  The positionID follows the same scheme as
+// domrender.JSRenderer (see visitSyncNode there) and is only used when hydration markers are enabled,
+// an empty positionID means that no markers should be written (e.g. for the contents of <head>).
+func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut, positionID string) (*html.Node, error) {
 
 	if len(bo.Out) != 1 {
 		return nil, fmt.Errorf("BuildOut must contain exactly one element in Out")
@@ -60,15 +88,15 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 
 	vgn := bo.Out[0]
 
-	var visit func(vgn *vugu.VGNode) ([]*html.Node, error)
-	visit = func(vgn *vugu.VGNode) ([]*html.Node, error) {
+	var visit func(vgn *vugu.VGNode, positionID string) ([]*html.Node, error)
+	visit = func(vgn *vugu.VGNode, positionID string) ([]*html.Node, error) {
 
 		// log.Printf("vgn: %#v", vgn)
 
 		// if component then look up BuildOut for it and call renderOne again and return
 		if vgn.Component != nil {
 			cbo := br.ResultFor(vgn.Component)
-			retn, err := r.renderOne(br, cbo)
+			retn, err := r.renderOne(br, cbo, positionID)
 			if err != nil {
 				return nil, err
 			}
@@ -79,15 +107,39 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 			// return retn[0], nil
 		}
 
+		// if portal then the children go in an element which is appended to the target once the rest is rendered
+		// (see placePortals), and a placeholder comment is output here, the same as domrender.JSRenderer
+		if vgn.IsPortal() {
+			if vgn.IsBlankPortal() {
+				return nil, fmt.Errorf("vg-portal at position %s has an empty target selector", positionID)
+			}
+			if vgn.FirstChild != nil {
+				pn := newPortalNode(positionID)
+				r.portals = append(r.portals, &staticPortal{selector: vgn.Portal, node: pn})
+				childIndex := 1
+				for vgchild := vgn.FirstChild; vgchild != nil; vgchild = vgchild.NextSibling {
+					nchildren, err := visit(vgchild, childPositionID(positionID, "_", childIndex))
+					if err != nil {
+						return nil, err
+					}
+					appendChildren(pn, nchildren)
+					childIndex++
+				}
+			}
+			return []*html.Node{{Type: html.CommentNode, Data: portalComment}}, nil
+		}
+
 		// if template then just traverse the children directly and return them in a series, omitting vgn
 		if vgn.IsTemplate() {
 			var retn []*html.Node
+			childIndex := 1
 			for vgchild := vgn.FirstChild; vgchild != nil; vgchild = vgchild.NextSibling {
-				nchildren, err := visit(vgchild)
+				nchildren, err := visit(vgchild, childPositionID(positionID, "_t_", childIndex))
 				if err != nil {
 					return nil, err
 				}
 				retn = append(retn, nchildren...)
+				childIndex++
 			}
 			return retn, nil
 		}
@@ -101,6 +153,24 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 			n.Attr = append(n.Attr, html.Attribute{Key: vgattr.Key, Val: vgattr.Val})
 		}
 
+		// the html, head and body tags are selected directly by the JSRenderer and get no marker,
+		// the single element inside body is the mount point and has the same position as body
+		childPosition := positionID
+		bodyChild := false
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "html", "head":
+				childPosition = ""
+			case "body":
+				childPosition = "body"
+				bodyChild = true
+			default:
+				if r.hydrationMarkers && positionID != "" {
+					n.Attr = append(n.Attr, html.Attribute{Key: PositionAttr, Val: positionID})
+				}
+			}
+		}
+
 		// handle InnerHTML
 
 		if vgn.InnerHTML != nil {
@@ -120,13 +190,19 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 		}
 
 		// handle children
+		childIndex := 1
 		for vgchild := vgn.FirstChild; vgchild != nil; vgchild = vgchild.NextSibling {
-			nchildren, err := visit(vgchild)
+			cpos := childPositionID(childPosition, "_", childIndex)
+			if bodyChild {
+				cpos = childPosition
+			}
+			nchildren, err := visit(vgchild, cpos)
 			if err != nil {
 				return nil, err
 			}
 			// n.AppendChild(nchildren)
 			appendChildren(n, nchildren)
+			childIndex++
 		}
 
 		// special case for <head>, we need to emit the CSS here as that is separate
@@ -136,7 +212,7 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 			for _, css := range bo.CSS {
 
 				// convert each one
-				nchildren, err := visit(css)
+				nchildren, err := visit(css, "")
 				if err != nil {
 					return nil, err
 				}
@@ -150,7 +226,7 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 			for _, js := range bo.JS {
 
 				// convert each one
-				nchildren, err := visit(js)
+				nchildren, err := visit(js, "")
 				if err != nil {
 					return nil, err
 				}
@@ -162,7 +238,7 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 		return []*html.Node{n}, nil
 	}
 
-	nret, err := visit(vgn)
+	nret, err := visit(vgn, positionID)
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +248,15 @@ func (r *StaticRenderer) renderOne(br *vugu.BuildResults, bo *vugu.BuildOut) (*h
 	return nret[0], nil
 }
 
+// childPositionID returns the positionID for a child of the node at positionID,
+// or an empty string if positionID is empty (no markers are being tracked there).
+func childPositionID(positionID, sep string, childIndex int) string {
+	if positionID == "" {
+		return ""
+	}
+	return positionID + sep + strconv.Itoa(childIndex)
+}
+
 func appendChildren(parent *html.Node, children []*html.Node) {
 	for _, c := range children {
 		parent.AppendChild(c)
@@ -100,6 +100,79 @@ comp1 in the house
 			},
 			outReNotMatch: []string{`vg-template`},
 		},
+		{
+			name:      "hydration-markers",
+			opts:      gen.ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu":  `<div id="root"><span>one</span><vg-template vg-if='true'><b>two</b></vg-template><main:Comp1/></div>`,
+				"comp1.vugu": `<p>comp1 <i>here</i></p>`,
+			},
+			bfiles: map[string]string{
+				"main.go": `// +build !wasm
+
+package main
+
+import (
+	"os"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/staticrender"
+)
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil { panic(err) }
+	renderer := staticrender.New(os.Stdout)
+	renderer.SetHydrationMarkers(true)
+	err = renderer.Render(buildEnv.RunBuild(&Root{}))
+	if err != nil { panic(err) }
+}
+`,
+			},
+			outReMatch: []string{
+				`<div id="root" data-vgpos="0">`,
+				`<span data-vgpos="0_1">one</span>`,
+				`<b data-vgpos="0_2_t_1">two</b>`,
+				`<p data-vgpos="0_3">comp1 <i data-vgpos="0_3_2">here</i></p>`,
+			},
+			outReNotMatch: []string{`vg-template`},
+		},
+		{
+			name:      "portal",
+			opts:      gen.ParserGoPkgOpts{},
+			recursive: false,
+			infiles: map[string]string{
+				"root.vugu": `<div id="root"><vg-portal target="#modals"><p>modal</p></vg-portal><span>after</span><div id="modals"></div></div>`,
+			},
+			bfiles: map[string]string{
+				"main.go": `// +build !wasm
+
+package main
+
+import (
+	"os"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/staticrender"
+)
+
+func main() {
+	buildEnv, err := vugu.NewBuildEnv()
+	if err != nil { panic(err) }
+	renderer := staticrender.New(os.Stdout)
+	renderer.SetHydrationMarkers(true)
+	err = renderer.Render(buildEnv.RunBuild(&Root{}))
+	if err != nil { panic(err) }
+}
+`,
+			},
+			outReMatch: []string{
+				`<div id="root" data-vgpos="0"><!--vg-portal--><span data-vgpos="0_2">after</span>`,
+				`<div id="modals" data-vgpos="0_3"><div data-vg-portal="0_1" style="display: contents"><p data-vgpos="0_1_1">modal</p></div></div>`,
+			},
+			outReNotMatch: []string{`vg-portal target`},
+		},
 		{
 			name:      "syscall-js",
 			opts:      gen.ParserGoPkgOpts{},
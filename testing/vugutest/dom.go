@@ -0,0 +1,487 @@
+package vugutest
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vugu/html"
+	"github.com/vugu/html/atom"
+	"github.com/vugu/vjson"
+
+	"github.com/vugu/vugu"
+	js "github.com/vugu/vugu/js"
+)
+
+// Node is an element, text or comment node in the in-memory DOM of a Harness.
+// Attributes are what the component output, Props are the JS properties set
+// during render (e.g. "value" and "checked") and are updated by the Harness
+// event helpers like Input.
+type Node struct {
+	Type      vugu.VGNodeType
+	Data      string // tag name for elements, the text for text and comment nodes
+	Namespace string
+	Attr      []vugu.VGAttribute
+	Props     map[string]any
+
+	Parent   *Node
+	Children []*Node
+
+	positionID string
+	handlers   []vugu.DOMEventHandlerSpec
+	ref        *js.Element
+
+	// for the DOM of a Window, see window.go
+	key        string      // the key assigned by opcodeMoveKeyed, "" for none
+	listeners  []*listener // the event listeners added to the element
+	delegated  []*listener // the listeners set by opcodeSetDelegatedEvents
+	cssCreated bool        // the style or link tag was created by opcodeSetCSSTag
+}
+
+// PositionID returns the position of the node in the tree, using the same scheme as domrender.
+func (n *Node) PositionID() string {
+	return n.positionID
+}
+
+// Handlers returns the DOM event handlers registered on this element.
+func (n *Node) Handlers() []vugu.DOMEventHandlerSpec {
+	return n.handlers
+}
+
+// AttrValue returns the value of the named attribute and whether it is present.
+func (n *Node) AttrValue(key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// ID returns the id attribute.
+func (n *Node) ID() string {
+	v, _ := n.AttrValue("id")
+	return v
+}
+
+// HasClass returns true if the class attribute contains c.
+func (n *Node) HasClass(c string) bool {
+	v, _ := n.AttrValue("class")
+	for _, f := range strings.Fields(v) {
+		if f == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Prop returns the value of a JS property set during render, or nil.
+func (n *Node) Prop(key string) any {
+	return n.Props[key]
+}
+
+// Value returns the value of a form element: the "value" property if set, otherwise the value attribute.
+// For a textarea the text content is used in place of the attribute.
+func (n *Node) Value() string {
+	if v, ok := n.Props["value"]; ok {
+		return fmt.Sprint(v)
+	}
+	if n.Data == "textarea" {
+		return n.Text()
+	}
+	v, _ := n.AttrValue("value")
+	return v
+}
+
+// Checked returns the checked state: the "checked" property if set, otherwise whether the checked attribute is present.
+func (n *Node) Checked() bool {
+	if v, ok := n.Props["checked"]; ok {
+		b, _ := v.(bool)
+		return b
+	}
+	_, ok := n.AttrValue("checked")
+	return ok
+}
+
+// Text returns the concatenated text content of the node and its descendants.
+func (n *Node) Text() string {
+	var buf strings.Builder
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Type == vugu.TextNode {
+			buf.WriteString(n.Data)
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return buf.String()
+}
+
+// HTML returns the outer HTML of the node.
+func (n *Node) HTML() string {
+	var buf bytes.Buffer
+	err := html.Render(&buf, n.htmlNode())
+	if err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
+
+// InnerHTML returns the HTML of the children of the node.
+func (n *Node) InnerHTML() string {
+	var buf bytes.Buffer
+	for _, c := range n.Children {
+		err := html.Render(&buf, c.htmlNode())
+		if err != nil {
+			panic(err)
+		}
+	}
+	return buf.String()
+}
+
+// Query returns the first descendant element matching the CSS selector, or nil if none.
+// It panics if the selector is invalid.
+func (n *Node) Query(selector string) *Node {
+	sel := mustParseSelector(selector)
+	var ret *Node
+	n.walkElements(func(c *Node) bool {
+		if sel.match(c) {
+			ret = c
+			return false
+		}
+		return true
+	})
+	return ret
+}
+
+// QueryAll returns all descendant elements matching the CSS selector, in document order.
+// It panics if the selector is invalid.
+func (n *Node) QueryAll(selector string) []*Node {
+	sel := mustParseSelector(selector)
+	var ret []*Node
+	n.walkElements(func(c *Node) bool {
+		if sel.match(c) {
+			ret = append(ret, c)
+		}
+		return true
+	})
+	return ret
+}
+
+// walkElements calls fn for each descendant element in document order, stopping when fn returns false
+func (n *Node) walkElements(fn func(*Node) bool) bool {
+	for _, c := range n.Children {
+		if c.Type != vugu.ElementNode {
+			continue
+		}
+		if !fn(c) {
+			return false
+		}
+		if !c.walkElements(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Node) byPositionID(positionID string) *Node {
+	var ret *Node
+	n.walkElements(func(c *Node) bool {
+		if c.positionID == positionID {
+			ret = c
+			return false
+		}
+		return true
+	})
+	return ret
+}
+
+func (n *Node) setProp(key string, val any) {
+	if n.Props == nil {
+		n.Props = make(map[string]any)
+	}
+	n.Props[key] = val
+}
+
+func (n *Node) appendChild(c *Node) {
+	if c.Parent != nil {
+		c.Parent.removeChild(c)
+	}
+	c.Parent = n
+	n.Children = append(n.Children, c)
+}
+
+// insertBefore inserts c before ref, or at the end if ref is nil, removing it from its parent first
+func (n *Node) insertBefore(c, ref *Node) {
+	if ref == nil {
+		n.appendChild(c)
+		return
+	}
+	if c.Parent != nil {
+		c.Parent.removeChild(c)
+	}
+	i := n.childIndex(ref)
+	n.Children = append(n.Children[:i], append([]*Node{c}, n.Children[i:]...)...)
+	c.Parent = n
+}
+
+func (n *Node) removeChild(c *Node) {
+	i := n.childIndex(c)
+	if i < 0 {
+		return
+	}
+	n.Children = append(n.Children[:i], n.Children[i+1:]...)
+	c.Parent = nil
+}
+
+func (n *Node) replaceChild(newChild, oldChild *Node) {
+	n.insertBefore(newChild, oldChild)
+	n.removeChild(oldChild)
+}
+
+func (n *Node) childIndex(c *Node) int {
+	for i, x := range n.Children {
+		if x == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *Node) firstChild() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[0]
+}
+
+func (n *Node) nextSibling() *Node {
+	if n.Parent == nil {
+		return nil
+	}
+	i := n.Parent.childIndex(n)
+	if i < 0 || i+1 >= len(n.Parent.Children) {
+		return nil
+	}
+	return n.Parent.Children[i+1]
+}
+
+// setAttr sets the attribute, replacing the value if it is already present
+func (n *Node) setAttr(key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, vugu.VGAttribute{Key: key, Val: val})
+}
+
+func (n *Node) removeAttr(key string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+			return
+		}
+	}
+}
+
+// addListener adds l if it is not already added, like addEventListener
+func (n *Node) addListener(l *listener) {
+	for _, x := range n.listeners {
+		if x == l {
+			return
+		}
+	}
+	n.listeners = append(n.listeners, l)
+}
+
+func (n *Node) removeListener(l *listener) {
+	for i, x := range n.listeners {
+		if x == l {
+			n.listeners = append(n.listeners[:i], n.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// htmlNode converts to an html.Node for rendering, properties are not included
+func (n *Node) htmlNode() *html.Node {
+	hn := &html.Node{
+		Type:      html.NodeType(n.Type), // type numbers are the same
+		Data:      n.Data,
+		DataAtom:  atom.Lookup([]byte(n.Data)),
+		Namespace: n.Namespace,
+	}
+	for _, a := range n.Attr {
+		hn.Attr = append(hn.Attr, html.Attribute{Namespace: a.Namespace, Key: a.Key, Val: a.Val})
+	}
+	for _, c := range n.Children {
+		hn.AppendChild(c.htmlNode())
+	}
+	return hn
+}
+
+// buildDOM converts the output of a build into a tree of Nodes under a document node
+func buildDOM(br *vugu.BuildResults) (*Node, error) {
+
+	if br.Out == nil || len(br.Out.Out) != 1 {
+		return nil, fmt.Errorf("vugutest: root BuildOut must contain exactly one element in Out")
+	}
+
+	doc := &Node{Type: vugu.DocumentNode}
+	var portals []portalNode
+	nodes, err := convertOne(br, br.Out, "0", &portals)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range nodes {
+		doc.appendChild(c)
+	}
+
+	// the children of portals go inside their target, the same as domrender, a target which is not in
+	// the output (e.g. an element in the page outside of the mount point) is added to the end of the document
+	for _, p := range portals {
+		sel, err := parseSelector(p.selector)
+		if err != nil {
+			return nil, fmt.Errorf("vugutest: invalid portal target: %w", err)
+		}
+		var target *Node
+		doc.walkElements(func(c *Node) bool {
+			if sel.match(c) {
+				target = c
+				return false
+			}
+			return true
+		})
+		if target == nil {
+			target = doc
+		}
+		target.appendChild(p.node)
+	}
+
+	return doc, nil
+}
+
+// portalNode is the element holding the children of a portal, which is added to its target after the rest is converted
+type portalNode struct {
+	selector string
+	node     *Node
+}
+
+// convertOne converts the output of a single component, the positionIDs follow the
+// same scheme as domrender.JSRenderer (see visitSyncNode there)
+func convertOne(br *vugu.BuildResults, bo *vugu.BuildOut, positionID string, portals *[]portalNode) ([]*Node, error) {
+
+	if bo == nil || len(bo.Out) != 1 {
+		return nil, fmt.Errorf("vugutest: BuildOut must contain exactly one element in Out")
+	}
+
+	var visit func(vgn *vugu.VGNode, positionID string) ([]*Node, error)
+	visit = func(vgn *vugu.VGNode, positionID string) ([]*Node, error) {
+
+		if vgn.Component != nil {
+			return convertOne(br, br.ResultFor(vgn.Component), positionID, portals)
+		}
+
+		if vgn.IsPortal() {
+			if vgn.FirstChild != nil {
+				pn := &Node{
+					Type: vugu.ElementNode,
+					Data: "div",
+					Attr: []vugu.VGAttribute{
+						{Key: "data-vg-portal", Val: positionID},
+						{Key: "style", Val: "display: contents"},
+					},
+				}
+				*portals = append(*portals, portalNode{selector: vgn.Portal, node: pn})
+				for i, c := 1, vgn.FirstChild; c != nil; i, c = i+1, c.NextSibling {
+					nc, err := visit(c, positionID+"_"+strconv.Itoa(i))
+					if err != nil {
+						return nil, err
+					}
+					for _, x := range nc {
+						pn.appendChild(x)
+					}
+				}
+			}
+			return []*Node{{Type: vugu.CommentNode, Data: "vg-portal", positionID: positionID}}, nil
+		}
+
+		if vgn.IsTemplate() {
+			var ret []*Node
+			for i, c := 1, vgn.FirstChild; c != nil; i, c = i+1, c.NextSibling {
+				nc, err := visit(c, positionID+"_t_"+strconv.Itoa(i))
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, nc...)
+			}
+			return ret, nil
+		}
+
+		n := &Node{
+			Type:       vgn.Type,
+			Data:       vgn.Data,
+			Namespace:  vgn.Namespace,
+			Attr:       append([]vugu.VGAttribute(nil), vgn.Attr...),
+			positionID: positionID,
+			handlers:   vgn.DOMEventHandlerSpecList,
+			ref:        vgn.Ref,
+		}
+
+		for _, p := range vgn.Prop {
+			var v any
+			err := vjson.Unmarshal(p.JSONVal, &v)
+			if err != nil {
+				return nil, fmt.Errorf("vugutest: unable to decode property %q: %w", p.Key, err)
+			}
+			n.setProp(p.Key, v)
+		}
+
+		if vgn.InnerHTML != nil {
+			ctx := &html.Node{Type: html.ElementNode, Data: vgn.Data, DataAtom: atom.Lookup([]byte(vgn.Data))}
+			parts, err := html.ParseFragment(strings.NewReader(*vgn.InnerHTML), ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range parts {
+				n.appendChild(fromHTMLNode(p))
+			}
+			return []*Node{n}, nil
+		}
+
+		for i, c := 1, vgn.FirstChild; c != nil; i, c = i+1, c.NextSibling {
+			nc, err := visit(c, positionID+"_"+strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			for _, x := range nc {
+				n.appendChild(x)
+			}
+		}
+
+		return []*Node{n}, nil
+	}
+
+	return visit(bo.Out[0], positionID)
+}
+
+// fromHTMLNode converts markup parsed from InnerHTML, these nodes have no positionID or handlers
+func fromHTMLNode(hn *html.Node) *Node {
+	n := &Node{
+		Type:      vugu.VGNodeType(hn.Type),
+		Data:      hn.Data,
+		Namespace: hn.Namespace,
+	}
+	for _, a := range hn.Attr {
+		n.Attr = append(n.Attr, vugu.VGAttribute{Namespace: a.Namespace, Key: a.Key, Val: a.Val})
+	}
+	for c := hn.FirstChild; c != nil; c = c.NextSibling {
+		n.appendChild(fromHTMLNode(c))
+	}
+	return n
+}
@@ -0,0 +1,252 @@
+package vugutest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/domrender"
+	"github.com/vugu/vugu/js"
+)
+
+// Event is the DOMEvent passed to handlers by Dispatch.  PreventDefault and StopPropagation
+// are recorded instead of calling into JS, and the JS methods return a zero js.Value.
+type Event struct {
+	vugu.DOMEvent
+
+	defaultPrevented   bool
+	propagationStopped bool
+	handled            int
+}
+
+// PreventDefault records that the default action was prevented.
+func (e *Event) PreventDefault() { e.defaultPrevented = true }
+
+// StopPropagation stops the event from being passed to further elements.
+func (e *Event) StopPropagation() { e.propagationStopped = true }
+
+// JSEvent returns a zero js.Value, there is no JS event.
+func (e *Event) JSEvent() js.Value { return js.Value{} }
+
+// JSEventTarget returns a zero js.Value, there is no JS event.
+func (e *Event) JSEventTarget() js.Value { return js.Value{} }
+
+// JSEventCurrentTarget returns a zero js.Value, there is no JS event.
+func (e *Event) JSEventCurrentTarget() js.Value { return js.Value{} }
+
+// DefaultPrevented returns true if PreventDefault was called, either by a handler or with the .prevent modifier.
+func (e *Event) DefaultPrevented() bool { return e.defaultPrevented }
+
+// PropagationStopped returns true if StopPropagation was called, either by a handler or with the .stop modifier.
+func (e *Event) PropagationStopped() bool { return e.propagationStopped }
+
+// HandlerCount returns the number of handlers that were invoked.
+func (e *Event) HandlerCount() int { return e.handled }
+
+// Dispatch fires an event of eventType at target, calling the matching handlers on target and its
+// ancestors in capture and then bubble order, and re-renders afterward if any handler was called.
+// The summary becomes the event's EventSummary (see vugu.DOMEvent), with "type" set and "target"
+// filled in from the target's attributes and properties (values in summary take precedence).
+// Set summary["bubbles"] to false to prevent bubbling; focus, blur and the like never bubble.
+func (h *Harness) Dispatch(target *Node, eventType string, summary map[string]any) (*Event, error) {
+
+	if target == nil {
+		return nil, fmt.Errorf("vugutest: Dispatch called with nil target")
+	}
+
+	ev, bubbles := newEvent(h.eventEnv, target, eventType, summary)
+	propagate(ev, target, bubbles, func(n *Node, capture bool) error {
+		h.invokeHandlers(ev, n, target, eventType, capture)
+		return nil
+	})
+
+	if ev.handled == 0 {
+		return ev, nil
+	}
+
+	// like domrender, a render happens after each handled event
+	h.drainRenderCh()
+	return ev, h.Render()
+}
+
+// invokeHandlers calls the handlers on n for the event type and phase, applying the modifiers like the JS side of domrender does
+func (h *Harness) invokeHandlers(ev *Event, n, target *Node, eventType string, capture bool) {
+
+	for i := range n.handlers {
+		hs := &n.handlers[i]
+
+		if hs.EventType != eventType || hs.Capture != capture || hs.Func == nil {
+			continue
+		}
+
+		if !eventModifiersMatch(ev, n, target, hs.Self, hs.Keys) {
+			continue
+		}
+		if hs.Once {
+			k := fmt.Sprintf("%s|%s|%v|%v|%s", n.positionID, hs.EventType, hs.Capture, hs.Passive, strings.Join(hs.Keys, ","))
+			if h.onceFired[k] {
+				continue
+			}
+			h.onceFired[k] = true
+		}
+		applyEventModifiers(ev, hs.PreventDefault, hs.StopPropagation)
+
+		h.eventMU.Lock()
+		hs.Func(ev)
+		h.eventMU.Unlock()
+		ev.handled++
+	}
+}
+
+// newEvent returns the Event for dispatching eventType at target and whether it bubbles,
+// with the summary filled in as described for Harness.Dispatch and Window.Dispatch
+func newEvent(eventEnv vugu.EventEnv, target *Node, eventType string, summary map[string]any) (ev *Event, bubbles bool) {
+
+	s := make(map[string]any, len(summary)+2)
+	for k, v := range summary {
+		s[k] = v
+	}
+	s["type"] = eventType
+	s["target"] = targetSummary(target, s["target"])
+
+	bubbles = domrender.EventBubbles(eventType)
+	if b, ok := s["bubbles"].(bool); ok {
+		bubbles = b
+	}
+	s["bubbles"] = bubbles
+
+	return &Event{DOMEvent: vugu.NewDOMEvent(eventEnv, s)}, bubbles
+}
+
+// propagate passes ev along the path from the document down to target and back up, as the browser does:
+// invoke is called for each node on the path in the capture phase, then for target in the bubble phase
+// and then, if the event bubbles, for the ancestors of target.  It stops when propagation is stopped or
+// invoke returns an error, which is returned.
+func propagate(ev *Event, target *Node, bubbles bool, invoke func(n *Node, capture bool) error) error {
+
+	var path []*Node
+	for n := target; n != nil && (n.Type == vugu.ElementNode || n.Type == vugu.DocumentNode); n = n.Parent {
+		path = append([]*Node{n}, path...)
+	}
+
+	var err error
+	for i := 0; i < len(path) && !ev.propagationStopped && err == nil; i++ {
+		err = invoke(path[i], true)
+	}
+	if !ev.propagationStopped && err == nil {
+		err = invoke(target, false)
+	}
+	for i := len(path) - 2; bubbles && i >= 0 && !ev.propagationStopped && err == nil; i-- {
+		err = invoke(path[i], false)
+	}
+
+	return err
+}
+
+// eventModifiersMatch returns false if the .self or key modifiers of a handler on n exclude the event
+func eventModifiersMatch(ev *Event, n, target *Node, self bool, keys []string) bool {
+	if self && n != target {
+		return false
+	}
+	return len(keys) == 0 || eventKeysMatch(ev.EventSummary(), keys)
+}
+
+// applyEventModifiers applies the .prevent and .stop modifiers of a handler which is called
+func applyEventModifiers(ev *Event, prevent, stop bool) {
+	if prevent {
+		ev.defaultPrevented = true
+	}
+	if stop {
+		ev.propagationStopped = true
+	}
+}
+
+// targetSummary builds the "target" part of the event summary from the node, merged with any target passed in
+func targetSummary(n *Node, in any) map[string]any {
+	ret := make(map[string]any)
+	for _, a := range n.Attr {
+		ret[a.Key] = a.Val
+	}
+	ret["tagName"] = strings.ToUpper(n.Data)
+	ret["value"] = n.Value()
+	ret["checked"] = n.Checked()
+	for k, v := range n.Props {
+		ret[k] = v
+	}
+	if m, ok := in.(map[string]any); ok {
+		for k, v := range m {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// these mirror eventKeyAliases and eventMouseButtons in domrender's JS
+var eventKeyAliases = map[string][]string{
+	"enter":  {"enter"},
+	"tab":    {"tab"},
+	"delete": {"delete", "backspace"},
+	"esc":    {"escape", "esc"},
+	"space":  {" ", "spacebar"},
+	"up":     {"arrowup", "up"},
+	"down":   {"arrowdown", "down"},
+	"left":   {"arrowleft", "left"},
+	"right":  {"arrowright", "right"},
+}
+
+var eventMouseButtons = map[string]float64{"left": 0, "middle": 1, "right": 2}
+
+var kebabRE = regexp.MustCompile(`([a-z])([A-Z])`)
+
+// eventKeysMatch returns true if the event satisfies all of the key modifiers, it is the same as eventKeysMatch in domrender's JS
+func eventKeysMatch(summary map[string]any, keys []string) bool {
+	for _, k := range keys {
+		switch k {
+		case "ctrl", "alt", "shift", "meta":
+			if b, _ := summary[k+"Key"].(bool); !b {
+				return false
+			}
+			continue
+		}
+		key, hasKey := summary["key"].(string)
+		// mouse events use left/middle/right as buttons
+		if btn, ok := summaryNumber(summary["button"]); ok && !hasKey {
+			if want, ok := eventMouseButtons[k]; ok {
+				if btn != want {
+					return false
+				}
+				continue
+			}
+		}
+		if !hasKey {
+			return false
+		}
+		eventKey := strings.ToLower(kebabRE.ReplaceAllString(key, "$1-$2"))
+		names, ok := eventKeyAliases[k]
+		if !ok {
+			names = []string{k}
+		}
+		found := false
+		for _, name := range names {
+			if name == eventKey || name == strings.ToLower(key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func summaryNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,313 @@
+package vugutest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vugu/vugu"
+)
+
+// The selector support covers what is commonly needed in tests:
+// type (div, *), #id, .class, [attr], [attr=val], [attr~=val], [attr^=val], [attr$=val], [attr*=val],
+// the descendant (space) and child (>) combinators and groups separated by commas.
+// Pseudo-classes are not supported.
+
+// selector is a group of alternatives (comma separated)
+type selector []complexSel
+
+func (s selector) match(n *Node) bool {
+	for _, c := range s {
+		if c.match(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// complexSel is a series of compound selectors joined by combinators, e.g. "ul > li.item a"
+type complexSel struct {
+	parts       []compoundSel
+	combinators []byte // combinators[i] joins parts[i] and parts[i+1], either ' ' or '>'
+}
+
+func (c complexSel) match(n *Node) bool {
+	return c.matchAt(n, len(c.parts)-1)
+}
+
+// matchAt matches right to left, part i against n
+func (c complexSel) matchAt(n *Node, i int) bool {
+	if !c.parts[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i-1] {
+	case '>':
+		p := n.Parent
+		return p != nil && p.Type == vugu.ElementNode && c.matchAt(p, i-1)
+	default:
+		for p := n.Parent; p != nil && p.Type == vugu.ElementNode; p = p.Parent {
+			if c.matchAt(p, i-1) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type attrSel struct {
+	key string
+	op  string // empty for presence only
+	val string
+}
+
+// compoundSel is a sequence of simple selectors which must all match the same element, e.g. "input.name[type=text]"
+type compoundSel struct {
+	tag     string // empty or "*" for any
+	id      string
+	classes []string
+	attrs   []attrSel
+}
+
+func (c compoundSel) match(n *Node) bool {
+	if n.Type != vugu.ElementNode {
+		return false
+	}
+	if c.tag != "" && c.tag != "*" && !strings.EqualFold(c.tag, n.Data) {
+		return false
+	}
+	if c.id != "" && n.ID() != c.id {
+		return false
+	}
+	for _, cl := range c.classes {
+		if !n.HasClass(cl) {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		v, ok := n.AttrValue(a.key)
+		if !ok {
+			return false
+		}
+		switch a.op {
+		case "":
+		case "=":
+			if v != a.val {
+				return false
+			}
+		case "~=":
+			found := false
+			for _, f := range strings.Fields(v) {
+				if f == a.val {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		case "^=":
+			if a.val == "" || !strings.HasPrefix(v, a.val) {
+				return false
+			}
+		case "$=":
+			if a.val == "" || !strings.HasSuffix(v, a.val) {
+				return false
+			}
+		case "*=":
+			if a.val == "" || !strings.Contains(v, a.val) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func mustParseSelector(s string) selector {
+	sel, err := parseSelector(s)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+// parseSelector parses a CSS selector
+func parseSelector(s string) (selector, error) {
+
+	var ret selector
+
+	for _, group := range strings.Split(s, ",") {
+		p := &selParser{s: strings.TrimSpace(group)}
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, fmt.Errorf("vugutest: invalid selector %q: %w", s, err)
+		}
+		ret = append(ret, c)
+	}
+
+	return ret, nil
+}
+
+type selParser struct {
+	s   string
+	pos int
+}
+
+func (p *selParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *selParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *selParser) parseComplex() (complexSel, error) {
+	var ret complexSel
+
+	if p.s == "" {
+		return ret, fmt.Errorf("empty selector")
+	}
+
+	for {
+		c, err := p.parseCompound()
+		if err != nil {
+			return ret, err
+		}
+		ret.parts = append(ret.parts, c)
+
+		hadSpace := p.skipSpace()
+		if p.eof() {
+			return ret, nil
+		}
+		comb := byte(' ')
+		if p.s[p.pos] == '>' {
+			comb = '>'
+			p.pos++
+			p.skipSpace()
+		} else if !hadSpace {
+			return ret, fmt.Errorf("unexpected %q at offset %d", p.s[p.pos], p.pos)
+		}
+		ret.combinators = append(ret.combinators, comb)
+	}
+}
+
+func (p *selParser) parseCompound() (compoundSel, error) {
+	var ret compoundSel
+	start := p.pos
+
+	if !p.eof() && p.s[p.pos] == '*' {
+		ret.tag = "*"
+		p.pos++
+	} else if name := p.ident(); name != "" {
+		ret.tag = name
+	}
+
+	for !p.eof() {
+		switch p.s[p.pos] {
+		case '#':
+			p.pos++
+			ret.id = p.ident()
+			if ret.id == "" {
+				return ret, fmt.Errorf("missing id at offset %d", p.pos)
+			}
+		case '.':
+			p.pos++
+			cl := p.ident()
+			if cl == "" {
+				return ret, fmt.Errorf("missing class name at offset %d", p.pos)
+			}
+			ret.classes = append(ret.classes, cl)
+		case '[':
+			p.pos++
+			a, err := p.parseAttr()
+			if err != nil {
+				return ret, err
+			}
+			ret.attrs = append(ret.attrs, a)
+		case ':':
+			return ret, fmt.Errorf("pseudo-classes are not supported (offset %d)", p.pos)
+		default:
+			if p.pos == start {
+				return ret, fmt.Errorf("unexpected %q at offset %d", p.s[p.pos], p.pos)
+			}
+			return ret, nil
+		}
+	}
+
+	if p.pos == start {
+		return ret, fmt.Errorf("missing selector at offset %d", p.pos)
+	}
+	return ret, nil
+}
+
+// parseAttr parses the inside of [...], the opening bracket has already been consumed
+func (p *selParser) parseAttr() (attrSel, error) {
+	var ret attrSel
+
+	p.skipSpace()
+	ret.key = p.ident()
+	if ret.key == "" {
+		return ret, fmt.Errorf("missing attribute name at offset %d", p.pos)
+	}
+	p.skipSpace()
+
+	if p.eof() {
+		return ret, fmt.Errorf("unterminated attribute selector")
+	}
+
+	if p.s[p.pos] == ']' {
+		p.pos++
+		return ret, nil
+	}
+
+	switch {
+	case p.s[p.pos] == '=':
+		ret.op = "="
+		p.pos++
+	case p.pos+1 < len(p.s) && p.s[p.pos+1] == '=' && strings.IndexByte("~^$*", p.s[p.pos]) >= 0:
+		ret.op = p.s[p.pos : p.pos+2]
+		p.pos += 2
+	default:
+		return ret, fmt.Errorf("unexpected %q in attribute selector at offset %d", p.s[p.pos], p.pos)
+	}
+
+	p.skipSpace()
+	if p.eof() {
+		return ret, fmt.Errorf("unterminated attribute selector")
+	}
+
+	if q := p.s[p.pos]; q == '"' || q == '\'' {
+		end := strings.IndexByte(p.s[p.pos+1:], q)
+		if end < 0 {
+			return ret, fmt.Errorf("unterminated string in attribute selector")
+		}
+		ret.val = p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		ret.val = p.ident()
+	}
+
+	p.skipSpace()
+	if p.eof() || p.s[p.pos] != ']' {
+		return ret, fmt.Errorf("unterminated attribute selector")
+	}
+	p.pos++
+
+	return ret, nil
+}
+
+func (p *selParser) ident() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80 {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
@@ -0,0 +1,66 @@
+package vugutest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vugu/vugu"
+)
+
+func TestSelector(t *testing.T) {
+
+	assert := assert.New(t)
+
+	el := func(tag string, attrs ...string) *Node {
+		n := &Node{Type: vugu.ElementNode, Data: tag}
+		for i := 0; i+1 < len(attrs); i += 2 {
+			n.Attr = append(n.Attr, vugu.VGAttribute{Key: attrs[i], Val: attrs[i+1]})
+		}
+		return n
+	}
+
+	doc := &Node{Type: vugu.DocumentNode}
+	ul := el("ul", "id", "list", "class", "menu main")
+	doc.appendChild(ul)
+	li1 := el("li", "class", "item", "data-name", "first-one")
+	li2 := el("li", "class", "item active", "data-name", "second")
+	ul.appendChild(li1)
+	ul.appendChild(li2)
+	a := el("a", "href", "https://example.com/x.png")
+	li2.appendChild(a)
+	span := el("span")
+	a.appendChild(span)
+
+	tcases := []struct {
+		sel  string
+		want []*Node
+	}{
+		{"li", []*Node{li1, li2}},
+		{"*", []*Node{ul, li1, li2, a, span}},
+		{"#list", []*Node{ul}},
+		{".menu.main", []*Node{ul}},
+		{"li.active", []*Node{li2}},
+		{"ul > li", []*Node{li1, li2}},
+		{"ul > span", nil},
+		{"ul span", []*Node{span}},
+		{"#list .item a > span", []*Node{span}},
+		{"[href]", []*Node{a}},
+		{"[data-name=second]", []*Node{li2}},
+		{`[data-name="first-one"]`, []*Node{li1}},
+		{"[class~=active]", []*Node{li2}},
+		{"[data-name^=first]", []*Node{li1}},
+		{"[href$='.png']", []*Node{a}},
+		{"[href*=example]", []*Node{a}},
+		{"a, #list", []*Node{ul, a}},
+	}
+
+	for _, tc := range tcases {
+		assert.Equal(tc.want, doc.QueryAll(tc.sel), "selector %q", tc.sel)
+	}
+
+	for _, bad := range []string{"", "li:first-child", "[href", "a,", "#", "li >"} {
+		_, err := parseSelector(bad)
+		assert.Error(err, "selector %q", bad)
+	}
+}
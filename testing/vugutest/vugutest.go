@@ -0,0 +1,339 @@
+/*
+Package vugutest provides a headless harness for testing Vugu components with a plain `go test`,
+no browser required.
+
+A Harness builds a root component with a BuildEnv and keeps the output as an in-memory DOM
+(see Node).  Tests can query it with CSS selectors, dispatch synthetic events into the
+DOMEventHandlerSpec funcs of the rendered elements and check the resulting HTML:
+
+	h, err := vugutest.New(&Root{})
+	...
+	err = h.Click("#add")
+	...
+	if h.MustQuery(".count").Text() != "1" { ... }
+
+Events are dispatched with capture and bubbling phases like in the browser and the event
+modifiers (PreventDefault, StopPropagation, Once, Self and Keys) are honored.  After each
+dispatch the harness re-renders, just like domrender does.  Changes made from other goroutines
+which call EventEnv().UnlockRender() are picked up with WaitForRender or RenderPending.
+
+The DOM is rebuilt on each render, so Nodes obtained before a render should not be used afterward.
+
+A Harness does not use domrender, to test domrender.JSRenderer itself (or a component together with it)
+use a Window, which runs the instructions from the renderer against an in-memory DOM in place of the browser.
+*/
+package vugutest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vugu/vugu"
+	js "github.com/vugu/vugu/js"
+)
+
+// Harness runs a root component against an in-memory DOM.
+type Harness struct {
+	root vugu.Builder
+
+	buildEnv *vugu.BuildEnv
+	eventEnv *vugu.EventEnvImpl
+	eventMU  sync.RWMutex
+	renderCh chan bool
+
+	doc *Node
+
+	renderCount int
+
+	onceFired map[string]bool
+
+	// manages the Rendered lifecycle callback
+	renderedFirst map[any]bool
+
+	// the vg-refs assigned in the last render
+	refs map[*js.Element]bool
+}
+
+// New creates a Harness for the root component and performs the first render.
+func New(root vugu.Builder) (*Harness, error) {
+
+	h := &Harness{
+		root:          root,
+		renderCh:      make(chan bool, 64),
+		onceFired:     make(map[string]bool),
+		renderedFirst: make(map[any]bool),
+	}
+	h.eventEnv = vugu.NewEventEnvImpl(&h.eventMU, h.renderCh)
+
+	var err error
+	h.buildEnv, err = vugu.NewBuildEnv(h.eventEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// EventEnv returns the EventEnv used by the harness.
+func (h *Harness) EventEnv() vugu.EventEnv {
+	return h.eventEnv
+}
+
+// BuildEnv returns the BuildEnv used by the harness.
+func (h *Harness) BuildEnv() *vugu.BuildEnv {
+	return h.buildEnv
+}
+
+// RenderCount returns the number of renders performed so far.
+func (h *Harness) RenderCount() int {
+	return h.renderCount
+}
+
+// Render builds the root component and replaces the in-memory DOM with the result.
+func (h *Harness) Render() error {
+
+	h.eventMU.RLock()
+	defer h.eventMU.RUnlock()
+
+	br := h.buildEnv.RunBuild(h.root)
+
+	doc, err := buildDOM(br)
+	if err != nil {
+		return err
+	}
+	h.doc = doc
+	h.renderCount++
+
+	h.assignRefs()
+
+	h.invokeRendered(br)
+
+	return nil
+}
+
+// assignRefs sets each vg-ref in the DOM to an Element (which does nothing outside of wasm, but is set)
+// and clears the ones from the last render which are gone, like domrender does
+func (h *Harness) assignRefs() {
+	refs := make(map[*js.Element]bool)
+	h.doc.walkElements(func(n *Node) bool {
+		if n.ref != nil {
+			*n.ref = js.ElementOf(js.Undefined())
+			refs[n.ref] = true
+		}
+		return true
+	})
+	for ref := range h.refs {
+		if !refs[ref] {
+			*ref = js.Element{}
+		}
+	}
+	h.refs = refs
+}
+
+// RenderPending re-renders if a render was requested (with EventEnv().UnlockRender())
+// since the last render and returns true if so.  It does not block.
+func (h *Harness) RenderPending() (bool, error) {
+	select {
+	case <-h.renderCh:
+		h.drainRenderCh()
+		return true, h.Render()
+	default:
+		return false, nil
+	}
+}
+
+// WaitForRender waits up to timeout for a render to be requested (with EventEnv().UnlockRender(),
+// typically from a goroutine started by an event handler), then re-renders.
+// It returns false if the timeout elapsed first.
+func (h *Harness) WaitForRender(timeout time.Duration) (bool, error) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-h.renderCh:
+		h.drainRenderCh()
+		return true, h.Render()
+	case <-t.C:
+		return false, nil
+	}
+}
+
+func (h *Harness) drainRenderCh() {
+	for {
+		select {
+		case <-h.renderCh:
+		default:
+			return
+		}
+	}
+}
+
+// Document returns the document node, the parent of the root element.
+func (h *Harness) Document() *Node {
+	return h.doc
+}
+
+// HTML returns the rendered HTML of the root element.
+func (h *Harness) HTML() string {
+	return h.doc.InnerHTML()
+}
+
+// Query returns the first element matching the CSS selector, or nil if none.
+// It panics if the selector is invalid.
+func (h *Harness) Query(selector string) *Node {
+	return h.doc.Query(selector)
+}
+
+// QueryAll returns all elements matching the CSS selector, in document order.
+// It panics if the selector is invalid.
+func (h *Harness) QueryAll(selector string) []*Node {
+	return h.doc.QueryAll(selector)
+}
+
+// MustQuery is like Query but panics if nothing matches.
+func (h *Harness) MustQuery(selector string) *Node {
+	n := h.Query(selector)
+	if n == nil {
+		panic(fmt.Errorf("vugutest: no element matches %q", selector))
+	}
+	return n
+}
+
+// ErrNotFound is returned by the event helpers when the selector does not match anything.
+var ErrNotFound = errors.New("vugutest: no element matches selector")
+
+func (h *Harness) queryErr(selector string) (*Node, error) {
+	n := h.Query(selector)
+	if n == nil {
+		return nil, fmt.Errorf("%w %q", ErrNotFound, selector)
+	}
+	return n, nil
+}
+
+// Click dispatches a click event on the first element matching selector.
+func (h *Harness) Click(selector string) error {
+	n, err := h.queryErr(selector)
+	if err != nil {
+		return err
+	}
+	_, err = h.Dispatch(n, "click", map[string]any{"button": 0.0, "buttons": 0.0, "detail": 1.0})
+	return err
+}
+
+// Input sets the value of the first element matching selector and dispatches
+// an input event followed by a change event, as happens when a user types into a field.
+func (h *Harness) Input(selector, value string) error {
+	n, err := h.queryErr(selector)
+	if err != nil {
+		return err
+	}
+	n.setProp("value", value)
+	_, err = h.Dispatch(n, "input", map[string]any{"inputType": "insertText", "data": value})
+	if err != nil {
+		return err
+	}
+	// the DOM was rebuilt by the render after the input event, so the change event goes to
+	// the element in the same position (which now has the updated value if it is bound)
+	n = h.doc.byPositionID(n.positionID)
+	if n == nil {
+		return nil
+	}
+	n.setProp("value", value)
+	_, err = h.Dispatch(n, "change", nil)
+	return err
+}
+
+// SetChecked sets the checked state of the first element matching selector (a checkbox or radio)
+// and dispatches a change event.
+func (h *Harness) SetChecked(selector string, checked bool) error {
+	n, err := h.queryErr(selector)
+	if err != nil {
+		return err
+	}
+	n.setProp("checked", checked)
+	_, err = h.Dispatch(n, "change", nil)
+	return err
+}
+
+// KeyDown dispatches a keydown event followed by a keyup event with the specified key (e.g. "Enter", "a")
+// on the first element matching selector.  Modifier keys can be set with mods, e.g. "ctrlKey": true.
+func (h *Harness) KeyDown(selector, key string, mods map[string]any) error {
+	n, err := h.queryErr(selector)
+	if err != nil {
+		return err
+	}
+	summary := map[string]any{"key": key}
+	for k, v := range mods {
+		summary[k] = v
+	}
+	_, err = h.Dispatch(n, "keydown", summary)
+	if err != nil {
+		return err
+	}
+	n = h.doc.byPositionID(n.positionID)
+	if n == nil {
+		return nil
+	}
+	_, err = h.Dispatch(n, "keyup", summary)
+	return err
+}
+
+type renderedCtx struct {
+	eventEnv vugu.EventEnv
+	first    bool
+}
+
+func (c *renderedCtx) EventEnv() vugu.EventEnv { return c.eventEnv }
+func (c *renderedCtx) First() bool             { return c.first }
+
+// invokeRendered calls Rendered on each component in the build, like domrender does
+func (h *Harness) invokeRendered(br *vugu.BuildResults) {
+
+	seen := make(map[any]bool)
+
+	var walk func(bo *vugu.BuildOut)
+	walk = func(bo *vugu.BuildOut) {
+		if bo == nil {
+			return
+		}
+		for _, c := range bo.Components {
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			first := !h.renderedFirst[c]
+			h.renderedFirst[c] = true
+			switch rc := c.(type) {
+			case interface{ Rendered() }:
+				rc.Rendered()
+			case interface{ Rendered(ctx vugu.RenderedCtx) }:
+				rc.Rendered(&renderedCtx{eventEnv: h.eventEnv, first: first})
+			}
+			walk(br.ResultFor(c))
+		}
+	}
+
+	seen[h.root] = true
+	first := !h.renderedFirst[h.root]
+	h.renderedFirst[h.root] = true
+	switch rc := h.root.(type) {
+	case interface{ Rendered() }:
+		rc.Rendered()
+	case interface{ Rendered(ctx vugu.RenderedCtx) }:
+		rc.Rendered(&renderedCtx{eventEnv: h.eventEnv, first: first})
+	}
+	walk(br.Out)
+
+	// forget components no longer present
+	for k := range h.renderedFirst {
+		if !seen[k] {
+			delete(h.renderedFirst, k)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package vugutest
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vugu/vjson"
+
+	"github.com/vugu/vugu"
+	js "github.com/vugu/vugu/js"
+)
+
+// counter is a hand-written component like what the code generator outputs
+type counter struct {
+	Count  int
+	Name   string
+	Log    []string
+	Clicks int
+}
+
+func (c *counter) Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {
+
+	div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div", Attr: []vugu.VGAttribute{{Key: "id", Val: "root"}}}
+	div.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+		{EventType: "click", Capture: true, Func: func(vugu.DOMEvent) { c.Log = append(c.Log, "root-capture") }},
+		{EventType: "click", Func: func(vugu.DOMEvent) { c.Log = append(c.Log, "root-bubble") }},
+	}
+
+	count := &vugu.VGNode{Type: vugu.ElementNode, Data: "span", Attr: []vugu.VGAttribute{{Key: "class", Val: "count big"}}}
+	count.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: strconv.Itoa(c.Count)})
+	div.AppendChild(count)
+
+	btn := &vugu.VGNode{Type: vugu.ElementNode, Data: "button", Attr: []vugu.VGAttribute{{Key: "id", Val: "add"}}}
+	btn.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+		{EventType: "click", Func: func(vugu.DOMEvent) { c.Count++; c.Log = append(c.Log, "add") }},
+	}
+	btn.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: "Add"})
+	div.AppendChild(btn)
+
+	stop := &vugu.VGNode{Type: vugu.ElementNode, Data: "button", Attr: []vugu.VGAttribute{{Key: "id", Val: "stop"}}}
+	stop.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+		{EventType: "click", StopPropagation: true, PreventDefault: true, Once: true, Func: func(vugu.DOMEvent) { c.Clicks++ }},
+	}
+	div.AppendChild(stop)
+
+	input := &vugu.VGNode{Type: vugu.ElementNode, Data: "input", Attr: []vugu.VGAttribute{{Key: "type", Val: "text"}, {Key: "name", Val: "name"}}}
+	b, _ := vjson.Marshal(c.Name)
+	input.Prop = append(input.Prop, vugu.VGProperty{Key: "value", JSONVal: b})
+	input.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+		{EventType: "input", Func: func(e vugu.DOMEvent) { c.Name = vugu.InputEventOf(e).Value }},
+		{EventType: "keydown", Keys: []string{"enter"}, Func: func(e vugu.DOMEvent) { c.Log = append(c.Log, "enter") }},
+	}
+	div.AppendChild(input)
+
+	tmpl := &vugu.VGNode{Type: vugu.ElementNode} // template, children go directly into div
+	for i := 0; i < c.Count; i++ {
+		li := &vugu.VGNode{Type: vugu.ElementNode, Data: "p", Attr: []vugu.VGAttribute{{Key: "data-i", Val: strconv.Itoa(i)}}}
+		tmpl.AppendChild(li)
+	}
+	div.AppendChild(tmpl)
+
+	raw := "<b>raw</b>"
+	rawdiv := &vugu.VGNode{Type: vugu.ElementNode, Data: "div", Attr: []vugu.VGAttribute{{Key: "class", Val: "raw"}}, InnerHTML: &raw}
+	div.AppendChild(rawdiv)
+
+	return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+}
+
+func TestHarness(t *testing.T) {
+
+	assert := assert.New(t)
+
+	c := &counter{}
+	h, err := New(c)
+	assert.NoError(err)
+
+	assert.Equal("0", h.MustQuery(".count").Text())
+	assert.Equal("0", h.MustQuery("#root").PositionID())
+	assert.Equal("0_2", h.MustQuery("#add").PositionID())
+	assert.Equal("raw", h.MustQuery(".raw b").Text())
+	assert.Nil(h.Query("p"))
+
+	// click bubbles through capture, target and bubble phases and re-renders
+	assert.NoError(h.Click("#add"))
+	assert.Equal([]string{"root-capture", "add", "root-bubble"}, c.Log)
+	assert.Equal("1", h.MustQuery("span.count").Text())
+	assert.NoError(h.Click("#add"))
+	assert.Equal("2", h.MustQuery(".count").Text())
+	assert.Len(h.QueryAll("#root > p"), 2)
+	assert.Equal("0_5_t_2", h.MustQuery(`p[data-i="1"]`).PositionID())
+	assert.Contains(h.HTML(), `<span class="count big">2</span>`)
+
+	// modifiers: stop prevents bubbling, once fires only once
+	c.Log = nil
+	ev, err := h.Dispatch(h.MustQuery("#stop"), "click", nil)
+	assert.NoError(err)
+	assert.True(ev.DefaultPrevented())
+	assert.True(ev.PropagationStopped())
+	assert.Equal([]string{"root-capture"}, c.Log)
+	assert.NoError(h.Click("#stop"))
+	assert.Equal(1, c.Clicks)
+
+	// input sets the value property and fires the handler
+	assert.NoError(h.Input("input[name=name]", "Joe"))
+	assert.Equal("Joe", c.Name)
+	assert.Equal("Joe", h.MustQuery("input").Value())
+
+	// key filtering
+	c.Log = nil
+	assert.NoError(h.KeyDown("input", "a", nil))
+	assert.NotContains(c.Log, "enter")
+	assert.NoError(h.KeyDown("input", "Enter", nil))
+	assert.Contains(c.Log, "enter")
+
+	// async changes are picked up when the render is requested
+	go func() {
+		ee := h.EventEnv()
+		ee.Lock()
+		c.Count = 5
+		ee.UnlockRender()
+	}()
+	ok, err := h.WaitForRender(5 * time.Second)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("5", h.MustQuery(".count").Text())
+
+	ok, err = h.RenderPending()
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.ErrorIs(h.Click("#missing"), ErrNotFound)
+}
+
+func TestHarnessPortal(t *testing.T) {
+
+	assert := assert.New(t)
+
+	open := true
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div", Attr: []vugu.VGAttribute{{Key: "id", Val: "root"}}}
+		portal := &vugu.VGNode{Type: vugu.ElementNode, Portal: "#modals"}
+		if open {
+			btn := &vugu.VGNode{Type: vugu.ElementNode, Data: "button", Attr: []vugu.VGAttribute{{Key: "id", Val: "close"}}}
+			btn.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+				{EventType: "click", Func: func(vugu.DOMEvent) { open = false }},
+			}
+			portal.AppendChild(btn)
+		}
+		div.AppendChild(portal)
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	h, err := New(root)
+	assert.NoError(err)
+
+	// the target is not in the output, so the children go at the end of the document
+	assert.Nil(h.Query("#root button"))
+	pos, _ := h.MustQuery("[data-vg-portal] > #close").Parent.AttrValue("data-vg-portal")
+	assert.Equal("0_1", pos)
+	assert.Contains(h.HTML(), "<!--vg-portal-->")
+
+	// events are handled by the owning component
+	assert.NoError(h.Click("#close"))
+	assert.False(open)
+	assert.Nil(h.Query("#close"))
+}
+
+func TestHarnessRef(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var inputEl js.Element
+	show := true
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div"}
+		if show {
+			div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "input", Ref: &inputEl})
+		}
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	h, err := New(root)
+	assert.NoError(err)
+	assert.True(inputEl.IsSet())
+	inputEl.Focus() // no-op outside of wasm
+	assert.Equal("", inputEl.Value())
+
+	show = false
+	assert.NoError(h.Render())
+	assert.False(inputEl.IsSet())
+}
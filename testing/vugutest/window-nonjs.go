@@ -0,0 +1,16 @@
+//go:build !js || !wasm
+
+package vugutest
+
+import (
+	js "github.com/vugu/vugu/js"
+)
+
+// Install makes the Window the global JS object (see js.SetGlobal), so a domrender.JSRenderer created
+// afterward renders into it.  The returned function restores the previous global.
+// Only one Window can be installed at a time, and tests that use one cannot be run in parallel.
+func (w *Window) Install() (uninstall func()) {
+	prev := js.Global()
+	js.SetGlobal(js.ValueOf(windowObject{w: w}))
+	return func() { js.SetGlobal(prev) }
+}
@@ -0,0 +1,1120 @@
+package vugutest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vugu/html"
+	"github.com/vugu/html/atom"
+	"github.com/vugu/vjson"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/domrender/ildecode"
+	js "github.com/vugu/vugu/js"
+)
+
+// Window is an in-memory stand-in for the browser window that domrender.JSRenderer renders into,
+// so the renderer itself can be tested with a plain `go test`.  It is a Go implementation of the
+// JS side of domrender (vuguRender and friends) which applies the instructions from the renderer
+// to a DOM of Nodes and registers the event listeners they specify, so events dispatched to
+// the DOM go back to the renderer and into the handlers of the components:
+//
+//	w, err := vugutest.NewWindow(`<html><head></head><body><div id="app"></div></body></html>`)
+//	...
+//	defer w.Install()()
+//	r, err := domrender.New("#app")
+//	...
+//	buildEnv, err := vugu.NewBuildEnv(r.EventEnv())
+//	...
+//	err = r.Render(buildEnv.RunBuild(root))
+//	...
+//	_, err = w.Click("#add")
+//	...
+//	err = r.Render(buildEnv.RunBuild(root)) // the handler ran and requested a render
+//
+// Unlike a Harness, the DOM is updated in place by each render, so Nodes stay valid for as long as the
+// renderer keeps them.  Only the parts of the browser that domrender uses are implemented.
+type Window struct {
+	doc *Node
+
+	renderArray     []byte   // see vuguGetRenderArray
+	eventHandler    js.Value // see vuguSetEventHandler
+	callbackHandler js.Value // see vuguSetCallbackHandler
+	props           map[string]js.Value
+
+	// the same state as window.vuguState on the JS side
+	el                *Node
+	nextElMove        string // "first_child", "next_sibling" or ""
+	pendingKey        string
+	mountPointEl      *Node
+	elAttrNames       map[string]bool
+	elEventKeys       map[string]bool
+	elDelegated       bool
+	eventHandlerMap   map[string]map[string]*listener // positionID -> event key -> listener
+	delegateListeners map[string]*delegateListener    // event type and phase -> listener at the mount point
+	cssTagsSet        []*Node
+	bufferedInnerHTML string
+	hydrateMismatches []map[string]any
+
+	// the event being dispatched, for vuguGetActiveEvent and friends
+	activeEvent         *Event
+	activeTarget        *Node
+	activeCurrentTarget *Node
+}
+
+// listener is an event listener added to an element by opcodeSetEventListener or opcodeSetDelegatedEvents
+type listener struct {
+	positionID string
+	eventType  string
+	capture    bool
+	passive    bool
+	flags      uint8
+	keys       string
+	fired      bool // a once listener which has been called
+	delegate   bool // the listener at the mount point which calls the delegated listeners, see delegateEvent
+}
+
+// key returns the event key, the same as on the JS side
+func (l *listener) key() string {
+	return fmt.Sprintf("%s|%s|%s|%d:%s", l.eventType, boolDigit(l.capture), boolDigit(l.passive), l.flags, l.keys)
+}
+
+// parseEventKey returns a listener from an event key sent with opcodeSetDelegatedEvents
+func parseEventKey(positionID, k string) (*listener, error) {
+	parts := strings.SplitN(k, "|", 4)
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bad event key %q", k)
+	}
+	flags, keys, _ := strings.Cut(parts[3], ":")
+	f, err := strconv.ParseUint(flags, 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("bad event key %q: %w", k, err)
+	}
+	return &listener{
+		positionID: positionID,
+		eventType:  parts[0],
+		capture:    parts[1] == "1",
+		passive:    parts[2] == "1",
+		flags:      uint8(f),
+		keys:       keys,
+	}, nil
+}
+
+// delegateListener is a listener added to root by delegateEvent
+type delegateListener struct {
+	l    *listener
+	root *Node
+}
+
+// event modifier flags, the same as the Go and JS sides of domrender
+const (
+	eventFlagPreventDefault  = 1
+	eventFlagStopPropagation = 2
+	eventFlagOnce            = 4
+	eventFlagSelf            = 8
+)
+
+// NewWindow returns a Window with a DOM parsed from pageHTML, which should contain the mount point
+// the renderer will use (or just be "<html></html>" if the root component renders the whole page).
+func NewWindow(pageHTML string) (*Window, error) {
+	hn, err := html.Parse(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, err
+	}
+	return &Window{
+		doc:               fromHTMLNode(hn),
+		eventHandlerMap:   make(map[string]map[string]*listener),
+		delegateListeners: make(map[string]*delegateListener),
+	}, nil
+}
+
+// Document returns the document node.
+func (w *Window) Document() *Node {
+	return w.doc
+}
+
+// Query returns the first element in the document matching the CSS selector, or nil if none.
+// It panics if the selector is invalid.
+func (w *Window) Query(selector string) *Node {
+	return w.doc.Query(selector)
+}
+
+// QueryAll returns all elements in the document matching the CSS selector, in document order.
+// It panics if the selector is invalid.
+func (w *Window) QueryAll(selector string) []*Node {
+	return w.doc.QueryAll(selector)
+}
+
+// Click dispatches a click event on the first element matching selector.
+func (w *Window) Click(selector string) (*Event, error) {
+	n := w.Query(selector)
+	if n == nil {
+		return nil, fmt.Errorf("%w %q", ErrNotFound, selector)
+	}
+	return w.Dispatch(n, "click", map[string]any{"button": 0.0, "buttons": 0.0, "detail": 1.0})
+}
+
+// Input sets the value property of the first element matching selector and dispatches
+// an input event followed by a change event, as happens when a user types into a field.
+func (w *Window) Input(selector, value string) error {
+	n := w.Query(selector)
+	if n == nil {
+		return fmt.Errorf("%w %q", ErrNotFound, selector)
+	}
+	n.setProp("value", value)
+	_, err := w.Dispatch(n, "input", map[string]any{"inputType": "insertText", "data": value})
+	if err != nil {
+		return err
+	}
+	_, err = w.Dispatch(n, "change", nil)
+	return err
+}
+
+// Dispatch fires an event of eventType at target, calling the event listeners registered by the renderer
+// on target and its ancestors in capture and then bubble order, each of which passes the event to the
+// renderer to call the handler.  Delegated listeners (see domrender.JSRenderer.SetEventDelegation) are
+// called from the listener at the mount point, as they are in a browser.  The event summary is built the same way as Harness.Dispatch does.
+// The renderer requests a render after each handled event, it is up to the caller to do it.
+func (w *Window) Dispatch(target *Node, eventType string, summary map[string]any) (*Event, error) {
+
+	if target == nil {
+		return nil, fmt.Errorf("vugutest: Dispatch called with nil target")
+	}
+	if w.eventHandler.IsUndefined() {
+		return nil, fmt.Errorf("vugutest: Dispatch called before vuguSetEventHandler")
+	}
+
+	ev, bubbles := newEvent(nil, target, eventType, summary)
+	err := propagate(ev, target, bubbles, func(n *Node, capture bool) error {
+		return w.invokeListeners(ev, n, target, eventType, capture)
+	})
+
+	return ev, err
+}
+
+// invokeListeners calls the listeners on n for the event type and phase, like the listener function on the JS side
+func (w *Window) invokeListeners(ev *Event, n, target *Node, eventType string, capture bool) error {
+
+	for _, l := range append([]*listener(nil), n.listeners...) {
+
+		if l.eventType != eventType || l.capture != capture {
+			continue
+		}
+
+		var err error
+		if l.delegate {
+			err = w.invokeDelegated(ev, n, target, l)
+		} else {
+			err = w.callListener(ev, l, n, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// invokeDelegated calls the delegated listeners for the phase of dl on the elements from target up to root,
+// like the listener function from delegateListener on the JS side
+func (w *Window) invokeDelegated(ev *Event, root, target *Node, dl *listener) error {
+
+	var path []*Node
+	for n := target; n != nil; n = n.Parent {
+		if len(n.delegated) > 0 {
+			path = append(path, n)
+		}
+		if n == root {
+			break
+		}
+	}
+	if dl.capture {
+		slices.Reverse(path)
+	}
+
+	for _, n := range path {
+		for _, l := range n.delegated {
+			if l.eventType != dl.eventType || l.capture != dl.capture || l.passive != dl.passive || l.fired {
+				continue
+			}
+			err := w.callListener(ev, l, n, target)
+			if err != nil {
+				return err
+			}
+		}
+		if ev.propagationStopped {
+			break
+		}
+	}
+
+	return nil
+}
+
+// callListener applies the modifiers of l and passes the event to the renderer, n is the element l is for
+func (w *Window) callListener(ev *Event, l *listener, n, target *Node) error {
+
+	var keys []string
+	if l.keys != "" {
+		keys = strings.Split(l.keys, ",")
+	}
+	if !eventModifiersMatch(ev, n, target, l.flags&eventFlagSelf != 0, keys) {
+		return nil
+	}
+	applyEventModifiers(ev, l.flags&eventFlagPreventDefault != 0, l.flags&eventFlagStopPropagation != 0)
+	if l.flags&eventFlagOnce != 0 {
+		l.fired = true
+		n.removeListener(l)
+	}
+
+	b, err := vjson.Marshal(map[string]any{
+		"position_id":   l.positionID,
+		"event_type":    l.eventType,
+		"capture":       l.capture,
+		"passive":       l.passive,
+		"modifiers":     fmt.Sprintf("%d:%s", l.flags, l.keys),
+		"event_summary": ev.EventSummary(),
+	})
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, len(b)+4)
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[4:], b)
+
+	w.activeEvent, w.activeTarget, w.activeCurrentTarget = ev, target, n
+	w.eventHandler.Invoke(buf)
+	w.activeEvent, w.activeTarget, w.activeCurrentTarget = nil, nil, nil
+	ev.handled++
+
+	return nil
+}
+
+// delegateEvent adds the listener for the event type and phase at the mount point (or the document) if it is not there already
+func (w *Window) delegateEvent(eventType string, capture, passive bool) {
+	root := w.mountPointEl
+	if root == nil {
+		root = w.doc
+	}
+	k := eventType + "|" + boolDigit(capture) + "|" + boolDigit(passive)
+	dl := w.delegateListeners[k]
+	if dl != nil && dl.root == root {
+		return
+	}
+	if dl != nil {
+		// the mount point was replaced
+		dl.root.removeListener(dl.l)
+	} else {
+		dl = &delegateListener{l: &listener{eventType: eventType, capture: capture, passive: passive, delegate: true}}
+		w.delegateListeners[k] = dl
+	}
+	dl.root = root
+	root.addListener(dl.l)
+}
+
+// HydrationMismatches returns the mismatches found by opcodeHydrateExpect which the renderer has not read yet.
+func (w *Window) HydrationMismatches() []map[string]any {
+	return w.hydrateMismatches
+}
+
+// Apply runs the instructions in b, in the same way as vuguRender does on the JS side.  The state is kept
+// between calls, so instructions can continue from the last call (the renderer flushes when its buffer is full).
+func (w *Window) Apply(b []byte) error {
+
+	il, err := ildecode.Decode(b)
+	if err != nil {
+		return err
+	}
+
+	w.pendingKey = ""
+	if w.elAttrNames == nil {
+		w.elAttrNames = make(map[string]bool)
+		w.elEventKeys = make(map[string]bool)
+	}
+
+	for _, in := range il {
+		err := w.apply(in)
+		if err != nil {
+			return fmt.Errorf("vugutest: %s: %w", ildecode.Format(in), err)
+		}
+	}
+
+	return nil
+}
+
+// apply runs a single instruction, see the JS side for the details of each
+func (w *Window) apply(in ildecode.Instruction) error {
+
+	switch in := in.(type) {
+
+	case ildecode.ClearEl:
+		w.el = nil
+		w.nextElMove = ""
+
+	case ildecode.SetProperty:
+		if w.el == nil {
+			return fmt.Errorf("no current reference")
+		}
+		var v any
+		err := vjson.Unmarshal([]byte(in.JSONValue), &v)
+		if err != nil {
+			return err
+		}
+		w.el.setProp(in.Key, v)
+
+	case ildecode.SelectQuery:
+		el, err := w.querySelector(in.Selector)
+		if err != nil {
+			return err
+		}
+		w.el = el
+		w.nextElMove = ""
+
+	case ildecode.SetAttrStr:
+		if w.el == nil {
+			return fmt.Errorf("no current reference")
+		}
+		w.el.setAttr(in.Name, in.Value)
+		w.elAttrNames[in.Name] = true
+
+	case ildecode.SetAttrNSStr:
+		if w.el == nil {
+			return fmt.Errorf("no current reference")
+		}
+		w.el.setAttr(in.Name, in.Value)
+		w.elAttrNames[in.Name] = true
+
+	case ildecode.SelectMountPoint:
+		w.elAttrNames = make(map[string]bool)
+		w.elEventKeys = make(map[string]bool)
+		w.elDelegated = false
+		if w.mountPointEl == nil {
+			el, err := w.querySelector(in.Selector)
+			if err != nil {
+				return err
+			}
+			if el == nil {
+				return fmt.Errorf("mount point selector not found: %s", in.Selector)
+			}
+			w.mountPointEl = el
+		}
+		el := w.mountPointEl
+		if el.Type != vugu.ElementNode || !strings.EqualFold(el.Data, in.NodeName) {
+			newEl := createElement(in.NodeName, "")
+			el.Parent.replaceChild(newEl, el)
+			w.mountPointEl = newEl
+			el = newEl
+		}
+		w.el = el
+		w.nextElMove = ""
+
+	case ildecode.RemoveOtherAttrs:
+		if w.el == nil {
+			return fmt.Errorf("no element selected")
+		}
+		if w.nextElMove != "" {
+			return fmt.Errorf("cannot remove attributes when nextElMove is set")
+		}
+		attrs := w.el.Attr[:0]
+		for _, a := range w.el.Attr {
+			if w.elAttrNames[a.Key] {
+				attrs = append(attrs, a)
+			}
+		}
+		w.el.Attr = attrs
+
+	case ildecode.MoveToParent:
+		if w.nextElMove == "first_child" {
+			w.nextElMove = ""
+			break
+		}
+		p := w.el.Parent
+		for w.el.nextSibling() != nil {
+			p.removeChild(w.el.nextSibling())
+		}
+		w.el = p
+		w.nextElMove = ""
+
+	case ildecode.MoveToFirstChild:
+		err := w.doNextElMove()
+		if err != nil {
+			return err
+		}
+		if w.el == nil {
+			return fmt.Errorf("must have current selection")
+		}
+		w.nextElMove = "first_child"
+
+	case ildecode.MoveToNextSibling:
+		err := w.doNextElMove()
+		if err != nil {
+			return err
+		}
+		if w.el == nil {
+			return fmt.Errorf("must have current selection")
+		}
+		w.nextElMove = "next_sibling"
+
+	case ildecode.SetElement:
+		return w.setElement(in.NodeName, "")
+
+	case ildecode.SetElementNS:
+		return w.setElement(in.NodeName, in.Namespace)
+
+	case ildecode.SetText:
+		w.pendingKey = ""
+		return w.setNode(vugu.TextNode, in.Text)
+
+	case ildecode.SetComment:
+		w.pendingKey = ""
+		return w.setNode(vugu.CommentNode, in.Comment)
+
+	case ildecode.BufferInnerHTML:
+		w.bufferedInnerHTML += in.HTML
+
+	case ildecode.SetInnerHTML:
+		if w.el == nil || w.nextElMove != "" || w.el.Type != vugu.ElementNode {
+			return fmt.Errorf("must have current element selected")
+		}
+		ctx := &html.Node{Type: html.ElementNode, Data: w.el.Data, DataAtom: atom.Lookup([]byte(w.el.Data))}
+		parts, err := html.ParseFragment(strings.NewReader(w.bufferedInnerHTML+in.HTML), ctx)
+		if err != nil {
+			return err
+		}
+		w.bufferedInnerHTML = ""
+		w.el.Children = nil
+		for _, p := range parts {
+			w.el.appendChild(fromHTMLNode(p))
+		}
+
+	case ildecode.RemoveOtherEventListeners:
+		if w.el == nil {
+			return fmt.Errorf("must have current element selected")
+		}
+		emap := w.eventHandlerMap[in.PositionID]
+		for k, l := range emap {
+			if !w.elEventKeys[k] {
+				w.el.removeListener(l)
+				delete(emap, k)
+			}
+		}
+		// an element moved by opcodeMoveKeyed still has the listeners from its previous position
+		if prev := w.el.positionID; prev != in.PositionID {
+			for _, l := range w.eventHandlerMap[prev] {
+				w.el.removeListener(l)
+			}
+			w.el.positionID = in.PositionID
+		}
+		if len(emap) == 0 {
+			delete(w.eventHandlerMap, in.PositionID)
+		}
+		if !w.elDelegated {
+			w.el.delegated = nil
+		}
+
+	case ildecode.SetEventListener:
+		if w.el == nil {
+			return fmt.Errorf("must have current element selected")
+		}
+		nl := &listener{
+			positionID: in.PositionID,
+			eventType:  in.EventType,
+			capture:    in.Capture,
+			passive:    in.Passive,
+			flags:      in.Flags,
+			keys:       in.Keys,
+		}
+		eventKey := nl.key()
+		w.elEventKeys[eventKey] = true
+		emap := w.eventHandlerMap[in.PositionID]
+		if emap == nil {
+			emap = make(map[string]*listener)
+			w.eventHandlerMap[in.PositionID] = emap
+		}
+		l := emap[eventKey]
+		if l == nil {
+			l = nl
+			emap[eventKey] = l
+		}
+		if !l.fired {
+			w.el.addListener(l)
+		}
+
+	case ildecode.SetDelegatedEvents:
+		if w.el == nil {
+			return fmt.Errorf("must have current element selected")
+		}
+		// once listeners which already fired stay that way while the element is at the same position
+		fired := make(map[string]bool)
+		for _, l := range w.el.delegated {
+			if l.positionID == in.PositionID {
+				fired[l.key()] = l.fired
+			}
+		}
+		var ls []*listener
+		for _, k := range strings.Split(in.Events, "\n") {
+			l, err := parseEventKey(in.PositionID, k)
+			if err != nil {
+				return err
+			}
+			l.fired = fired[k]
+			ls = append(ls, l)
+			w.delegateEvent(l.eventType, l.capture, l.passive)
+		}
+		w.el.delegated = ls
+		w.elDelegated = true
+
+	case ildecode.SetCSSTag:
+		return w.setCSSTag(in)
+
+	case ildecode.RemoveOtherCSSTags:
+		for _, cssEl := range w.doc.QueryAll("style,link") {
+			if !cssEl.cssCreated || containsNode(w.cssTagsSet, cssEl) {
+				continue
+			}
+			cssEl.Parent.removeChild(cssEl)
+		}
+		w.cssTagsSet = nil
+
+	case ildecode.Callback:
+		if w.callbackHandler.IsUndefined() {
+			return fmt.Errorf("no callback handler set")
+		}
+		w.callbackHandler.Invoke(in.CallbackID)
+
+	case ildecode.CallbackLastElement:
+		if w.el == nil {
+			return fmt.Errorf("no current reference")
+		}
+		if w.callbackHandler.IsUndefined() {
+			return fmt.Errorf("no callback handler set")
+		}
+		w.callbackHandler.Invoke(in.CallbackID, nodeObject{w: w, n: w.el})
+
+	case ildecode.HydrateExpect:
+		w.hydrateExpect(in)
+
+	case ildecode.RemoveKeyed:
+		if w.el == nil || w.nextElMove != "" {
+			return fmt.Errorf("must be called with the parent element selected")
+		}
+		for _, c := range w.el.Children {
+			if c.key == in.Key {
+				w.el.removeChild(c)
+				break
+			}
+		}
+
+	case ildecode.MoveKeyed:
+		var parent, slot *Node
+		switch w.nextElMove {
+		case "first_child":
+			parent = w.el
+			slot = w.el.firstChild()
+		case "next_sibling":
+			parent = w.el.Parent
+			slot = w.el.nextSibling()
+		default:
+			return fmt.Errorf("must follow MoveToFirstChild or MoveToNextSibling")
+		}
+		w.pendingKey = in.Key
+		if slot != nil && slot.key == in.Key {
+			break // already in place
+		}
+		var found *Node
+		for c := slot; c != nil; c = c.nextSibling() {
+			if c.key == in.Key {
+				found = c
+				break
+			}
+		}
+		if found != nil {
+			parent.insertBefore(found, slot)
+		} else if slot != nil && slot.key != "" {
+			parent.insertBefore(&Node{Type: vugu.CommentNode}, slot)
+		}
+
+	case ildecode.SkipNode:
+		return w.skipNodes(1)
+
+	case ildecode.SkipNodes:
+		return w.skipNodes(in.Count)
+
+	case ildecode.RemoveAttr:
+		if w.el == nil {
+			return fmt.Errorf("no element selected")
+		}
+		if w.nextElMove != "" {
+			return fmt.Errorf("cannot remove attribute when nextElMove is set")
+		}
+		w.el.removeAttr(in.Name)
+
+	case ildecode.SelectPortal:
+		target, err := w.querySelector(in.Selector)
+		if err != nil {
+			return err
+		}
+		if target == nil {
+			return fmt.Errorf("target element not found for selector %q", in.Selector)
+		}
+		el := portalElement(target, in.PortalID)
+		if el == nil {
+			el = createElement("div", "")
+			el.setAttr("data-vg-portal", in.PortalID)
+			el.setAttr("style", "display: contents")
+			target.appendChild(el)
+		}
+		w.el = el
+		w.nextElMove = ""
+
+	case ildecode.RemovePortal:
+		target, err := w.querySelector(in.Selector)
+		if err != nil {
+			return err
+		}
+		if target != nil {
+			if el := portalElement(target, in.PortalID); el != nil {
+				target.removeChild(el)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported instruction")
+	}
+
+	return nil
+}
+
+// doNextElMove performs the move from MoveToFirstChild or MoveToNextSibling, if any
+func (w *Window) doNextElMove() error {
+	switch w.nextElMove {
+	case "first_child":
+		w.el = w.el.firstChild()
+		if w.el == nil {
+			return fmt.Errorf("unable to find first child")
+		}
+	case "next_sibling":
+		w.el = w.el.nextSibling()
+		if w.el == nil {
+			return fmt.Errorf("unable to find next sibling")
+		}
+	}
+	w.nextElMove = ""
+	return nil
+}
+
+// moveOrCreate selects the node for nextElMove, creating it with create if it doesn't exist,
+// it returns true if the node was created
+func (w *Window) moveOrCreate(create func() *Node) (bool, error) {
+	var next, parent *Node
+	switch w.nextElMove {
+	case "":
+		return false, nil
+	case "first_child":
+		next, parent = w.el.firstChild(), w.el
+	case "next_sibling":
+		next, parent = w.el.nextSibling(), w.el.Parent
+	default:
+		return false, fmt.Errorf("bad nextElMove value: %s", w.nextElMove)
+	}
+	w.nextElMove = ""
+	if next != nil {
+		w.el = next
+		return false, nil
+	}
+	w.el = create()
+	parent.appendChild(w.el)
+	return true, nil
+}
+
+// setElement is SetElement and SetElementNS
+func (w *Window) setElement(nodeName, namespace string) error {
+
+	w.elAttrNames = make(map[string]bool)
+	w.elEventKeys = make(map[string]bool)
+	w.elDelegated = false
+
+	create := func() *Node { return createElement(nodeName, namespace) }
+	created, err := w.moveOrCreate(create)
+	if err != nil {
+		return err
+	}
+
+	if !created && (w.el.Type != vugu.ElementNode || !strings.EqualFold(w.el.Data, nodeName)) {
+		newEl := create()
+		w.el.Parent.replaceChild(newEl, w.el)
+		w.el = newEl
+	}
+
+	// elements synced without a key have any previous key cleared
+	w.el.key = w.pendingKey
+	w.pendingKey = ""
+
+	return nil
+}
+
+// setNode is SetText and SetComment
+func (w *Window) setNode(typ vugu.VGNodeType, content string) error {
+
+	create := func() *Node { return &Node{Type: typ, Data: content} }
+	created, err := w.moveOrCreate(create)
+	if err != nil || created {
+		return err
+	}
+
+	if w.el.Type != typ {
+		newEl := create()
+		w.el.Parent.replaceChild(newEl, w.el)
+		w.el = newEl
+	} else {
+		w.el.Data = content
+	}
+
+	return nil
+}
+
+// skipNodes is SkipNode and SkipNodes
+func (w *Window) skipNodes(count uint32) error {
+	switch w.nextElMove {
+	case "first_child":
+		w.el = w.el.firstChild()
+	case "next_sibling":
+		w.el = w.el.nextSibling()
+	}
+	w.nextElMove = ""
+	w.pendingKey = ""
+	for i := uint32(1); i < count && w.el != nil; i++ {
+		w.el = w.el.nextSibling()
+	}
+	if w.el == nil {
+		return fmt.Errorf("not enough nodes to skip")
+	}
+	return nil
+}
+
+func (w *Window) setCSSTag(in ildecode.SetCSSTag) error {
+
+	attrs := make(map[string]string, len(in.AttrPairs)/2)
+	var attrKeys []string
+	for i := 0; i+1 < len(in.AttrPairs); i += 2 {
+		if _, ok := attrs[in.AttrPairs[i]]; !ok {
+			attrKeys = append(attrKeys, in.AttrPairs[i])
+		}
+		attrs[in.AttrPairs[i]] = in.AttrPairs[i+1]
+	}
+
+	// style tags are the same if they have the same text, links if they have the same href
+	cssKey := func(el *Node) string {
+		if el.Data == "link" {
+			v, _ := el.AttrValue("href")
+			return v
+		}
+		return el.Text()
+	}
+	thisKey := in.TextContent
+	if in.ElementName == "link" {
+		thisKey = attrs["href"]
+	}
+	if thisKey == "" {
+		return nil
+	}
+
+	for _, cssEl := range w.doc.QueryAll(in.ElementName) {
+		if cssKey(cssEl) == thisKey {
+			w.cssTagsSet = append(w.cssTagsSet, cssEl)
+			return nil
+		}
+	}
+
+	head := w.doc.Query("head")
+	if head == nil {
+		return fmt.Errorf("document has no head")
+	}
+	el := createElement(in.ElementName, "")
+	for _, k := range attrKeys {
+		el.setAttr(k, attrs[k])
+	}
+	el.cssCreated = true
+	if in.TextContent != "" {
+		el.appendChild(&Node{Type: vugu.TextNode, Data: in.TextContent})
+	}
+	head.appendChild(el)
+	w.cssTagsSet = append(w.cssTagsSet, el)
+
+	return nil
+}
+
+func (w *Window) hydrateExpect(in ildecode.HydrateExpect) {
+
+	el := w.el
+	switch w.nextElMove {
+	case "first_child":
+		if el != nil {
+			el = el.firstChild()
+		}
+	case "next_sibling":
+		if el != nil {
+			el = el.nextSibling()
+		}
+	}
+
+	ok := el != nil && domNodeType(el) == int(in.NodeType)
+	if ok && el.Type == vugu.ElementNode {
+		ok = strings.EqualFold(el.Data, in.NodeName)
+		// if the server wrote a position marker it must agree with ours
+		if marker, has := el.AttrValue("data-vgpos"); ok && has && marker != in.PositionID {
+			ok = false
+		}
+	}
+
+	if !ok {
+		found := "(none)"
+		if el != nil {
+			found = domNodeName(el)
+			if marker, has := el.AttrValue("data-vgpos"); el.Type == vugu.ElementNode && has {
+				found += "[data-vgpos=" + marker + "]"
+			}
+		}
+		w.hydrateMismatches = append(w.hydrateMismatches, map[string]any{
+			"position_id": in.PositionID,
+			"expected":    in.NodeName,
+			"found":       found,
+		})
+	}
+}
+
+// querySelector returns the first element in the document matching selector, or nil if none
+func (w *Window) querySelector(selector string) (*Node, error) {
+	sel, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	var ret *Node
+	w.doc.walkElements(func(c *Node) bool {
+		if sel.match(c) {
+			ret = c
+			return false
+		}
+		return true
+	})
+	return ret, nil
+}
+
+// call is a call to a function of the window, the ones which domrender uses are implemented
+func (w *Window) call(m string, args []any) js.Value {
+
+	switch m {
+
+	case "eval":
+		// the script is the JS side of domrender, which this replaces
+
+	case "vuguGetRenderArray":
+		if w.renderArray == nil {
+			w.renderArray = make([]byte, 16384)
+		}
+		return js.ValueOf(w.renderArray)
+
+	case "vuguRender":
+		if w.renderArray == nil {
+			panic(fmt.Errorf("vugutest: vuguRender called before vuguGetRenderArray"))
+		}
+		err := w.Apply(w.renderArray)
+		if err != nil {
+			panic(err)
+		}
+
+	case "vuguSetEventHandler":
+		w.eventHandler = js.ValueOf(args[0])
+
+	case "vuguSetCallbackHandler":
+		w.callbackHandler = js.ValueOf(args[0])
+
+	case "vuguHydrateMismatches":
+		mml := w.hydrateMismatches
+		if mml == nil {
+			mml = []map[string]any{}
+		}
+		b, err := vjson.Marshal(mml)
+		if err != nil {
+			panic(err)
+		}
+		w.hydrateMismatches = nil
+		return js.ValueOf(string(b))
+
+	case "vuguGetActiveEvent":
+		if w.activeEvent != nil {
+			return js.ValueOf(w.activeEvent.EventSummary())
+		}
+
+	case "vuguGetActiveEventTarget":
+		if w.activeTarget != nil {
+			return js.ValueOf(nodeObject{w: w, n: w.activeTarget})
+		}
+
+	case "vuguGetActiveEventCurrentTarget":
+		if w.activeCurrentTarget != nil {
+			return js.ValueOf(nodeObject{w: w, n: w.activeCurrentTarget})
+		}
+
+	case "vuguActiveEventPreventDefault":
+		if w.activeEvent != nil {
+			w.activeEvent.defaultPrevented = true
+		}
+
+	case "vuguActiveEventStopPropagation":
+		if w.activeEvent != nil {
+			w.activeEvent.propagationStopped = true
+		}
+
+	default:
+		panic(fmt.Errorf("vugutest: Window does not implement %s", m))
+	}
+
+	return js.Undefined()
+}
+
+// windowObject is the js.Value for the window, see Window.Install
+type windowObject struct{ w *Window }
+
+func (o windowObject) Get(p string) js.Value {
+	if p == "window" {
+		return js.ValueOf(o)
+	}
+	return o.w.props[p]
+}
+
+func (o windowObject) Set(p string, x any) {
+	if o.w.props == nil {
+		o.w.props = make(map[string]js.Value)
+	}
+	o.w.props[p] = js.ValueOf(x)
+}
+
+func (o windowObject) Call(m string, args ...any) js.Value {
+	return o.w.call(m, args)
+}
+
+// nodeObject is the js.Value for an element, e.g. for vg-js-create and vg-ref
+type nodeObject struct {
+	w *Window
+	n *Node
+}
+
+func (o nodeObject) Get(p string) js.Value {
+	switch p {
+	case "nodeName":
+		return js.ValueOf(strings.ToUpper(domNodeName(o.n)))
+	case "tagName":
+		return js.ValueOf(strings.ToUpper(o.n.Data))
+	case "id":
+		return js.ValueOf(o.n.ID())
+	case "value":
+		return js.ValueOf(o.n.Value())
+	case "checked":
+		return js.ValueOf(o.n.Checked())
+	case "textContent":
+		return js.ValueOf(o.n.Text())
+	}
+	if v, ok := o.n.Props[p]; ok {
+		return js.ValueOf(v)
+	}
+	return js.Undefined()
+}
+
+func (o nodeObject) Set(p string, x any) {
+	o.n.setProp(p, x)
+}
+
+func (o nodeObject) Call(m string, args ...any) js.Value {
+	switch m {
+	case "getAttribute":
+		if v, ok := o.n.AttrValue(fmt.Sprint(args[0])); ok {
+			return js.ValueOf(v)
+		}
+		return js.Null()
+	case "setAttribute":
+		o.n.setAttr(fmt.Sprint(args[0]), fmt.Sprint(args[1]))
+		return js.Undefined()
+	case "removeAttribute":
+		o.n.removeAttr(fmt.Sprint(args[0]))
+		return js.Undefined()
+	case "focus", "blur", "scrollIntoView":
+		return js.Undefined()
+	case "getBoundingClientRect":
+		// there is no layout, so every element is empty and at the origin
+		return js.ValueOf(map[string]any{"x": 0, "y": 0, "width": 0, "height": 0, "top": 0, "right": 0, "bottom": 0, "left": 0})
+	}
+	panic(fmt.Errorf("vugutest: element does not implement %s", m))
+}
+
+// createElement is document.createElement and createElementNS
+func createElement(nodeName, namespace string) *Node {
+	n := &Node{Type: vugu.ElementNode, Data: nodeName}
+	switch namespace {
+	case "", "http://www.w3.org/1999/xhtml":
+		n.Data = strings.ToLower(nodeName)
+	case "http://www.w3.org/2000/svg":
+		n.Namespace = "svg"
+	case "http://www.w3.org/1998/Math/MathML":
+		n.Namespace = "math"
+	default:
+		n.Namespace = namespace
+	}
+	return n
+}
+
+// portalElement returns the child of target which holds the children of the portal
+func portalElement(target *Node, portalID string) *Node {
+	for _, c := range target.Children {
+		if v, ok := c.AttrValue("data-vg-portal"); ok && c.Type == vugu.ElementNode && v == portalID {
+			return c
+		}
+	}
+	return nil
+}
+
+// domNodeType returns the nodeType of n in the browser
+func domNodeType(n *Node) int {
+	switch n.Type {
+	case vugu.ElementNode:
+		return 1
+	case vugu.TextNode:
+		return 3
+	case vugu.CommentNode:
+		return 8
+	case vugu.DocumentNode:
+		return 9
+	}
+	return 0
+}
+
+// domNodeName returns the nodeName of n in the browser, in lower case
+func domNodeName(n *Node) string {
+	switch n.Type {
+	case vugu.TextNode:
+		return "#text"
+	case vugu.CommentNode:
+		return "#comment"
+	case vugu.DocumentNode:
+		return "#document"
+	}
+	return strings.ToLower(n.Data)
+}
+
+func boolDigit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func containsNode(l []*Node, n *Node) bool {
+	for _, c := range l {
+		if c == n {
+			return true
+		}
+	}
+	return false
+}
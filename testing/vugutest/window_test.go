@@ -0,0 +1,314 @@
+//go:build !js || !wasm
+
+package vugutest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/domrender"
+	js "github.com/vugu/vugu/js"
+)
+
+const testPage = `<html><head></head><body><div id="app"></div><div id="modals"></div></body></html>`
+
+// newTestRenderer returns a Window and a domrender.JSRenderer rendering into it
+func newTestRenderer(t *testing.T) (*Window, *domrender.JSRenderer, *vugu.BuildEnv) {
+	w, err := NewWindow(testPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Install())
+	r, err := domrender.New("#app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildEnv, err := vugu.NewBuildEnv(r.EventEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, r, buildEnv
+}
+
+func TestWindow(t *testing.T) {
+
+	assert := assert.New(t)
+
+	w, r, buildEnv := newTestRenderer(t)
+	c := &counter{}
+	render := func() {
+		t.Helper()
+		assert.NoError(r.Render(buildEnv.RunBuild(c)))
+	}
+
+	render()
+	root := w.Query("#root")
+	assert.NotNil(root)
+	assert.Equal("0", w.Query(".count").Text())
+	assert.Equal("raw", w.Query(".raw b").Text())
+	assert.Equal("", w.Query("input").Value())
+
+	// the event goes through the renderer's handleDOMEvent to the handlers
+	_, err := w.Click("#add")
+	assert.NoError(err)
+	assert.Equal([]string{"root-capture", "add", "root-bubble"}, c.Log)
+	render()
+	_, err = w.Click("#add")
+	assert.NoError(err)
+	render()
+	assert.Equal("2", w.Query(".count").Text())
+	assert.Len(w.QueryAll("#root > p"), 2)
+
+	// the DOM is updated in place
+	assert.Same(root, w.Query("#root"))
+
+	// and is the same as the Harness builds from the output directly
+	h, err := New(&counter{Count: 2})
+	assert.NoError(err)
+	assert.Equal(h.MustQuery("#root").HTML(), root.HTML())
+
+	// modifiers are applied before the event gets to the renderer
+	c.Log = nil
+	ev, err := w.Dispatch(w.Query("#stop"), "click", nil)
+	assert.NoError(err)
+	assert.True(ev.DefaultPrevented())
+	assert.True(ev.PropagationStopped())
+	assert.Equal([]string{"root-capture"}, c.Log)
+	render()
+	_, err = w.Click("#stop")
+	assert.NoError(err)
+	assert.Equal(1, c.Clicks)
+
+	// properties
+	assert.NoError(w.Input("input[name=name]", "Joe"))
+	assert.Equal("Joe", c.Name)
+	render()
+	assert.Equal("Joe", w.Query("input").Prop("value"))
+
+	// key filtering
+	c.Log = nil
+	_, err = w.Dispatch(w.Query("input"), "keydown", map[string]any{"key": "a"})
+	assert.NoError(err)
+	assert.NotContains(c.Log, "enter")
+	_, err = w.Dispatch(w.Query("input"), "keydown", map[string]any{"key": "Enter"})
+	assert.NoError(err)
+	assert.Contains(c.Log, "enter")
+
+	_, err = w.Click("#missing")
+	assert.ErrorIs(err, ErrNotFound)
+}
+
+func TestWindowKeyed(t *testing.T) {
+
+	assert := assert.New(t)
+
+	w, r, buildEnv := newTestRenderer(t)
+
+	items := []string{"a", "b", "c"}
+	var created []string
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		ul := &vugu.VGNode{Type: vugu.ElementNode, Data: "ul"}
+		for _, it := range items {
+			li := &vugu.VGNode{Type: vugu.ElementNode, Data: "li", Key: it, Attr: []vugu.VGAttribute{{Key: "id", Val: it}}}
+			li.AppendChild(&vugu.VGNode{Type: vugu.TextNode, Data: it})
+			it := it
+			li.JSCreateHandler = vugu.JSValueFunc(func(v js.Value) {
+				created = append(created, it+":"+v.Get("id").String())
+			})
+			ul.AppendChild(li)
+		}
+		return &vugu.BuildOut{Out: []*vugu.VGNode{ul}}
+	})
+
+	assert.NoError(r.Render(buildEnv.RunBuild(root)))
+	assert.Equal([]string{"a:a", "b:b", "c:c"}, created)
+	a, c := w.Query("#a"), w.Query("#c")
+
+	// keyed elements are moved, not rewritten
+	items = []string{"c", "a"}
+	assert.NoError(r.Render(buildEnv.RunBuild(root)))
+	lis := w.QueryAll("li")
+	if assert.Len(lis, 2) {
+		assert.Same(c, lis[0])
+		assert.Same(a, lis[1])
+	}
+	assert.Equal("<ul><li id=\"c\">c</li><li id=\"a\">a</li></ul>", w.Query("ul").HTML())
+}
+
+func TestWindowPortal(t *testing.T) {
+
+	assert := assert.New(t)
+
+	w, r, buildEnv := newTestRenderer(t)
+
+	open := true
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div", Attr: []vugu.VGAttribute{{Key: "id", Val: "root"}}}
+		portal := &vugu.VGNode{Type: vugu.ElementNode, Portal: "#modals"}
+		if open {
+			btn := &vugu.VGNode{Type: vugu.ElementNode, Data: "button", Attr: []vugu.VGAttribute{{Key: "id", Val: "close"}}}
+			btn.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+				{EventType: "click", Func: func(vugu.DOMEvent) { open = false }},
+			}
+			portal.AppendChild(btn)
+		}
+		div.AppendChild(portal)
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+
+	assert.NoError(r.Render(buildEnv.RunBuild(root)))
+	assert.NotNil(w.Query("#modals > [data-vg-portal] > #close"))
+
+	_, err := w.Click("#close")
+	assert.NoError(err)
+	assert.False(open)
+	assert.NoError(r.Render(buildEnv.RunBuild(root)))
+	assert.Nil(w.Query("#close"))
+	assert.Nil(w.Query("[data-vg-portal]"))
+}
+
+func TestWindowEventDelegation(t *testing.T) {
+
+	assert := assert.New(t)
+
+	w, r, buildEnv := newTestRenderer(t)
+	r.SetEventDelegation(true)
+	c := &counter{}
+	render := func() {
+		t.Helper()
+		assert.NoError(r.Render(buildEnv.RunBuild(c)))
+	}
+
+	render()
+	root := w.Query("#root")
+	assert.Len(w.Query("#add").listeners, 0)
+	assert.Len(root.listeners, 4) // click in both phases, input and keydown
+
+	// the same order as with a listener on each element
+	_, err := w.Click("#add")
+	assert.NoError(err)
+	assert.Equal([]string{"root-capture", "add", "root-bubble"}, c.Log)
+	render()
+	assert.Equal("1", w.Query(".count").Text())
+
+	// stop keeps it from the handlers further up, once is only called the first time
+	c.Log = nil
+	ev, err := w.Click("#stop")
+	assert.NoError(err)
+	assert.True(ev.DefaultPrevented())
+	assert.True(ev.PropagationStopped())
+	assert.Equal([]string{"root-capture"}, c.Log)
+	render()
+	_, err = w.Click("#stop")
+	assert.NoError(err)
+	assert.Equal(1, c.Clicks)
+
+	assert.NoError(w.Input("input[name=name]", "Joe"))
+	assert.Equal("Joe", c.Name)
+	c.Log = nil
+	_, err = w.Dispatch(w.Query("input"), "keydown", map[string]any{"key": "Enter"})
+	assert.NoError(err)
+	assert.Equal([]string{"enter"}, c.Log)
+}
+
+func TestWindowEventDelegationBody(t *testing.T) {
+
+	assert := assert.New(t)
+
+	w, r, buildEnv := newTestRenderer(t)
+	r.SetEventDelegation(true)
+
+	var log []string
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		html := &vugu.VGNode{Type: vugu.ElementNode, Data: "html"}
+		html.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "head"})
+		body := &vugu.VGNode{Type: vugu.ElementNode, Data: "body"}
+		body.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+			{EventType: "click", Func: func(vugu.DOMEvent) { log = append(log, "body") }},
+		}
+		html.AppendChild(body)
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div", Attr: []vugu.VGAttribute{{Key: "id", Val: "app"}}}
+		body.AppendChild(div)
+		btn := &vugu.VGNode{Type: vugu.ElementNode, Data: "button", Attr: []vugu.VGAttribute{{Key: "id", Val: "btn"}}}
+		btn.DOMEventHandlerSpecList = []vugu.DOMEventHandlerSpec{
+			{EventType: "click", Func: func(vugu.DOMEvent) { log = append(log, "btn") }},
+		}
+		div.AppendChild(btn)
+		return &vugu.BuildOut{Out: []*vugu.VGNode{html}}
+	})
+
+	// the body is above the mount point, so its listener is added to it directly
+	assert.NoError(r.Render(buildEnv.RunBuild(root)))
+	assert.Len(w.Query("body").listeners, 1)
+	assert.Len(w.Query("#btn").listeners, 0)
+
+	_, err := w.Click("#btn")
+	assert.NoError(err)
+	assert.Equal([]string{"btn", "body"}, log)
+}
+
+func TestWindowHydrateMountPoint(t *testing.T) {
+
+	assert := assert.New(t)
+
+	w, r, buildEnv := newTestRenderer(t)
+	r.SetHydrate(true)
+
+	// the server rendered a div at the mount point but the client renders a section
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		return &vugu.BuildOut{Out: []*vugu.VGNode{{Type: vugu.ElementNode, Data: "section"}}}
+	})
+
+	assert.NoError(r.Render(buildEnv.RunBuild(root)))
+	mm := r.HydrationMismatches()
+	if assert.Len(mm, 1) {
+		assert.Equal(domrender.HydrationMismatch{PositionID: "0", Expected: "section", Found: "div"}, mm[0])
+	}
+	assert.NotNil(w.Query("body > section"))
+}
+
+func TestWindowInstallJS(t *testing.T) {
+
+	assert := assert.New(t)
+
+	// without a Window the js placeholders behave as they always have outside of wasm
+	assert.True(js.Null().IsUndefined())
+	assert.True(js.ValueOf(1).IsUndefined())
+	assert.True(js.ValueOf("a").Equal(js.ValueOf("b")))
+	assert.Panics(func() { js.FuncOf(func(this js.Value, args []js.Value) any { return nil }) })
+
+	w, err := NewWindow(testPage)
+	assert.NoError(err)
+	uninstall := w.Install()
+
+	assert.True(js.Null().IsNull())
+	assert.Equal(1, js.ValueOf(1).Int())
+	assert.False(js.ValueOf("a").Equal(js.ValueOf("b")))
+	f := js.FuncOf(func(this js.Value, args []js.Value) any { return args[0].Int() + 1 })
+	assert.Equal(3, f.Invoke(2).Int())
+
+	// and Element is backed by the Window's elements
+	r, err := domrender.New("#app")
+	assert.NoError(err)
+	buildEnv, err := vugu.NewBuildEnv(r.EventEnv())
+	assert.NoError(err)
+	var el js.Element
+	root := vugu.NewBuilderFunc(func(in *vugu.BuildIn) (out *vugu.BuildOut) {
+		div := &vugu.VGNode{Type: vugu.ElementNode, Data: "div", Attr: []vugu.VGAttribute{{Key: "id", Val: "app"}}}
+		div.AppendChild(&vugu.VGNode{Type: vugu.ElementNode, Data: "input", Attr: []vugu.VGAttribute{{Key: "id", Val: "name"}}, Ref: &el})
+		return &vugu.BuildOut{Out: []*vugu.VGNode{div}}
+	})
+	assert.NoError(r.Render(buildEnv.RunBuild(root)))
+	assert.True(el.IsSet())
+	el.SetValue("Joe")
+	assert.Equal("Joe", w.Query("#name").Value())
+	assert.Equal("Joe", el.Value())
+	assert.Equal(js.Rect{}, el.GetBoundingClientRect())
+	el.Focus()
+
+	// uninstalling restores the placeholders
+	uninstall()
+	assert.True(js.Null().IsUndefined())
+}
@@ -5,6 +5,7 @@ import (
 	"html"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/vugu/vugu/js"
 )
@@ -76,13 +77,24 @@ type VGNode struct {
 
 	DOMEventHandlerSpecList []DOMEventHandlerSpec // describes invocations when DOM events happen
 
+	// identifies this node among its siblings (set with vg-key), when the children of an element
+	// change order the renderer moves the existing DOM node for each key instead of rewriting them in place
+	Key string
+
 	// indicates this node's output should be delegated to the specified component
 	Component any
 
+	// if set, this is a portal (see IsPortal) and this is the CSS selector of the element its children are rendered into
+	Portal string
+
 	// if not-nil, called when element is created (but before examining child nodes)
 	JSCreateHandler JSValueHandler
 	// if not-nil, called after children have been visited
 	JSPopulateHandler JSValueHandler
+
+	// if not-nil, the renderer assigns the element to this when it is rendered and
+	// sets it back to the zero value when the element is no longer rendered (set with vg-ref)
+	Ref *js.Element
 }
 
 // IsComponent returns true if this is a component (Component != nil).
@@ -91,15 +103,39 @@ func (n *VGNode) IsComponent() bool {
 	return n.Component != nil
 }
 
-// IsTemplate returns true if this is a template (Type is ElementNode and Data is an empty string and not a Component).
+// IsTemplate returns true if this is a template (Type is ElementNode and Data is an empty string and not a Component or portal).
 // Templates have their children flattened into the output DOM instead of being processed directly.
 func (n *VGNode) IsTemplate() bool {
-	if n.Type == ElementNode && n.Data == "" && n.Component == nil {
+	if n.Type == ElementNode && n.Data == "" && n.Component == nil && n.Portal == "" {
 		return true
 	}
 	return false
 }
 
+// IsPortal returns true if this is a portal (Type is ElementNode, Data is an empty string and Portal is set).
+// The children of a portal are rendered inside the existing element matching the Portal selector instead
+// of in place (which is useful for modals, tooltips and the like), a placeholder comment is output in place.
+// The element is found in the document when rendering, it should be outside of the mount point.
+func (n *VGNode) IsPortal() bool {
+	return n.Type == ElementNode && n.Data == "" && n.Component == nil && n.Portal != ""
+}
+
+// PortalTarget returns the value for the Portal of a vg-portal with the target selector sel.
+// An empty sel (e.g. from a :target expression) is returned as a blank selector, so the node is
+// still a portal instead of a template which outputs its children in place, and renderers return
+// an error for it (see IsBlankPortal).
+func PortalTarget(sel string) string {
+	if sel == "" {
+		return " "
+	}
+	return sel
+}
+
+// IsBlankPortal returns true if this is a portal whose selector is blank, which renderers report as an error.
+func (n *VGNode) IsBlankPortal() bool {
+	return n.IsPortal() && strings.TrimSpace(n.Portal) == ""
+}
+
 // InsertBefore inserts newChild as a child of n, immediately before oldChild
 // in the sequence of n's children. oldChild may be nil, in which case newChild
 // is appended to the end of n's children.
@@ -279,6 +315,17 @@ func (n *VGNode) AddAttrInterface(key string, val any) {
 	n.Attr = append(n.Attr, nattr)
 }
 
+// SetKey assigns Key from the given value, using the same conversion as AddAttrInterface.
+// A nil value leaves Key empty.
+func (n *VGNode) SetKey(val any) {
+	var tmp VGNode
+	tmp.AddAttrInterface("", val)
+	n.Key = ""
+	if len(tmp.Attr) > 0 {
+		n.Key = tmp.Attr[0].Val
+	}
+}
+
 // AddAttrList takes a VGAttributeLister and sets the returned attributes to the node
 func (n *VGNode) AddAttrList(lister VGAttributeLister) {
 	n.Attr = append(n.Attr, lister.AttributeList()...)
@@ -0,0 +1,178 @@
+/*
+Package vgtrace records build and render timings and exports them in the Chrome trace event format,
+which can be loaded in chrome://tracing, Perfetto (https://ui.perfetto.dev) or the Performance
+panel of the browser's developer tools.
+
+A Recorder implements both vugu.BuildTracer and domrender.RenderTracer:
+
+	rec := vgtrace.NewRecorder()
+	buildEnv.SetTracer(rec)
+	renderer.SetTracer(rec)
+	...
+	err := rec.WriteChromeTrace(w)
+
+Each build pass, component build and render becomes a complete ("X") event.  Component builds
+are nested inside their build pass on the timeline.  Args on the events carry the cache hits and
+misses, instruction counts by opcode, flushes and bytes flushed.
+*/
+package vgtrace
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/vugu/vjson"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/domrender"
+)
+
+// Event categories.
+const (
+	CategoryBuild     = "build"
+	CategoryComponent = "component"
+	CategoryRender    = "render"
+)
+
+// Event is a single timed event.
+type Event struct {
+	Name     string
+	Category string // one of the Category constants
+	Start    time.Time
+	Duration time.Duration
+	Args     map[string]any
+}
+
+// Recorder collects events from a BuildEnv and a JSRenderer.  It is safe for concurrent use.
+type Recorder struct {
+	// MaxEvents limits the number of events kept, the oldest are dropped first.  Zero means no limit.
+	MaxEvents int
+
+	mu     sync.Mutex
+	events []Event
+}
+
+var _ vugu.BuildTracer = (*Recorder)(nil)
+var _ domrender.RenderTracer = (*Recorder)(nil)
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// ComponentBuilt implements vugu.BuildTracer.
+func (r *Recorder) ComponentBuilt(stats vugu.ComponentBuildStats) {
+	r.add(Event{
+		Name:     fmt.Sprintf("%T", stats.Component),
+		Category: CategoryComponent,
+		Start:    stats.Start,
+		Duration: stats.Duration,
+		Args:     map[string]any{"cache_hit": stats.CacheHit},
+	})
+}
+
+// BuildDone implements vugu.BuildTracer.
+func (r *Recorder) BuildDone(stats vugu.BuildStats) {
+	r.add(Event{
+		Name:     "build",
+		Category: CategoryBuild,
+		Start:    stats.Start,
+		Duration: stats.Duration,
+		Args: map[string]any{
+			"components":   stats.Components,
+			"cache_hits":   stats.CacheHits,
+			"cache_misses": stats.CacheMisses,
+		},
+	})
+}
+
+// RenderDone implements domrender.RenderTracer.
+func (r *Recorder) RenderDone(stats domrender.RenderStats) {
+	opcodes := make(map[string]any, len(stats.OpcodeCounts))
+	for k, v := range stats.OpcodeCounts {
+		opcodes[k] = v
+	}
+	r.add(Event{
+		Name:     "render",
+		Category: CategoryRender,
+		Start:    stats.Start,
+		Duration: stats.Duration,
+		Args: map[string]any{
+			"instructions":       stats.Instructions,
+			"opcodes":            opcodes,
+			"flushes":            stats.Flushes,
+			"bytes_flushed":      stats.BytesFlushed,
+			"components":         stats.Components,
+			"skipped_components": stats.SkippedComponents,
+			"skipped_subtrees":   stats.SkippedSubtrees,
+		},
+	})
+}
+
+func (r *Recorder) add(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	if r.MaxEvents > 0 && len(r.events) > r.MaxEvents {
+		r.events = append(r.events[:0], r.events[len(r.events)-r.MaxEvents:]...)
+	}
+}
+
+// Events returns a copy of the recorded events, in the order they were recorded.
+// Component events are recorded as each component is built, so they come before the build pass that contains them.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Reset discards all recorded events.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// WriteChromeTrace writes the recorded events as Chrome trace event JSON (the "JSON Object Format").
+// Timestamps are relative to the earliest event.
+func (r *Recorder) WriteChromeTrace(w io.Writer) error {
+
+	events := r.Events()
+
+	var epoch time.Time
+	for _, ev := range events {
+		if epoch.IsZero() || ev.Start.Before(epoch) {
+			epoch = ev.Start
+		}
+	}
+
+	traceEvents := make([]any, 0, len(events))
+	for _, ev := range events {
+		te := map[string]any{
+			"name": ev.Name,
+			"cat":  ev.Category,
+			"ph":   "X",
+			"ts":   float64(ev.Start.Sub(epoch).Nanoseconds()) / 1000,
+			"dur":  float64(ev.Duration.Nanoseconds()) / 1000,
+			"pid":  1,
+			"tid":  1,
+		}
+		if len(ev.Args) > 0 {
+			te["args"] = ev.Args
+		}
+		traceEvents = append(traceEvents, te)
+	}
+
+	b, err := vjson.Marshal(map[string]any{
+		"traceEvents":     traceEvents,
+		"displayTimeUnit": "ms",
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,70 @@
+package vgtrace
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vugu/vjson"
+
+	"github.com/vugu/vugu"
+	"github.com/vugu/vugu/domrender"
+)
+
+type root struct{}
+
+func (c *root) Build(vgin *vugu.BuildIn) (vgout *vugu.BuildOut) {
+	return &vugu.BuildOut{Out: []*vugu.VGNode{{Type: vugu.ElementNode, Data: "div"}}}
+}
+
+func TestRecorder(t *testing.T) {
+
+	assert := assert.New(t)
+
+	rec := NewRecorder()
+
+	be, err := vugu.NewBuildEnv()
+	assert.NoError(err)
+	be.SetTracer(rec)
+	be.RunBuild(&root{})
+
+	rec.RenderDone(domrender.RenderStats{
+		Start:        time.Now(),
+		Duration:     time.Millisecond,
+		Instructions: 3,
+		OpcodeCounts: map[string]int{"SetElement": 2, "Bye": 1},
+	})
+
+	events := rec.Events()
+	if assert.Len(events, 3) {
+		assert.Equal("*vgtrace.root", events[0].Name)
+		assert.Equal(CategoryComponent, events[0].Category)
+		assert.Equal(CategoryBuild, events[1].Category)
+		assert.Equal(1, events[1].Args["components"])
+		assert.Equal(CategoryRender, events[2].Category)
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(rec.WriteChromeTrace(&buf))
+
+	var m map[string]any
+	assert.NoError(vjson.Unmarshal(buf.Bytes(), &m))
+	te, _ := m["traceEvents"].([]any)
+	if assert.Len(te, 3) {
+		ev, _ := te[2].(map[string]any)
+		assert.Equal("render", ev["name"])
+		assert.Equal("X", ev["ph"])
+		assert.Equal(float64(1000), ev["dur"])
+		args, _ := ev["args"].(map[string]any)
+		opcodes, _ := args["opcodes"].(map[string]any)
+		assert.Equal(float64(2), opcodes["SetElement"])
+	}
+
+	rec.MaxEvents = 1
+	rec.RenderDone(domrender.RenderStats{Start: time.Now()})
+	assert.Len(rec.Events(), 1)
+
+	rec.Reset()
+	assert.Empty(rec.Events())
+}